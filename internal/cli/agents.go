@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Each agent profile is a state directory of its own under ~/.spirit-agents,
+// with its own tracked files, backends and schedule. The default state in
+// ~/.spirit is the profile named "default".
+
+const defaultAgent = "default"
+
+// configDirFlag and agentFlag are set by the global --config-dir and
+// --agent flags. activeAgent is the profile ConfigDir belongs to, empty
+// when it is the default state or an explicit directory.
+var (
+	configDirFlag string
+	agentFlag     string
+	activeAgent   string
+)
+
+var agentNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+func validateAgentName(name string) error {
+	if !agentNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid agent name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+func homeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return home
+}
+
+func defaultConfigDir() string {
+	return filepath.Join(homeDir(), ".spirit")
+}
+
+func agentsDir() string {
+	return filepath.Join(homeDir(), ".spirit-agents")
+}
+
+func agentDir(name string) string {
+	if name == defaultAgent {
+		return defaultConfigDir()
+	}
+	return filepath.Join(agentsDir(), name)
+}
+
+// selectConfigDir sets ConfigDir from, in order, --config-dir, --agent,
+// SPIRIT_CONFIG_DIR, SPIRIT_AGENT and the default ~/.spirit.
+func selectConfigDir() error {
+	if configDirFlag != "" && agentFlag != "" {
+		return fmt.Errorf("use either --config-dir or --agent, not both")
+	}
+
+	activeAgent = ""
+	switch {
+	case configDirFlag != "":
+		dir, err := filepath.Abs(configDirFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --config-dir: %w", err)
+		}
+		ConfigDir = dir
+		return nil
+	case agentFlag != "":
+		return selectAgent(agentFlag)
+	case os.Getenv("SPIRIT_CONFIG_DIR") != "":
+		ConfigDir = getConfigDir()
+		return nil
+	}
+	return selectAgent(os.Getenv("SPIRIT_AGENT"))
+}
+
+func selectAgent(name string) error {
+	if name == "" || name == defaultAgent {
+		ConfigDir = getConfigDir()
+		return nil
+	}
+	if err := validateAgentName(name); err != nil {
+		return err
+	}
+	activeAgent = name
+	ConfigDir = agentDir(name)
+	return nil
+}
+
+// agentProfile describes one state directory for 'spirit agents list'.
+type agentProfile struct {
+	Name  string
+	Dir   string
+	Emoji string
+	Title string
+}
+
+func listAgentProfiles() []agentProfile {
+	profiles := []agentProfile{}
+	if _, err := os.Stat(defaultConfigDir()); err == nil {
+		profiles = append(profiles, readAgentProfile(defaultAgent, defaultConfigDir()))
+	}
+	entries, _ := os.ReadDir(agentsDir())
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() && validateAgentName(e.Name()) == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		profiles = append(profiles, readAgentProfile(name, agentDir(name)))
+	}
+	return profiles
+}
+
+func readAgentProfile(name, dir string) agentProfile {
+	profile := agentProfile{Name: name, Dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, "spirit.json"))
+	if err != nil {
+		return profile
+	}
+	var config Config
+	if json.Unmarshal(data, &config) == nil {
+		profile.Emoji, profile.Title = config.Identity.Emoji, config.Identity.Name
+	}
+	return profile
+}
+
+func agentsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "agents",
+		Short: "Manage agent profiles on this machine",
+		Long: `Each agent profile has its own state directory, tracked files, backends
+and autobackup schedule. Select one for any command with --agent NAME or
+SPIRIT_AGENT=NAME; without either, the default state in ~/.spirit/ is used.
+
+Examples:
+  spirit agents create atlas --emoji="🗺️"
+  spirit --agent atlas sync
+  SPIRIT_AGENT=atlas spirit status
+  spirit agents remove atlas --force`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List agent profiles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listAgents()
+		},
+	})
+
+	createCmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create an agent profile and initialize its state",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			title, _ := cmd.Flags().GetString("name")
+			emoji, _ := cmd.Flags().GetString("emoji")
+			email, _ := cmd.Flags().GetString("email")
+			return createAgent(args[0], title, emoji, email)
+		},
+	}
+	createCmd.Flags().String("name", "", "Agent name in its identity (default: the profile name)")
+	createCmd.Flags().String("emoji", "🤖", "Agent emoji")
+	createCmd.Flags().String("email", "", "Agent email")
+	cmd.AddCommand(createCmd)
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Delete an agent profile, its state and its schedule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			return removeAgent(args[0], force)
+		},
+	}
+	removeCmd.Flags().Bool("force", false, "Delete the state directory and its local history")
+	cmd.AddCommand(removeCmd)
+
+	return cmd
+}
+
+func listAgents() error {
+	profiles := listAgentProfiles()
+	if len(profiles) == 0 {
+		fmt.Println("No agents yet. Run: spirit init, or spirit agents create <name>")
+		return nil
+	}
+
+	fmt.Println("🌌 Agents")
+	fmt.Println()
+	for _, p := range profiles {
+		marker := " "
+		if filepath.Clean(p.Dir) == filepath.Clean(ConfigDir) {
+			marker = "*"
+		}
+		identity := strings.TrimSpace(p.Emoji + " " + p.Title)
+		if identity == "" {
+			identity = "(not initialized)"
+		}
+		fmt.Printf(" %s %-12s %-20s %s\n", marker, p.Name, identity, p.Dir)
+	}
+	return nil
+}
+
+func createAgent(name, title, emoji, email string) error {
+	if err := validateAgentName(name); err != nil {
+		return err
+	}
+	if name == defaultAgent {
+		return fmt.Errorf("the default agent lives in %s. Run: spirit init", defaultConfigDir())
+	}
+	dir := agentDir(name)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("agent %q already exists in %s", name, dir)
+	}
+	if title == "" {
+		title = name
+	}
+
+	ConfigDir, activeAgent = dir, name
+	if err := initializeSpirit(title, emoji, email); err != nil {
+		return err
+	}
+	fmt.Printf("\nUse it with: spirit --agent %s <command>\n", name)
+	return nil
+}
+
+// removeAgent deletes a profile after removing its schedule. The state is
+// only deleted with force, as unsynced checkpoints are lost with it.
+func removeAgent(name string, force bool) error {
+	if err := validateAgentName(name); err != nil {
+		return err
+	}
+	if name == defaultAgent {
+		return fmt.Errorf("the default agent cannot be removed")
+	}
+	dir := agentDir(name)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("no agent %q", name)
+	}
+	if !force {
+		fmt.Printf("⚠️  This deletes %s, including checkpoints that were never synced.\n", dir)
+		fmt.Printf("   Run again with --force to remove agent %q\n", name)
+		return nil
+	}
+
+	ConfigDir, activeAgent = dir, name
+	return withLock("spirit agents remove", func() error {
+		if err := uninstallSchedule(); err != nil {
+			return fmt.Errorf("failed to remove schedule: %w", err)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️  Removed agent %s (%s)\n", name, dir)
+		return nil
+	})
+}
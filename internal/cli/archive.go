@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/cobra"
+)
+
+// A .spirit archive is a zstd-compressed tar holding manifest.json (the
+// ExportPackage) followed by every file under files/.
+const (
+	archiveExt      = ".spirit"
+	archiveVersion  = "1"
+	archiveManifest = "manifest.json"
+	archiveFilesDir = "files/"
+)
+
+func exportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [source]",
+		Short: "Write the state to a portable .spirit archive",
+		Long: `Package every tracked file, spirit.json and a manifest with a SHA-256
+per file, the source commit and the spirit version into a single
+.spirit archive (tar + zstd).
+
+The source defaults to ~/.spirit/ and may be any 'spirit migrate' location.
+The archive is not encrypted: store it as carefully as the state itself.
+
+Examples:
+  spirit export
+  spirit export -o orion.spirit
+  spirit export github:USER/agent-state -o orion.spirit`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source := "current"
+			if len(args) > 0 {
+				source = args[0]
+			}
+			output, _ := cmd.Flags().GetString("output")
+			return exportArchive(source, output)
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", "Archive to write (default <name>-<timestamp>.spirit)")
+	return cmd
+}
+
+func importCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <archive> [destination]",
+		Short: "Restore state from a .spirit archive",
+		Long: `Verify a .spirit archive and write its files to the destination, which
+defaults to ~/.spirit/ and may be any 'spirit migrate' location.
+
+Examples:
+  spirit import orion.spirit
+  spirit import orion.spirit ~/agents/orion
+  spirit import orion.spirit s3://my-bucket/orion`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dest := "current"
+			if len(args) > 1 {
+				dest = args[1]
+			}
+			force, _ := cmd.Flags().GetBool("force")
+			return withLock(cmd.CommandPath(), func() error {
+				return importArchive(args[0], dest, force)
+			})
+		},
+	}
+	cmd.Flags().Bool("force", false, "Overwrite an existing local state")
+	return cmd
+}
+
+func exportArchive(source, output string) error {
+	sourceType, sourcePath := parseLocation(source)
+	fmt.Printf("📦 Exporting %s (%s)...\n", sourceType, sourcePath)
+
+	pkg, err := exportFrom(sourceType, sourcePath)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	defer pkg.Close()
+	if err := validateExport(pkg); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	if output == "" {
+		output = fmt.Sprintf("%s-%s%s", pkg.Identity.Name, time.Now().Format("20060102-150405"), archiveExt)
+	}
+	if err := importTo("archive", output, pkg, false); err != nil {
+		return err
+	}
+
+	info, _ := os.Stat(output)
+	fmt.Printf("✅ Wrote %s\n", output)
+	fmt.Printf("   Files: %d\n", len(pkg.Files))
+	if pkg.Commit != "" {
+		fmt.Printf("   Commit: %s\n", pkg.Commit[:7])
+	}
+	if info != nil {
+		fmt.Printf("   Size: %s\n", formatSize(info.Size()))
+	}
+	return nil
+}
+
+func importArchive(archive, dest string, force bool) error {
+	destType, destPath := parseLocation(dest)
+	if destType == "local" && !force {
+		if _, err := os.Stat(filepath.Join(destPath, "spirit.json")); err == nil {
+			return fmt.Errorf("%s already holds a spirit state (use --force to overwrite)", destPath)
+		}
+	}
+
+	pkg, err := exportFrom("archive", archive)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", archive, err)
+	}
+	if err := validateExport(pkg); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	fmt.Printf("📥 Importing %s %s (%d files, exported %s by spirit %s)\n",
+		pkg.Identity.Emoji, pkg.Identity.Name, len(pkg.Files), pkg.ExportedAt, pkg.ToolVersion)
+	if err := importTo(destType, destPath, pkg, false); err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	fmt.Printf("✅ Imported into %s\n", destPath)
+	return nil
+}
+
+// writeArchive writes pkg and its contents as a .spirit archive.
+func writeArchive(w io.Writer, pkg *ExportPackage) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(zw)
+
+	manifest, err := json.MarshalIndent(pkg, "", "  ")
+	if err != nil {
+		return err
+	}
+	modTime := time.Now()
+	if t, err := time.Parse(time.RFC3339, pkg.ExportedAt); err == nil {
+		modTime = t
+	}
+
+	add := func(name string, content []byte, mode int64) error {
+		hdr := &tar.Header{Name: name, Mode: mode, Size: int64(len(content)), ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(content)
+		return err
+	}
+
+	if err := add(archiveManifest, manifest, 0644); err != nil {
+		return err
+	}
+	for _, f := range pkg.Files {
+		if err := add(archiveFilesDir+f.Path, pkg.contents[f.Path], 0600); err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// readArchive reads a .spirit archive. Archives from a newer format version
+// are refused rather than half understood.
+func readArchive(r io.Reader) (*ExportPackage, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	var pkg *ExportPackage
+	contents := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a .spirit archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case hdr.Name == archiveManifest:
+			pkg = &ExportPackage{}
+			if err := json.Unmarshal(data, pkg); err != nil {
+				return nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			if pkg.Version != archiveVersion {
+				return nil, fmt.Errorf("archive format %q is not supported by spirit %s (expected %q)", pkg.Version, Version, archiveVersion)
+			}
+		case strings.HasPrefix(hdr.Name, archiveFilesDir):
+			name := strings.TrimPrefix(hdr.Name, archiveFilesDir)
+			if name == "" || path.Clean(name) != name || strings.HasPrefix(name, "../") {
+				return nil, fmt.Errorf("invalid path in archive: %s", hdr.Name)
+			}
+			contents[name] = data
+		}
+	}
+
+	if pkg == nil {
+		return nil, fmt.Errorf("not a .spirit archive: no %s", archiveManifest)
+	}
+	pkg.contents = contents
+	return pkg, nil
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportTestArchive exports the state repo into an archive and returns its
+// path with the package it was written from.
+func exportTestArchive(t *testing.T) (string, *ExportPackage) {
+	t.Helper()
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+	archive := filepath.Join(t.TempDir(), "orion"+archiveExt)
+	if err := exportArchive(dir, archive); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := exportFrom("local", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive, pkg
+}
+
+// writeTestArchive writes pkg as an archive after changing it.
+func writeTestArchive(t *testing.T, pkg *ExportPackage, change func(pkg *ExportPackage)) string {
+	t.Helper()
+	change(pkg)
+	var buf bytes.Buffer
+	if err := writeArchive(&buf, pkg); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "changed"+archiveExt)
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	archive, pkg := exportTestArchive(t)
+	dest := filepath.Join(t.TempDir(), "orion")
+	if err := importArchive(archive, dest, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pkg.Files) == 0 {
+		t.Fatal("nothing exported")
+	}
+	for _, f := range pkg.Files {
+		if got := readTestFile(t, filepath.Join(dest, f.Path)); got != string(pkg.contents[f.Path]) {
+			t.Errorf("%s = %q, want %q", f.Path, got, pkg.contents[f.Path])
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dest, "spirit.json")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("spirit.json imported with %v, %v; want mode 0600", info, err)
+	}
+
+	// An existing state is only replaced with --force
+	if err := importArchive(archive, dest, false); err == nil {
+		t.Error("import over an existing state succeeded without --force")
+	}
+	if err := importArchive(archive, dest, true); err != nil {
+		t.Errorf("import --force = %v", err)
+	}
+}
+
+func TestArchiveDamaged(t *testing.T) {
+	archive, pkg := exportTestArchive(t)
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	damaged := map[string][]byte{
+		"truncated": data[:len(data)/2],
+		"garbage":   []byte("not an archive"),
+	}
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)/2] ^= 0xff
+	damaged["flipped byte"] = flipped
+
+	for name, content := range damaged {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "damaged"+archiveExt)
+			if err := os.WriteFile(path, content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			dest := filepath.Join(t.TempDir(), "orion")
+			if err := importArchive(path, dest, false); err == nil {
+				t.Fatal("damaged archive imported")
+			}
+			if exists(filepath.Join(dest, "spirit.json")) {
+				t.Error("damaged archive wrote files")
+			}
+		})
+	}
+
+	t.Run("checksum", func(t *testing.T) {
+		path := writeTestArchive(t, pkg, func(pkg *ExportPackage) {
+			pkg.contents["IDENTITY.md"] = []byte("- **Name:** mallory\n")
+		})
+		if err := importArchive(path, filepath.Join(t.TempDir(), "orion"), false); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("import = %v, want a checksum mismatch", err)
+		}
+	})
+}
+
+func TestArchiveNewerVersion(t *testing.T) {
+	_, pkg := exportTestArchive(t)
+
+	path := writeTestArchive(t, pkg, func(pkg *ExportPackage) { pkg.Version = "2" })
+	if err := importArchive(path, filepath.Join(t.TempDir(), "orion"), false); err == nil || !strings.Contains(err.Error(), `archive format "2"`) {
+		t.Errorf("import = %v, want format 2 refused", err)
+	}
+	pkg.Version = archiveVersion
+	if err := validateExport(pkg); err != nil {
+		t.Fatal(err)
+	}
+
+	// A spirit.json from a newer spirit is refused before anything is written
+	path = writeTestArchive(t, pkg, func(pkg *ExportPackage) {
+		config := bytes.Replace(pkg.contents["spirit.json"], []byte(`"version": "1.2.0"`), []byte(`"version": "9.0.0"`), 1)
+		pkg.contents["spirit.json"] = config
+		for i, f := range pkg.Files {
+			if f.Path == "spirit.json" {
+				pkg.Files[i].SHA256 = sha256Hex(config)
+				pkg.Files[i].Size = int64(len(config))
+			}
+		}
+	})
+	dest := filepath.Join(t.TempDir(), "orion")
+	var newer *NewerVersionError
+	if err := importArchive(path, dest, false); !errors.As(err, &newer) {
+		t.Errorf("import = %v, want NewerVersionError", err)
+	}
+	if exists(dest) {
+		t.Error("newer archive wrote files")
+	}
+}
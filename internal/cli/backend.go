@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backend is a place where spirit state is preserved. Every entry in the
+// "backends" section of spirit.json is turned into a Backend through the
+// registry, keyed by its Type.
+type Backend interface {
+	// Name is the key of the backend in spirit.json.
+	Name() string
+	// Push publishes the tracked state of dir as a new snapshot.
+	Push(dir string) (*Snapshot, error)
+	// Pull brings dir up to date with the latest state held by the backend.
+	Pull(dir string) error
+	// List returns the snapshots held by the backend, newest first.
+	List() ([]Snapshot, error)
+	// Fetch writes the files of a single snapshot into dir.
+	Fetch(id, dir string) error
+	// Health reports whether the backend is reachable and usable.
+	Health() error
+}
+
+// Snapshot describes one preserved copy of the tracked state.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Message   string    `json:"message,omitempty"`
+	Files     int       `json:"files,omitempty"`
+}
+
+type backendFactory func(name string, config BackendConfig) (Backend, error)
+
+var backendRegistry = map[string]backendFactory{}
+
+// sourceBackendTypes are spirit.json entries that describe where state is
+// read from rather than where it is preserved.
+var sourceBackendTypes = map[string]bool{
+	"workspace": true,
+}
+
+func registerBackend(backendType string, factory backendFactory) {
+	backendRegistry[backendType] = factory
+}
+
+func newBackend(name string, config BackendConfig) (Backend, error) {
+	factory, ok := backendRegistry[config.Type]
+	if !ok {
+		return nil, fmt.Errorf("backend %q: unknown type %q", name, config.Type)
+	}
+	if config.Config == nil {
+		config.Config = map[string]string{}
+	}
+	return factory(name, config)
+}
+
+// loadBackends builds every enabled backend configured in spirit.json, in
+// name order. Without any configured backend, the git remote "origin" of
+// ConfigDir is used, as spirit always has.
+func loadBackends() ([]Backend, error) {
+	config, err := loadConfig()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no configuration found, run 'spirit init' first")
+		}
+		return nil, err
+	}
+	return backendsFor(config)
+}
+
+// backendsFor builds the enabled backends of config, as loadBackends does.
+func backendsFor(config *Config) ([]Backend, error) {
+	names := []string{}
+	for name, bc := range config.Backends {
+		if !sourceBackendTypes[bc.Type] && !bc.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		b, err := newBackend("origin", BackendConfig{Type: "git"})
+		if err != nil {
+			return nil, err
+		}
+		return []Backend{b}, nil
+	}
+
+	backends := []Backend{}
+	for _, name := range names {
+		b, err := newBackend(name, config.Backends[name])
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, b)
+	}
+	return backends, nil
+}
+
+// backendForLocation builds an ad-hoc backend for a migrate location such
+// as "github:owner/repo".
+func backendForLocation(locType, path string) (Backend, error) {
+	config := BackendConfig{Type: locType, Config: map[string]string{}}
+	switch locType {
+	case "github", "gitlab":
+		config.Config["repo"] = path
+	case "git":
+		config.Config["url"] = path
+	case "s3":
+		bucket, prefix, _ := strings.Cut(path, "/")
+		config.Config["bucket"] = bucket
+		config.Config["prefix"] = prefix
+		if os.Getenv("SPIRIT_S3_PATH_STYLE") != "" {
+			config.Config["path_style"] = "true"
+		}
+	default:
+		config.Config["path"] = path
+	}
+	return newBackend(locType, config)
+}
@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerBackend("dir", newDirBackend)
+}
+
+// defaultMirrorKeep is how many snapshots a dir backend keeps by default.
+const defaultMirrorKeep = 10
+
+// dirBackend mirrors snapshots into another directory, such as a USB stick,
+// an NFS mount or a second disk:
+//
+//	<path>/snapshots/<id>.json     manifest per snapshot
+//	<path>/blobs/<ab>/<sha256>     file contents, shared between snapshots
+//
+// Every file is written aside and renamed into place, and the manifest
+// goes last, so an interrupted push or a pulled drive never leaves a
+// half-written snapshot. Encryption works as for the s3 backend. Only the
+// newest "keep" snapshots are kept, along with the blobs they use.
+//
+// The directory must already exist: a drive that is not mounted is
+// reported instead of filling its empty mount point.
+type dirBackend struct {
+	name string
+	path string
+	keep int
+}
+
+func newDirBackend(name string, config BackendConfig) (Backend, error) {
+	path := config.Config["path"]
+	if path == "" {
+		return nil, fmt.Errorf("backend %s: dir path not configured", name)
+	}
+	if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(homeDir(), path[2:])
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %w", name, err)
+	}
+
+	keep := defaultMirrorKeep
+	if value := config.Config["keep"]; value != "" {
+		if keep, err = strconv.Atoi(value); err != nil || keep < 1 {
+			return nil, fmt.Errorf("backend %s: keep must be a positive number, not %q", name, value)
+		}
+	}
+	return &dirBackend{name: name, path: abs, keep: keep}, nil
+}
+
+func (b *dirBackend) Name() string { return b.name }
+
+func (b *dirBackend) Push(dir string) (*Snapshot, error) {
+	if err := b.Health(); err != nil {
+		return nil, err
+	}
+	manifest, err := buildManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := loadEncryptionConfig()
+	if err != nil {
+		return nil, err
+	}
+	var nameKey []byte
+	if encryption.Enabled {
+		if nameKey, err = blobKey(encryption); err != nil {
+			return nil, err
+		}
+	}
+
+	// Nothing changed since the last snapshot: keep the history for changes
+	if latest, err := b.latest(); err == nil && sameFiles(latest.Files, manifest.Files) {
+		snapshot := latest.snapshot()
+		return &snapshot, nil
+	}
+
+	for i, f := range manifest.Files {
+		if encryption.Enabled {
+			manifest.Files[i].Blob = blobName(nameKey, f.SHA256)
+		}
+		target := b.blobPath(manifest.Files[i].blob())
+		if _, err := os.Stat(target); err == nil {
+			continue // content already stored by an earlier snapshot
+		}
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return nil, err
+		}
+		if sha256Hex(content) != f.SHA256 {
+			return nil, fmt.Errorf("%s changed while it was mirrored; sync again", f.Path)
+		}
+		if encryption.Enabled {
+			if content, err = encryptContent(encryption, content); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeFileAtomic(target, content); err != nil {
+			return nil, fmt.Errorf("write %s: %w", f.Path, err)
+		}
+	}
+
+	// The manifest goes last so a snapshot only appears once it is complete
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if encryption.Enabled {
+		if data, err = encryptContent(encryption, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeFileAtomic(b.manifestPath(manifest.ID), data); err != nil {
+		return nil, fmt.Errorf("write manifest: %w", err)
+	}
+
+	if err := b.prune(); err != nil {
+		return nil, fmt.Errorf("prune old snapshots: %w", err)
+	}
+	snapshot := manifest.snapshot()
+	return &snapshot, nil
+}
+
+func (b *dirBackend) Pull(dir string) error {
+	latest, err := b.latest()
+	if err != nil {
+		return err
+	}
+	return b.Fetch(latest.ID, dir)
+}
+
+func (b *dirBackend) List() ([]Snapshot, error) {
+	manifests, _, err := b.manifests()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []Snapshot{}
+	for _, m := range manifests {
+		snapshots = append(snapshots, m.snapshot())
+	}
+	return snapshots, nil
+}
+
+func (b *dirBackend) Fetch(id, dir string) error {
+	manifest, err := b.manifest(id)
+	if err != nil {
+		return err
+	}
+	for _, f := range manifest.Files {
+		content, err := os.ReadFile(b.blobPath(f.blob()))
+		if err != nil {
+			return fmt.Errorf("read %s: %w", f.Path, err)
+		}
+		if err := writeManifestFile(dir, f, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *dirBackend) Health() error {
+	info, err := os.Stat(b.path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("mirror directory %s not found (is the drive mounted? create it to start mirroring)", b.path)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("mirror path %s is not a directory", b.path)
+	}
+	return nil
+}
+
+func (b *dirBackend) blobPath(name string) string {
+	return filepath.Join(b.path, "blobs", name[:2], name)
+}
+
+func (b *dirBackend) manifestPath(id string) string {
+	return filepath.Join(b.path, "snapshots", id+".json")
+}
+
+func (b *dirBackend) manifest(id string) (*snapshotManifest, error) {
+	data, err := os.ReadFile(b.manifestPath(filepath.Base(id)))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	if data, err = decryptContent(data); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	var manifest snapshotManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	return &manifest, nil
+}
+
+// manifests reads every snapshot manifest, newest first. Manifests that
+// cannot be read are skipped with a warning and counted in corrupt.
+func (b *dirBackend) manifests() (manifests []*snapshotManifest, corrupt int, err error) {
+	if err := b.Health(); err != nil {
+		return nil, 0, err
+	}
+	files, err := filepath.Glob(filepath.Join(b.path, "snapshots", "*.json"))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	manifests = []*snapshotManifest{}
+	for _, f := range files {
+		manifest, err := b.manifest(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping %s: %v\n", b.name, err)
+			corrupt++
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return newerSnapshot(manifests[i].snapshot(), manifests[j].snapshot())
+	})
+	return manifests, corrupt, nil
+}
+
+func (b *dirBackend) latest() (*snapshotManifest, error) {
+	manifests, _, err := b.manifests()
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no snapshots in %s", b.path)
+	}
+	return manifests[0], nil
+}
+
+// prune removes the snapshots beyond the newest b.keep, then every blob no
+// remaining snapshot refers to. Blobs are left alone while any manifest is
+// unreadable, as they may belong to it.
+func (b *dirBackend) prune() error {
+	manifests, corrupt, err := b.manifests()
+	if err != nil {
+		return err
+	}
+	if len(manifests) <= b.keep {
+		return nil
+	}
+	for _, m := range manifests[b.keep:] {
+		if err := os.Remove(b.manifestPath(m.ID)); err != nil {
+			return err
+		}
+	}
+	if corrupt > 0 {
+		return nil
+	}
+
+	used := map[string]bool{}
+	for _, m := range manifests[:b.keep] {
+		for _, f := range m.Files {
+			used[f.blob()] = true
+		}
+	}
+	blobs, err := filepath.Glob(filepath.Join(b.path, "blobs", "*", "*"))
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		if !used[filepath.Base(blob)] {
+			if err := os.Remove(blob); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sameFiles reports whether two manifests list the same contents.
+func sameFiles(a, b []manifestFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Path != b[i].Path || a[i].SHA256 != b[i].SHA256 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileAtomic writes data next to path, flushes it to the device and
+// renames it into place, so path is either absent or complete.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := bytes.NewReader(data).WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirSnapshotsWithinOneSecond(t *testing.T) {
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+	backend, err := newBackend("usb", BackendConfig{Type: "dir", Config: map[string]string{"path": t.TempDir(), "keep": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.(*dirBackend)
+
+	// Pushed back to back, the IDs share a second and only differ by commit
+	var pushed []*Snapshot
+	for _, day := range []string{"third", "fourth", "fifth"} {
+		if err := os.WriteFile(filepath.Join(dir, "memory/2026-01.md"), []byte("- "+day+" day\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, dir, "commit", "--quiet", "-am", day)
+		snapshot, err := b.Push(dir)
+		if err != nil {
+			t.Fatalf("push %s: %v", day, err)
+		}
+		pushed = append(pushed, snapshot)
+	}
+
+	snapshots, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != pushed[2].ID || snapshots[1].ID != pushed[1].ID {
+		t.Fatalf("list = %+v, want %s then %s", snapshots, pushed[2].ID, pushed[1].ID)
+	}
+
+	pulled := t.TempDir()
+	if err := b.Pull(pulled); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(pulled, "memory/2026-01.md")); got != "- fifth day\n" {
+		t.Errorf("pulled memory = %q, want the fifth day", got)
+	}
+}
+
+func TestDirSkipsCorruptSnapshots(t *testing.T) {
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+	mirror := t.TempDir()
+	backend, err := newBackend("usb", BackendConfig{Type: "dir", Config: map[string]string{"path": mirror, "keep": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.(*dirBackend)
+	pushed, err := b.Push(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blob names that would panic or escape blobs/ are refused
+	for id, blob := range map[string]string{"short": "ab", "escape": "../../../../etc/passwd"} {
+		manifest := `{"id":"` + id + `","created_at":"2020-01-01T00:00:00Z","files":[{"path":"IDENTITY.md","sha256":"` + blob + `"}]}`
+		if err := os.WriteFile(b.manifestPath(id), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Fetch(id, t.TempDir()); err == nil {
+			t.Errorf("fetched snapshot with blob %q", blob)
+		}
+	}
+	if err := os.WriteFile(b.manifestPath("garbage"), []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 || snapshots[0].ID != pushed.ID {
+		t.Errorf("list = %+v, want only %s", snapshots, pushed.ID)
+	}
+	if err := b.Pull(t.TempDir()); err != nil {
+		t.Errorf("pull = %v", err)
+	}
+
+	// Pruning with unreadable manifests keeps every blob
+	if err := os.WriteFile(filepath.Join(dir, "memory/2026-01.md"), []byte("- third day\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "commit", "--quiet", "-am", "third")
+	blobs, _ := filepath.Glob(filepath.Join(mirror, "blobs", "*", "*"))
+	if _, err := b.Push(dir); err != nil {
+		t.Fatal(err)
+	}
+	if kept, _ := filepath.Glob(filepath.Join(mirror, "blobs", "*", "*")); len(kept) <= len(blobs) {
+		t.Errorf("%d blobs after pruning, want all %d and the new one", len(kept), len(blobs))
+	}
+	if exists(b.manifestPath(pushed.ID)) {
+		t.Error("the old snapshot was not pruned")
+	}
+}
@@ -0,0 +1,290 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerBackend("git", newGitBackend)
+	registerBackend("github", newGitBackend)
+	registerBackend("gitlab", newGitBackend)
+}
+
+// gitBackend preserves state by pushing the ConfigDir repository to a git
+// remote. The remote is taken from "url" or "repo" in the backend config,
+// or must already exist in the repository. The branch is taken from
+// "branch", or follows the remote's HEAD.
+type gitBackend struct {
+	name   string
+	remote string
+	url    string
+	branch string
+}
+
+func newGitBackend(name string, config BackendConfig) (Backend, error) {
+	b := &gitBackend{
+		name:   name,
+		remote: config.Config["remote"],
+		url:    config.Config["url"],
+		branch: config.Config["branch"],
+	}
+	if b.url == "" && config.Config["repo"] != "" {
+		b.url = hostedRepoURL(config.Type, config.Config["repo"])
+	}
+	if b.remote == "" {
+		b.remote = "origin"
+		if b.url != "" && name != "" {
+			b.remote = name
+		}
+	}
+	return b, nil
+}
+
+// hostedRepoURL expands "owner/repo" into a clone URL for the hosting type.
+func hostedRepoURL(backendType, repo string) string {
+	repo = strings.TrimPrefix(strings.TrimPrefix(repo, "github:"), "gitlab:")
+	if strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@") || filepath.IsAbs(repo) {
+		return repo
+	}
+	host := "github.com"
+	if backendType == "gitlab" {
+		host = "gitlab.com"
+	}
+	return fmt.Sprintf("https://%s/%s.git", host, strings.TrimSuffix(repo, ".git"))
+}
+
+// repoMu guards the ConfigDir repository the git backends share. Setting
+// up remotes and pulling, which rebases the worktree, hold it exclusively;
+// pushes, which need a HEAD that is not mid-rebase, share it; fetches only
+// add objects and remote refs and run without it.
+var repoMu sync.RWMutex
+
+func (b *gitBackend) Name() string { return b.name }
+
+func (b *gitBackend) Push(dir string) (*Snapshot, error) {
+	branch, err := b.pull(dir)
+	if err != nil {
+		return nil, err
+	}
+	return b.pushBranch(dir, branch)
+}
+
+// pushPulled pushes dir without pulling it first, for callers that have
+// already brought it up to date with Pull.
+func (b *gitBackend) pushPulled(dir string) (*Snapshot, error) {
+	git, err := activeGit()
+	if err != nil {
+		return nil, err
+	}
+	branch, err := resolveBranch(git, dir, b.remote, b.branch)
+	if err != nil {
+		return nil, err
+	}
+	return b.pushBranch(dir, branch)
+}
+
+func (b *gitBackend) pushBranch(dir, branch string) (*Snapshot, error) {
+	repoMu.RLock()
+	defer repoMu.RUnlock()
+	if err := gitPush(dir, b.remote, branch); err != nil {
+		return nil, err
+	}
+	git, err := activeGit()
+	if err != nil {
+		return nil, err
+	}
+	head, err := git.Head(dir)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, _ := git.LastCommitTime(dir)
+	return &Snapshot{ID: head, CreatedAt: createdAt}, nil
+}
+
+func (b *gitBackend) Pull(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if b.url == "" {
+			return fmt.Errorf("backend %s: no url to clone from", b.name)
+		}
+		if err := requireGitBinary("git clone"); err != nil {
+			return fmt.Errorf("backend %s: %w", b.name, err)
+		}
+		args := []string{"clone", "--origin", b.remote, b.url, dir}
+		if b.branch != "" {
+			args = append(args, "--branch", b.branch)
+		}
+		cmd := exec.Command("git", args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git clone failed: %s", strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	_, err := b.pull(dir)
+	return err
+}
+
+// pull fetches the remote into dir and rebases onto the remote branch,
+// which it returns.
+func (b *gitBackend) pull(dir string) (string, error) {
+	repoMu.Lock()
+	err := b.ensureRemote(dir)
+	repoMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	if err := gitFetch(dir, b.remote); err != nil {
+		return "", err
+	}
+	git, err := activeGit()
+	if err != nil {
+		return "", err
+	}
+	branch, err := resolveBranch(git, dir, b.remote, b.branch)
+	if err != nil {
+		return "", err
+	}
+
+	repoMu.Lock()
+	defer repoMu.Unlock()
+	if err := gitPull(dir, b.remote, branch); err != nil {
+		return "", err
+	}
+	return branch, nil
+}
+
+func (b *gitBackend) List() ([]Snapshot, error) {
+	if err := gitFetch(ConfigDir, b.remote); err != nil {
+		return nil, err
+	}
+	ref, err := b.remoteRef()
+	if err != nil {
+		return nil, err
+	}
+	git, err := activeGit()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := git.Log(ConfigDir, ref, time.Time{}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, c := range commits {
+		snapshots = append(snapshots, Snapshot{ID: c.Hash, CreatedAt: c.Time, Message: c.Subject()})
+	}
+	return snapshots, nil
+}
+
+func (b *gitBackend) Fetch(id, dir string) error {
+	if err := gitFetch(ConfigDir, b.remote); err != nil {
+		return err
+	}
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	commit, err := git.ResolveCommit(ConfigDir, id)
+	if err != nil {
+		return err
+	}
+	files, err := git.Files(ConfigDir, commit)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		target := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in %s: %s", id, f.Path)
+		}
+		content, err := git.ReadFile(ConfigDir, commit, f.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return err
+		}
+	}
+	return decryptTree(dir)
+}
+
+func (b *gitBackend) Health() error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	target := b.url
+	if target == "" {
+		if _, err := git.RemoteURL(ConfigDir, b.remote); err != nil {
+			return fmt.Errorf("no remote %q configured", b.remote)
+		}
+		target = b.remote
+	}
+	if _, err := git.ListBranches(ConfigDir, target); err != nil {
+		return fmt.Errorf("remote unreachable: %w", err)
+	}
+	return nil
+}
+
+// ensureRemote makes sure the backend's remote exists in dir and points at
+// the configured url.
+func (b *gitBackend) ensureRemote(dir string) error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	current, err := git.RemoteURL(dir, b.remote)
+	switch {
+	case err != nil && b.url == "":
+		return fmt.Errorf("no remote %q configured", b.remote)
+	case err != nil, b.url != "" && current != b.url:
+		return git.SetRemote(dir, b.remote, b.url)
+	}
+	return nil
+}
+
+// remoteRef returns the remote-tracking branch holding the pushed history.
+func (b *gitBackend) remoteRef() (string, error) {
+	git, err := activeGit()
+	if err != nil {
+		return "", err
+	}
+	branch, err := resolveBranch(git, ConfigDir, b.remote, b.branch)
+	if err != nil {
+		return "", err
+	}
+	ref := "refs/remotes/" + b.remote + "/" + branch
+	if _, err := git.ResolveCommit(ConfigDir, ref); errors.Is(err, ErrUnknownRevision) {
+		return "", fmt.Errorf("remote %q has no branch %s", b.remote, branch)
+	} else if err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+// Tracking fetches the remote and compares the current branch with the
+// remote branch.
+func (b *gitBackend) Tracking() (branch string, ahead, behind int, err error) {
+	if err := gitFetch(ConfigDir, b.remote); err != nil {
+		return "", 0, 0, err
+	}
+	git, err := activeGit()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if branch, err = resolveBranch(git, ConfigDir, b.remote, b.branch); err != nil {
+		return "", 0, 0, err
+	}
+	ahead, behind, err = git.AheadBehind(ConfigDir, b.remote, branch)
+	return branch, ahead, behind, err
+}
@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func init() {
+	registerBackend("s3", newS3Backend)
+}
+
+// s3Backend stores snapshots in any S3-compatible object store:
+//
+//	<prefix>/snapshots/<id>.json   manifest per snapshot
+//	<prefix>/blobs/<sha256>        file contents, shared between snapshots
+//
+// With encryption enabled, blobs and manifests are age ciphertext and blobs
+// are named by a keyed hash so the store does not learn content hashes.
+//
+// Credentials come from the environment (AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, MINIO_ROOT_USER/PASSWORD),
+// ~/.aws/credentials, or instance metadata, in that order.
+type s3Backend struct {
+	name   string
+	bucket string
+	prefix string
+	client *minio.Client
+}
+
+func newS3Backend(name string, config BackendConfig) (Backend, error) {
+	bucket := config.Config["bucket"]
+	if bucket == "" {
+		return nil, fmt.Errorf("backend %s: s3 bucket not configured", name)
+	}
+
+	endpoint := config.Config["endpoint"]
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	secure := true
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		secure = u.Scheme != "http"
+		endpoint = u.Host
+	}
+
+	region := config.Config["region"]
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+
+	lookup := minio.BucketLookupAuto
+	if config.Config["path_style"] == "true" {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		}),
+		Secure:       secure,
+		Region:       region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %w", name, err)
+	}
+
+	return &s3Backend{
+		name:   name,
+		bucket: bucket,
+		prefix: strings.Trim(config.Config["prefix"], "/"),
+		client: client,
+	}, nil
+}
+
+func (b *s3Backend) Name() string { return b.name }
+
+func (b *s3Backend) key(parts ...string) string {
+	return path.Join(append([]string{b.prefix}, parts...)...)
+}
+
+func (b *s3Backend) Push(dir string) (*Snapshot, error) {
+	ctx := context.Background()
+	manifest, err := buildManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := loadEncryptionConfig()
+	if err != nil {
+		return nil, err
+	}
+	var nameKey []byte
+	if encryption.Enabled {
+		if nameKey, err = blobKey(encryption); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, f := range manifest.Files {
+		if encryption.Enabled {
+			manifest.Files[i].Blob = blobName(nameKey, f.SHA256)
+		}
+		key := b.key("blobs", manifest.Files[i].blob())
+		if _, err := b.client.StatObject(ctx, b.bucket, key, minio.StatObjectOptions{}); err == nil {
+			continue // content already stored by an earlier snapshot
+		}
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return nil, err
+		}
+		if encryption.Enabled {
+			if content, err = encryptContent(encryption, content); err != nil {
+				return nil, err
+			}
+		}
+		if err := b.put(ctx, key, content, "application/octet-stream"); err != nil {
+			return nil, fmt.Errorf("upload %s: %w", f.Path, err)
+		}
+	}
+
+	// The manifest goes last so a snapshot only appears once it is complete
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if encryption.Enabled {
+		if data, err = encryptContent(encryption, data); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.put(ctx, b.key("snapshots", manifest.ID+".json"), data, "application/json"); err != nil {
+		return nil, fmt.Errorf("upload manifest: %w", err)
+	}
+
+	snapshot := manifest.snapshot()
+	return &snapshot, nil
+}
+
+func (b *s3Backend) Pull(dir string) error {
+	snapshots, err := b.List()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots in s3://%s/%s", b.bucket, b.prefix)
+	}
+	return b.Fetch(snapshots[0].ID, dir)
+}
+
+func (b *s3Backend) List() ([]Snapshot, error) {
+	ctx := context.Background()
+	snapshots := []Snapshot{}
+	for obj := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: b.key("snapshots") + "/"}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if !strings.HasSuffix(obj.Key, ".json") {
+			continue
+		}
+		manifest, err := b.manifest(ctx, strings.TrimSuffix(path.Base(obj.Key), ".json"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping %s: %v\n", b.name, err)
+			continue
+		}
+		snapshots = append(snapshots, manifest.snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return newerSnapshot(snapshots[i], snapshots[j]) })
+	return snapshots, nil
+}
+
+func (b *s3Backend) Fetch(id, dir string) error {
+	ctx := context.Background()
+	manifest, err := b.manifest(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, f := range manifest.Files {
+		content, err := b.get(ctx, b.key("blobs", f.blob()))
+		if err != nil {
+			return fmt.Errorf("download %s: %w", f.Path, err)
+		}
+		if err := writeManifestFile(dir, f, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *s3Backend) Health() error {
+	exists, err := b.client.BucketExists(context.Background(), b.bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %q does not exist", b.bucket)
+	}
+	return nil
+}
+
+func (b *s3Backend) manifest(ctx context.Context, id string) (*snapshotManifest, error) {
+	data, err := b.get(ctx, b.key("snapshots", id+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	if data, err = decryptContent(data); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	var manifest snapshotManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", id, err)
+	}
+	return &manifest, nil
+}
+
+func (b *s3Backend) put(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := b.client.PutObject(ctx, b.bucket, key, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (b *s3Backend) get(ctx context.Context, key string) ([]byte, error) {
+	obj, err := b.client.GetObject(ctx, b.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
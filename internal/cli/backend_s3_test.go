@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// newTestS3Backend returns an s3 backend on the MinIO (or other
+// S3-compatible) server named by SPIRIT_TEST_S3_ENDPOINT, e.g.
+//
+//	MINIO_ROOT_USER=minioadmin MINIO_ROOT_PASSWORD=minioadmin \
+//	SPIRIT_TEST_S3_ENDPOINT=http://127.0.0.1:9000 go test ./internal/cli -run S3
+//
+// The bucket (SPIRIT_TEST_S3_BUCKET, default spirit-test) is created when
+// missing and everything under the test's prefix is removed afterwards.
+func newTestS3Backend(t *testing.T) *s3Backend {
+	t.Helper()
+	endpoint := os.Getenv("SPIRIT_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("SPIRIT_TEST_S3_ENDPOINT not set")
+	}
+	bucket := os.Getenv("SPIRIT_TEST_S3_BUCKET")
+	if bucket == "" {
+		bucket = "spirit-test"
+	}
+
+	backend, err := newBackend("minio", BackendConfig{Type: "s3", Config: map[string]string{
+		"endpoint":   endpoint,
+		"bucket":     bucket,
+		"prefix":     fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano()),
+		"region":     "us-east-1",
+		"path_style": "true",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := backend.(*s3Backend)
+
+	ctx := context.Background()
+	exists, err := b.client.BucketExists(ctx, bucket)
+	if err != nil {
+		t.Fatalf("reach %s: %v", endpoint, err)
+	}
+	if !exists {
+		if err := b.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: "us-east-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for obj := range b.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: b.prefix + "/", Recursive: true}) {
+			if obj.Err == nil {
+				b.client.RemoveObject(ctx, bucket, obj.Key, minio.RemoveObjectOptions{})
+			}
+		}
+	})
+	return b
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestS3PushListFetchPull(t *testing.T) {
+	withGitEnv(t)
+	b := newTestS3Backend(t)
+	state := newStateRepo(t, "main")
+
+	if err := b.Health(); err != nil {
+		t.Fatalf("health: %v", err)
+	}
+	first, err := b.Push(state)
+	if err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+
+	memory := filepath.Join(state, "memory/2026-01.md")
+	if err := os.WriteFile(memory, []byte("- first day\n- second day\n- third day\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, state, "commit", "--quiet", "-am", "third")
+	second, err := b.Push(state)
+	if err != nil {
+		t.Fatalf("second push: %v", err)
+	}
+
+	snapshots, err := b.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != second.ID || snapshots[1].ID != first.ID {
+		t.Fatalf("list = %+v, want %s then %s", snapshots, second.ID, first.ID)
+	}
+	if snapshots[0].Message != "third" || snapshots[0].Files != 4 {
+		t.Errorf("latest snapshot = %+v, want message third and 4 files", snapshots[0])
+	}
+
+	fetched := t.TempDir()
+	if err := b.Fetch(first.ID, fetched); err != nil {
+		t.Fatalf("fetch %s: %v", first.ID, err)
+	}
+	if got := readTestFile(t, filepath.Join(fetched, "memory/2026-01.md")); got != "- first day\n- second day\n" {
+		t.Errorf("fetched memory = %q", got)
+	}
+
+	pulled := t.TempDir()
+	if err := b.Pull(pulled); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(pulled, "memory/2026-01.md")); got != "- first day\n- second day\n- third day\n" {
+		t.Errorf("pulled memory = %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(pulled, "IDENTITY.md")); got != "- **Name:** orion\n" {
+		t.Errorf("pulled identity = %q", got)
+	}
+}
+
+func TestS3FetchUnknownSnapshot(t *testing.T) {
+	withGitEnv(t)
+	b := newTestS3Backend(t)
+
+	if err := b.Fetch("20260101T000000Z", t.TempDir()); err == nil {
+		t.Error("fetch of a missing snapshot succeeded")
+	}
+	if err := b.Pull(t.TempDir()); err == nil {
+		t.Error("pull from an empty prefix succeeded")
+	}
+}
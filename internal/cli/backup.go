@@ -4,47 +4,113 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func backupCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "backup",
 		Short: "Manually trigger state backup",
-		Long:  `Create a checkpoint and sync to all configured backends.`,
+		Long: `Create a checkpoint and sync to all configured backends.
+
+With --session-end, back up only when 'spirit autobackup --on-session-end'
+is enabled. Call it from the agent's session-end or shutdown hook.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			message, _ := cmd.Flags().GetString("message")
-			return backupSpirit(message)
+			sessionEnd, _ := cmd.Flags().GetBool("session-end")
+			if sessionEnd {
+				config, err := loadAutoBackupConfig()
+				if err != nil {
+					return err
+				}
+				if !config.Enabled || !config.OnSessionEnd {
+					fmt.Println("🚪 Session-end backups are off (spirit autobackup --on-session-end)")
+					return nil
+				}
+				if message == "" {
+					message = fmt.Sprintf("Session end at %s", time.Now().Format("2006-01-02 15:04"))
+				}
+			}
+			return withLock(cmd.CommandPath(), func() error {
+				return backupSpirit(message)
+			})
 		},
 	}
 
 	cmd.Flags().StringP("message", "m", "", "Backup message/description")
+	cmd.Flags().Bool("session-end", false, "Back up if session-end backups are enabled (for agent shutdown hooks)")
 
 	return cmd
 }
 
 func autoBackupCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "autobackup",
 		Short: "Configure automatic backups",
 		Long: `Set up automatic state preservation.
 
+Without flags, shows the current auto-backup status.
+
 Examples:
   spirit autobackup --interval=15m     # Backup every 15 minutes
   spirit autobackup --on-session-end     # Backup when session ends
   spirit autobackup --watch             # Watch for changes and backup
-  spirit autobackup --disable           # Disable auto-backup`,
+  spirit autobackup --status            # Show schedule and last result
+  spirit autobackup --disable           # Disable auto-backup
+
+  spirit autobackup --interval=15m --install=systemd   # Run via systemd user timer
+  spirit autobackup --interval=1h --install=cron       # Run via crontab
+  spirit autobackup --verify                           # Check the installed schedule
+  spirit autobackup --uninstall                        # Remove timer/crontab entry`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			status, _ := cmd.Flags().GetBool("status")
+			verify, _ := cmd.Flags().GetBool("verify")
+			switch {
+			case verify:
+				return verifyAutoBackup()
+			case status || !localFlagsChanged(cmd):
+				return showAutoBackupStatus()
+			}
 			return configureAutoBackup(cmd)
 		},
 	}
+
+	cmd.Flags().String("interval", "", "Backup interval, e.g. 15m or 1h (minimum 1m)")
+	cmd.Flags().Bool("on-session-end", false, "Backup when the agent session ends")
+	cmd.Flags().Bool("watch", false, "Watch tracked files and backup on change")
+	cmd.Flags().Bool("disable", false, "Disable auto-backup")
+	cmd.Flags().Bool("status", false, "Show auto-backup status")
+	cmd.Flags().String("install", "", "Install a scheduler for --interval: systemd or cron")
+	cmd.Flags().Bool("uninstall", false, "Remove the installed systemd timer or crontab entry")
+	cmd.Flags().Bool("verify", false, "Verify the installed scheduler")
+
+	return cmd
+}
+
+// localFlagsChanged reports whether any of the command's own flags was
+// given. Inherited flags such as --agent or --config-dir don't count.
+func localFlagsChanged(cmd *cobra.Command) bool {
+	changed := false
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		changed = changed || f.Changed
+	})
+	return changed
 }
 
 func backupSpirit(message string) error {
+	err := runBackup(message)
+	if recordErr := recordBackupResult(err); recordErr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Could not update autobackup.json: %v\n", recordErr)
+	}
+	return err
+}
+
+func runBackup(message string) error {
 	if message == "" {
 		message = fmt.Sprintf("Backup at %s", time.Now().Format("2006-01-02 15:04"))
 	}
@@ -53,7 +119,7 @@ func backupSpirit(message string) error {
 
 	// 1. Create checkpoint
 	fmt.Println("📸 Creating checkpoint...")
-	if err := createCheckpoint(message); err != nil {
+	if err := createCheckpointAs(kindBackup, message); err != nil {
 		return fmt.Errorf("checkpoint failed: %w", err)
 	}
 
@@ -74,43 +140,86 @@ func configureAutoBackup(cmd *cobra.Command) error {
 	onSessionEnd, _ := cmd.Flags().GetBool("on-session-end")
 	watch, _ := cmd.Flags().GetBool("watch")
 	disable, _ := cmd.Flags().GetBool("disable")
+	install, _ := cmd.Flags().GetString("install")
+	uninstall, _ := cmd.Flags().GetBool("uninstall")
 
-	if disable {
+	// Keep the backup history when reconfiguring
+	config, err := loadAutoBackupConfig()
+	if err != nil {
+		return err
+	}
+
+	if disable || uninstall {
+		if err := uninstallSchedule(); err != nil {
+			return fmt.Errorf("failed to remove scheduler: %w", err)
+		}
+		config.Scheduler = ""
+		if uninstall && !disable {
+			return saveAutoBackupConfig(*config)
+		}
 		fmt.Println("🛑 Disabling auto-backup...")
-		return disableAutoBackup()
+		config.Enabled = false
+		return saveAutoBackupConfig(*config)
+	}
+
+	if interval == "" {
+		interval = config.Interval
+	}
+	var every time.Duration
+	if interval != "" {
+		if every, err = parseBackupInterval(interval); err != nil {
+			return err
+		}
+	}
+	if install != "" {
+		if interval == "" {
+			return fmt.Errorf("--install needs an --interval")
+		}
+		if err := validateSchedule(install, every); err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("🔄 Configuring auto-backup...")
 
-	// Create autobackup config
-	config := AutoBackupConfig{
-		Enabled:       true,
-		Interval:      interval,
-		OnSessionEnd:  onSessionEnd,
-		Watch:         watch,
-		LastBackup:    time.Time{},
+	config.Enabled = true
+	config.Interval = interval
+	if cmd.Flags().Changed("on-session-end") {
+		config.OnSessionEnd = onSessionEnd
 	}
-
-	// Save config
-	if err := saveAutoBackupConfig(config); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	if cmd.Flags().Changed("watch") {
+		config.Watch = watch
 	}
 
 	// Setup mechanisms
 	if interval != "" {
 		fmt.Printf("⏱️  Backing up every %s\n", interval)
-		// Could setup cron or systemd timer
+	}
+	if install != "" {
+		if err := uninstallSchedule(); err != nil {
+			return fmt.Errorf("failed to replace scheduler: %w", err)
+		}
+		if err := installSchedule(install, every); err != nil {
+			return fmt.Errorf("failed to install %s schedule: %w", install, err)
+		}
+		config.Scheduler = install
+	} else if interval != "" && config.Scheduler == "" {
+		fmt.Println("   Nothing runs it yet; add --install=systemd or --install=cron")
+	}
+
+	// Save config
+	if err := saveAutoBackupConfig(*config); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
 	}
 
 	if onSessionEnd {
 		fmt.Println("🚪 Backing up on session end")
-		// Would integrate with OpenClaw shutdown hooks
+		fmt.Println("   Run 'spirit backup --session-end' from the agent's session-end hook")
 	}
 
 	if watch {
 		fmt.Println("👁️  Watching for changes")
-		// Setup fsnotify watcher
-		go watchAndBackup()
+		fmt.Println("   Run 'spirit daemon' to start the watcher")
 	}
 
 	fmt.Println("\n✅ Auto-backup configured!")
@@ -124,7 +233,38 @@ type AutoBackupConfig struct {
 	Interval     string    `json:"interval,omitempty"`
 	OnSessionEnd bool      `json:"on_session_end"`
 	Watch        bool      `json:"watch"`
+	Scheduler    string    `json:"scheduler,omitempty"`
 	LastBackup   time.Time `json:"last_backup"`
+	LastAttempt  time.Time `json:"last_attempt,omitempty"`
+	LastResult   string    `json:"last_result,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// parseBackupInterval validates an --interval value.
+func parseBackupInterval(interval string) (time.Duration, error) {
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q (use e.g. 15m or 1h)", interval)
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("interval %s is too short (minimum 1m)", interval)
+	}
+	return d, nil
+}
+
+func loadAutoBackupConfig() (*AutoBackupConfig, error) {
+	var config AutoBackupConfig
+	data, err := os.ReadFile(filepath.Join(ConfigDir, "autobackup.json"))
+	if os.IsNotExist(err) {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse autobackup.json: %w", err)
+	}
+	return &config, nil
 }
 
 func saveAutoBackupConfig(config AutoBackupConfig) error {
@@ -138,32 +278,99 @@ func saveAutoBackupConfig(config AutoBackupConfig) error {
 	return os.WriteFile(configPath, data, 0600)
 }
 
-func disableAutoBackup() error {
-	config := AutoBackupConfig{
-		Enabled: false,
+// recordBackupResult stores the outcome of a backup run in autobackup.json.
+func recordBackupResult(backupErr error) error {
+	if _, err := os.Stat(ConfigDir); err != nil {
+		return nil
+	}
+	config, err := loadAutoBackupConfig()
+	if err != nil {
+		return err
+	}
+
+	config.LastAttempt = time.Now()
+	if backupErr != nil {
+		config.LastResult = "failed"
+		config.LastError = backupErr.Error()
+	} else {
+		config.LastResult = "success"
+		config.LastError = ""
+		config.LastBackup = config.LastAttempt
 	}
-	return saveAutoBackupConfig(config)
+	return saveAutoBackupConfig(*config)
 }
 
-func watchAndBackup() {
-	// Watch ~/.spirit/memory/ and projects/ for changes
-	// Auto-backup when files change
-	// This runs in background goroutine
+func showAutoBackupStatus() error {
+	config, err := loadAutoBackupConfig()
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("👁️  Watcher started (background)")
+	fmt.Println("🔄 Auto-backup Status")
+	fmt.Println()
+	if config.Enabled {
+		fmt.Println("   Enabled:      Yes")
+	} else {
+		fmt.Println("   Enabled:      No")
+	}
+	if config.Interval != "" {
+		fmt.Printf("   Interval:     %s\n", config.Interval)
+	} else {
+		fmt.Println("   Interval:     Not set")
+	}
+	if config.Scheduler != "" {
+		fmt.Printf("   Scheduler:    %s\n", config.Scheduler)
+	} else {
+		fmt.Println("   Scheduler:    None installed")
+	}
+	fmt.Printf("   Session end:  %s\n", yesNo(config.OnSessionEnd))
+	fmt.Printf("   Watch:        %s\n", yesNo(config.Watch))
 
-	// Simplified - real implementation would use fsnotify
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+	fmt.Println()
+	if config.LastBackup.IsZero() {
+		fmt.Println("   Last backup:  Never")
+	} else {
+		fmt.Printf("   Last backup:  %s ago (%s)\n", formatDuration(time.Since(config.LastBackup)),
+			config.LastBackup.Format("2006-01-02 15:04"))
+	}
+	switch config.LastResult {
+	case "success":
+		fmt.Println("   Last result:  ✓ success")
+	case "failed":
+		fmt.Printf("   Last result:  ✗ failed (%s)\n", config.LastAttempt.Format("2006-01-02 15:04"))
+		fmt.Printf("   Last error:   %s\n", config.LastError)
+	}
 
-	for range ticker.C {
-		// Check if changes since last backup
-		if hasChanges() {
-			if err := backupSpirit("Auto-backup on change"); err != nil {
-				fmt.Fprintf(os.Stderr, "Auto-backup failed: %v\n", err)
-			}
+	if interval, err := parseBackupInterval(config.Interval); err == nil && config.Enabled {
+		next := config.LastAttempt.Add(interval)
+		if config.LastAttempt.IsZero() || time.Until(next) <= 0 {
+			fmt.Println("   Next run:     Due now")
+		} else {
+			fmt.Printf("   Next run:     in %s (%s)\n", formatDuration(time.Until(next)), next.Format("15:04"))
 		}
 	}
+
+	return nil
+}
+
+func verifyAutoBackup() error {
+	config, err := loadAutoBackupConfig()
+	if err != nil {
+		return err
+	}
+	if err := verifySchedule(config.Scheduler); err != nil {
+		fmt.Printf("✗ Auto-backup schedule: %v\n", err)
+		return err
+	}
+	fmt.Printf("✓ Auto-backup schedule (%s) installed and active, every %s\n", config.Scheduler, config.Interval)
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
 }
 
 func hasChanges() bool {
@@ -175,17 +382,17 @@ func hasChanges() bool {
 		return checkMTimeChanges()
 	}
 
-	// Use git status --porcelain to check for uncommitted changes
-	cmd := exec.Command("git", "status", "--porcelain")
-	cmd.Dir = ConfigDir
-	output, err := cmd.Output()
+	// Ask git for uncommitted changes
+	git, err := activeGit()
 	if err != nil {
-		// Git command failed, fallback to mtime check
 		return checkMTimeChanges()
 	}
-
-	// If output is empty, no changes pending
-	return len(strings.TrimSpace(string(output))) > 0
+	dirty, err := git.IsDirty(ConfigDir)
+	if err != nil {
+		// Git status failed, fallback to mtime check
+		return checkMTimeChanges()
+	}
+	return dirty
 }
 
 func checkMTimeChanges() bool {
@@ -224,71 +431,104 @@ func checkMTimeChanges() bool {
 }
 
 func syncToBackends() error {
-	// Sync to all configured backends
-	// Currently just implements GitHub
-
-	configPath := filepath.Join(ConfigDir, "spirit.json")
-	if _, err := os.Stat(configPath); err != nil {
-		return fmt.Errorf("no configuration found, run 'spirit init' first")
+	backends, err := loadBackends()
+	if err != nil {
+		return err
 	}
+	return pushToBackends(backends)
+}
 
-	// Get all backends
-	backends := []string{"github"} // TODO: Read from config
+// defaultPushConcurrency is how many backends are pushed to at once when
+// spirit.json does not say.
+const defaultPushConcurrency = 4
 
-	for _, backend := range backends {
-		switch backend {
-		case "github":
-			if err := syncToGitHub(); err != nil {
-				return fmt.Errorf("github sync failed: %w", err)
-			}
-		case "s3":
-			// TODO
+// pushResult is the outcome of pushing to one backend.
+type pushResult struct {
+	Snapshot *Snapshot
+	Err      error
+}
+
+// pushToBackends pushes the committed state of ConfigDir to every backend,
+// several at a time, and reports each outcome. It fails when fewer backends
+// than the quorum succeeded, so one unreachable remote does not stop the
+// others from holding a copy.
+func pushToBackends(backends []Backend) error {
+	concurrency, quorum := defaultPushConcurrency, len(backends)
+	if config, err := loadConfig(); err == nil && config.Sync != nil {
+		if config.Sync.Concurrency > 0 {
+			concurrency = config.Sync.Concurrency
+		}
+		if config.Sync.Quorum > 0 && config.Sync.Quorum < quorum {
+			quorum = config.Sync.Quorum
 		}
 	}
 
-	return nil
-}
-
-func syncToGitHub() error {
-	// Sync to GitHub
-	// This is a simplified version - real one would check if git repo,
-	// commit if needed, and push
+	results := make([]pushResult, len(backends))
 
-	dotGit := filepath.Join(ConfigDir, ".git")
-	if _, err := os.Stat(dotGit); os.IsNotExist(err) {
-		// Initialize git repo
-		cmd := exec.Command("git", "init")
-		cmd.Dir = ConfigDir
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("git init failed: %w", err)
+	// Object backends read the working tree, so the git backends bring in
+	// their remote changes first and every backend receives the same state
+	gitBackends := 0
+	for _, b := range backends {
+		if _, ok := b.(*gitBackend); ok {
+			gitBackends++
 		}
-
-		// Add remote
-		// Would read from config
 	}
-
-	// Add all changes
-	cmd := exec.Command("git", "add", "-A")
-	cmd.Dir = ConfigDir
-	if err := cmd.Run(); err != nil {
-		return err
+	pulled := gitBackends > 0 && gitBackends < len(backends)
+	if pulled {
+		for i, b := range backends {
+			if gb, ok := b.(*gitBackend); ok {
+				results[i].Err = gb.Pull(ConfigDir)
+			}
+		}
 	}
 
-	// Commit
-	cmd = exec.Command("git", "commit", "-m", fmt.Sprintf("Auto-backup: %s",
-		time.Now().Format("2006-01-02 15:04:05")))
-	cmd.Dir = ConfigDir
-	if err := cmd.Run(); err != nil {
-		// Might be no changes
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		if results[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, b Backend) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if gb, ok := b.(*gitBackend); ok && pulled {
+				results[i].Snapshot, results[i].Err = gb.pushPulled(ConfigDir)
+				return
+			}
+			results[i].Snapshot, results[i].Err = b.Push(ConfigDir)
+		}(i, b)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for i, b := range backends {
+		r := results[i]
+		switch {
+		case r.Err != nil:
+			fmt.Printf("   ✗ %s: %v\n", b.Name(), r.Err)
+		case r.Snapshot == nil:
+			succeeded++
+			fmt.Printf("   → %s\n", b.Name())
+		default:
+			succeeded++
+			id := r.Snapshot.ID
+			if len(id) == 40 {
+				id = id[:7] // git commit
+			}
+			fmt.Printf("   → %s (%s)\n", b.Name(), id)
+		}
 	}
 
-	// Push
-	// cmd = exec.Command("git", "push", "origin", "main")
-	// cmd.Dir = ConfigDir
-	// if err := cmd.Run(); err != nil {
-	//	 return err
-	// }
-
-	fmt.Println("   → Synced to GitHub")
+	if succeeded < quorum {
+		if len(backends) == 1 {
+			return fmt.Errorf("%s: %w", backends[0].Name(), results[0].Err)
+		}
+		return fmt.Errorf("%d of %d backends succeeded, %d required", succeeded, len(backends), quorum)
+	}
+	if succeeded < len(backends) {
+		fmt.Printf("⚠️  %d of %d backends succeeded (quorum %d)\n", succeeded, len(backends), quorum)
+	}
 	return nil
 }
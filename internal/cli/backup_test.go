@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestPushToBackendsInParallel(t *testing.T) {
+	for name := range engines() {
+		t.Run(name, func(t *testing.T) {
+			withGitEnv(t)
+			t.Setenv("SPIRIT_GIT", name)
+			dir := newStateRepo(t, "main")
+			head := runGit(t, dir, "rev-parse", "HEAD")
+
+			mirror := filepath.Join(t.TempDir(), "usb")
+			if err := os.Mkdir(mirror, 0755); err != nil {
+				t.Fatal(err)
+			}
+			usb, err := newBackend("usb", BackendConfig{Type: "dir", Config: map[string]string{"path": mirror}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			remotes := []string{newBareRepo(t, "main"), newBareRepo(t, "main"), newBareRepo(t, "main")}
+			backends := []Backend{usb}
+			for i, remote := range remotes {
+				backends = append(backends, &gitBackend{name: string(rune('a' + i)), remote: string(rune('a' + i)), url: remote})
+			}
+
+			// Mixed: the git backends are pulled once, then pushed in parallel
+			if err := pushToBackends(backends); err != nil {
+				t.Fatal(err)
+			}
+			for _, remote := range remotes {
+				if got := runGit(t, remote, "rev-parse", "main"); got != head {
+					t.Errorf("%s main = %s, want %s", remote, got, head)
+				}
+			}
+
+			// Only git backends: each pulls and pushes on its own
+			if err := os.WriteFile(filepath.Join(dir, "memory/2026-01.md"), []byte("- later\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			runGit(t, dir, "commit", "--quiet", "-am", "later")
+			head = runGit(t, dir, "rev-parse", "HEAD")
+			if err := pushToBackends(backends[1:]); err != nil {
+				t.Fatal(err)
+			}
+			for _, remote := range remotes {
+				if got := runGit(t, remote, "rev-parse", "main"); got != head {
+					t.Errorf("%s main = %s, want %s", remote, got, head)
+				}
+			}
+		})
+	}
+}
+
+func TestAutoBackupStatusIgnoresInheritedFlags(t *testing.T) {
+	dir := withConfigDir(t)
+	config := filepath.Join(dir, "autobackup.json")
+
+	run := func(args ...string) {
+		t.Helper()
+		root := &cobra.Command{Use: "spirit"}
+		root.PersistentFlags().String("config-dir", "", "")
+		root.AddCommand(autoBackupCmd())
+		root.SetArgs(args)
+		if err := root.Execute(); err != nil {
+			t.Fatalf("spirit %v: %v", args, err)
+		}
+	}
+
+	run("--config-dir", dir, "autobackup")
+	if _, err := os.Stat(config); !os.IsNotExist(err) {
+		t.Fatalf("status view wrote autobackup.json (%v)", err)
+	}
+
+	run("--config-dir", dir, "autobackup", "--interval=15m")
+	loaded, err := loadAutoBackupConfig()
+	if err != nil || !loaded.Enabled || loaded.Interval != "15m" {
+		t.Fatalf("after --interval = %+v, %v", loaded, err)
+	}
+}
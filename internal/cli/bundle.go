@@ -0,0 +1,387 @@
+package cli
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// A .spirit-bundle file carries the checkpoint history between machines
+// that share no network. It is a tar holding manifest.json (the
+// BundleManifest) and history.bundle, a git bundle of the branch, either
+// complete or holding only the commits after a previous bundle.
+const (
+	bundleExt       = ".spirit-bundle"
+	bundleVersion   = "1"
+	bundleManifest  = "manifest.json"
+	bundleHistory   = "history.bundle"
+	bundleRemote    = "spirit-bundle"
+	bundleTagPrefix = "spirit/bundle-"
+)
+
+// BundleManifest describes a .spirit-bundle: where its history ends and,
+// for an incremental bundle, where it starts.
+type BundleManifest struct {
+	Version     string          `json:"version"`
+	ToolVersion string          `json:"tool_version"`
+	Identity    Identity        `json:"identity"`
+	CreatedAt   string          `json:"created_at"`
+	Branch      string          `json:"branch"`
+	Head        string          `json:"head"`
+	Since       string          `json:"since,omitempty"` // empty for a complete history
+	Commits     int             `json:"commits"`
+	SHA256      string          `json:"sha256"` // of history.bundle
+	Config      json.RawMessage `json:"config,omitempty"`
+}
+
+func bundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Carry the checkpoint history on removable media",
+		Long: `Write the git history of ~/.spirit and its spirit.json into a single
+.spirit-bundle file, and import it on a machine with no access to any
+backend. With --since, only the commits after that checkpoint are written.
+
+Every bundle tags the checkpoint it ends at, so the next one can start there.
+
+Examples:
+  spirit bundle create                                  # Complete history
+  spirit bundle create --since spirit/bundle-20260216-180000
+  spirit bundle apply orion-20260216-180000.spirit-bundle`,
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Write the history to a .spirit-bundle file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, _ := cmd.Flags().GetString("since")
+			output, _ := cmd.Flags().GetString("output")
+			return withLock(cmd.CommandPath(), func() error {
+				return createBundle(since, output)
+			})
+		},
+	}
+	createCmd.Flags().String("since", "", "Only write commits after this checkpoint (commit, tag or timestamp)")
+	createCmd.Flags().StringP("output", "o", "", "Bundle to write (default <name>-<timestamp>.spirit-bundle)")
+	cmd.AddCommand(createCmd)
+
+	applyCmd := &cobra.Command{
+		Use:   "apply <file>",
+		Short: "Verify a .spirit-bundle file and import its history",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			force, _ := cmd.Flags().GetBool("force")
+			return withLock(cmd.CommandPath(), func() error {
+				return applyBundle(args[0], force)
+			})
+		},
+	}
+	applyCmd.Flags().Bool("force", false, "Replace a state that has no checkpoint history")
+	cmd.AddCommand(applyCmd)
+
+	return cmd
+}
+
+func createBundle(since, output string) error {
+	if _, err := newExecGit(); err != nil {
+		return fmt.Errorf("bundles need the git binary: %w", err)
+	}
+	g := execGit{}
+	head, err := g.Head(ConfigDir)
+	if err != nil {
+		return fmt.Errorf("no checkpoint history in %s. Run: spirit checkpoint", ConfigDir)
+	}
+	branch, err := g.CurrentBranch(ConfigDir)
+	if err != nil {
+		return err
+	}
+
+	manifest := BundleManifest{
+		Version:     bundleVersion,
+		ToolVersion: Version,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		Branch:      branch,
+		Head:        head,
+	}
+	if data, err := os.ReadFile(filepath.Join(ConfigDir, "spirit.json")); err == nil {
+		var config Config
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("cannot parse spirit.json: %w", err)
+		}
+		manifest.Identity = config.Identity
+		manifest.Config = data
+	}
+
+	revs := branch
+	if since != "" {
+		base, err := resolveRestoreRef(since)
+		if err != nil {
+			return err
+		}
+		if base == head {
+			return fmt.Errorf("no checkpoints since %s", since)
+		}
+		if code, err := g.exitCode(ConfigDir, "merge-base", "--is-ancestor", base, head); err != nil {
+			return err
+		} else if code != 0 {
+			return fmt.Errorf("%s is not an earlier checkpoint of %s", since, branch)
+		}
+		manifest.Since = base
+		revs = base + ".." + branch
+	}
+	count, err := g.run(ConfigDir, "rev-list", "--count", revs)
+	if err != nil {
+		return err
+	}
+	manifest.Commits, _ = strconv.Atoi(count)
+
+	tmp, err := os.CreateTemp("", "spirit-bundle-")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if _, err := g.run(ConfigDir, "bundle", "create", "--quiet", tmp.Name(), revs); err != nil {
+		return fmt.Errorf("git bundle failed: %w", err)
+	}
+	history, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	manifest.SHA256 = sha256Hex(history)
+
+	if output == "" {
+		name := manifest.Identity.Name
+		if name == "" {
+			name = "spirit"
+		}
+		output = fmt.Sprintf("%s-%s%s", name, time.Now().Format("20060102-150405"), bundleExt)
+	}
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := writeBundle(f, &manifest, history); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Mark where this bundle ends so the next one can start from it
+	tag := bundleTagPrefix + time.Now().Format("20060102-150405")
+	if _, err := g.run(ConfigDir, "tag", "--force", tag, head); err != nil {
+		tag = head[:7]
+	}
+
+	fmt.Printf("✅ Wrote %s\n", output)
+	if manifest.Since != "" {
+		fmt.Printf("   Commits: %d since %s\n", manifest.Commits, manifest.Since[:7])
+	} else {
+		fmt.Printf("   Commits: %d (complete history)\n", manifest.Commits)
+	}
+	fmt.Printf("   Head: %s on %s\n", head[:7], branch)
+	fmt.Printf("   Size: %s\n", formatSize(int64(len(history))))
+	fmt.Printf("   Next time: spirit bundle create --since %s\n", tag)
+	return nil
+}
+
+// writeBundle writes the manifest and the git bundle as a .spirit-bundle.
+func writeBundle(w io.Writer, manifest *BundleManifest, history []byte) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	modTime := time.Now()
+	if t, err := time.Parse(time.RFC3339, manifest.CreatedAt); err == nil {
+		modTime = t
+	}
+
+	tw := tar.NewWriter(w)
+	for _, entry := range []struct {
+		name    string
+		content []byte
+	}{{bundleManifest, data}, {bundleHistory, history}} {
+		hdr := &tar.Header{Name: entry.name, Mode: 0600, Size: int64(len(entry.content)), ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.content); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// readBundle reads a .spirit-bundle and checks the git bundle against its
+// manifest. Bundles from a newer format version are refused.
+func readBundle(r io.Reader) (*BundleManifest, []byte, error) {
+	tr := tar.NewReader(r)
+	var manifest *BundleManifest
+	var history []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("not a .spirit-bundle file: %w", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch hdr.Name {
+		case bundleManifest:
+			manifest = &BundleManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			if manifest.Version != bundleVersion {
+				return nil, nil, fmt.Errorf("bundle format %q is not supported by spirit %s (expected %q)", manifest.Version, Version, bundleVersion)
+			}
+		case bundleHistory:
+			history = data
+		}
+	}
+
+	if manifest == nil || history == nil {
+		return nil, nil, fmt.Errorf("not a .spirit-bundle file: missing %s or %s", bundleManifest, bundleHistory)
+	}
+	if sha256Hex(history) != manifest.SHA256 {
+		return nil, nil, fmt.Errorf("%s: checksum mismatch, the file is damaged", bundleHistory)
+	}
+	return manifest, history, nil
+}
+
+// applyBundle imports a bundle into ConfigDir: a complete one starts the
+// history of a new machine, an incremental one is pulled like a remote.
+func applyBundle(file string, force bool) error {
+	if _, err := newExecGit(); err != nil {
+		return fmt.Errorf("bundles need the git binary: %w", err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	manifest, history, err := readBundle(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", file, err)
+	}
+
+	fmt.Printf("📥 Applying %s %s: %d commits up to %s (created %s by spirit %s)\n",
+		manifest.Identity.Emoji, manifest.Identity.Name, manifest.Commits, manifest.Head[:7], manifest.CreatedAt, manifest.ToolVersion)
+
+	tmp, err := os.CreateTemp("", "spirit-bundle-*.bundle")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(history); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	g := execGit{}
+	_, err = g.Head(ConfigDir)
+	fresh := err != nil
+	if fresh {
+		if manifest.Since != "" {
+			return fmt.Errorf("this bundle only holds the commits after %s; apply a complete bundle first", manifest.Since[:7])
+		}
+		if _, err := os.Stat(filepath.Join(ConfigDir, "spirit.json")); err == nil && !force {
+			return fmt.Errorf("%s already holds a state without history (use --force to replace it)", ConfigDir)
+		}
+		if err := os.MkdirAll(ConfigDir, 0755); err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(ConfigDir, ".git")); os.IsNotExist(err) {
+			if err := g.Init(ConfigDir); err != nil {
+				return err
+			}
+		}
+	} else if status, err := g.run(ConfigDir, "status", "--porcelain", "--untracked-files=no"); err != nil {
+		return err
+	} else if status != "" {
+		return fmt.Errorf("%s has changes that are not checkpointed. Run: spirit checkpoint", ConfigDir)
+	}
+
+	// git checks the bundle and, for an incremental one, that the commits
+	// it builds on are already here
+	if _, err := g.run(ConfigDir, "bundle", "verify", "--quiet", tmp.Name()); err != nil {
+		return fmt.Errorf("bundle does not apply to %s: %w", ConfigDir, err)
+	}
+
+	if err := g.SetRemote(ConfigDir, bundleRemote, tmp.Name()); err != nil {
+		return err
+	}
+	defer g.run(ConfigDir, "remote", "remove", bundleRemote)
+	if _, err := g.run(ConfigDir, "fetch", "--quiet", bundleRemote); err != nil {
+		return fmt.Errorf("cannot read bundle: %w", err)
+	}
+
+	if fresh {
+		// Files decrypt on checkout when keys/ and encryption.json were copied over
+		if encryptionEnabled() {
+			if err := installCryptFilter(); err != nil {
+				return err
+			}
+		}
+		ref := "refs/remotes/" + bundleRemote + "/" + manifest.Branch
+		if _, err := g.run(ConfigDir, "checkout", "--quiet", "--force", "--no-track", "-B", manifest.Branch, ref); err != nil {
+			return err
+		}
+	} else if err := pullWith(g, ConfigDir, bundleRemote, manifest.Branch); err != nil {
+		return fmt.Errorf("cannot merge bundle: %w", err)
+	}
+
+	// spirit.json may not be tracked, so the bundle carries it as well
+	configPath := filepath.Join(ConfigDir, "spirit.json")
+	if _, err := os.Stat(configPath); os.IsNotExist(err) && len(manifest.Config) > 0 {
+		if err := os.WriteFile(configPath, manifest.Config, 0600); err != nil {
+			return err
+		}
+	}
+
+	head, _ := g.Head(ConfigDir)
+	fmt.Printf("✅ Applied to %s\n", ConfigDir)
+	if head != "" {
+		fmt.Printf("   Head: %s\n", head[:7])
+	}
+	if encryptedFilesIn(ConfigDir) {
+		fmt.Printf("⚠️  Some files are still encrypted: copy keys/ and encryption.json into %s, then run 'spirit restore'\n", ConfigDir)
+	}
+	if sourceDir := getSourceDir(); sourceDir != ConfigDir {
+		fmt.Printf("   Write the files to %s with: spirit restore\n", sourceDir)
+	}
+	return nil
+}
+
+// encryptedFilesIn reports whether any tracked file in dir is still age
+// ciphertext.
+func encryptedFilesIn(dir string) bool {
+	patterns, err := loadTrackedFilesFrom(dir)
+	if err != nil {
+		patterns = defaultTrackedFiles
+	}
+	for _, f := range collectTrackedFiles(dir, patterns) {
+		content, err := os.ReadFile(filepath.Join(dir, f))
+		if err == nil && isEncrypted(content) {
+			return true
+		}
+	}
+	return false
+}
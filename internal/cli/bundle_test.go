@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createTestBundle writes a bundle of ConfigDir, since a checkpoint when
+// since is set.
+func createTestBundle(t *testing.T, since string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "orion"+bundleExt)
+	if err := createBundle(since, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// rewriteTestBundle writes a copy of a bundle after changing it.
+func rewriteTestBundle(t *testing.T, path string, change func(manifest *BundleManifest, history []byte) []byte) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	manifest, history, err := readBundle(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history = change(manifest, history)
+
+	var buf bytes.Buffer
+	if err := writeBundle(&buf, manifest, history); err != nil {
+		t.Fatal(err)
+	}
+	changed := filepath.Join(t.TempDir(), "changed"+bundleExt)
+	if err := os.WriteFile(changed, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return changed
+}
+
+func TestBundleComplete(t *testing.T) {
+	withGitEnv(t)
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	source := newStateRepo(t, "main")
+	bundle := createTestBundle(t, "")
+
+	dest := withConfigDir(t)
+	if err := applyBundle(bundle, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := runGit(t, dest, "rev-parse", "HEAD"), runGit(t, source, "rev-parse", "HEAD"); got != want {
+		t.Errorf("head = %s, want %s", got, want)
+	}
+	if got := runGit(t, dest, "branch", "--show-current"); got != "main" {
+		t.Errorf("branch = %s, want main", got)
+	}
+	if got := readTestFile(t, filepath.Join(dest, "memory/2026-01.md")); got != "- first day\n- second day\n" {
+		t.Errorf("memory = %q", got)
+	}
+	if remotes := runGit(t, dest, "remote"); remotes != "" {
+		t.Errorf("remotes left behind: %s", remotes)
+	}
+}
+
+func TestBundleIncremental(t *testing.T) {
+	withGitEnv(t)
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	source := newStateRepo(t, "main")
+	complete := createTestBundle(t, "")
+	tags := strings.Fields(runGit(t, source, "tag", "--list", bundleTagPrefix+"*"))
+	if len(tags) != 1 {
+		t.Fatalf("bundle tags = %v, want one", tags)
+	}
+
+	if err := os.WriteFile(filepath.Join(source, "memory/2026-01.md"), []byte("- first day\n- second day\n- third day\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, source, "commit", "--quiet", "-am", "third")
+	incremental := createTestBundle(t, tags[0])
+
+	t.Run("onto existing history", func(t *testing.T) {
+		dest := withConfigDir(t)
+		if err := applyBundle(complete, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyBundle(incremental, false); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := runGit(t, dest, "rev-parse", "HEAD"), runGit(t, source, "rev-parse", "HEAD"); got != want {
+			t.Errorf("head = %s, want %s", got, want)
+		}
+		if got := readTestFile(t, filepath.Join(dest, "memory/2026-01.md")); !strings.HasSuffix(got, "- third day\n") {
+			t.Errorf("memory = %q, want the third day", got)
+		}
+	})
+
+	t.Run("into an empty directory", func(t *testing.T) {
+		dest := withConfigDir(t)
+		if err := applyBundle(incremental, false); err == nil || !strings.Contains(err.Error(), "apply a complete bundle first") {
+			t.Errorf("apply = %v, want a complete bundle asked for", err)
+		}
+		if exists(filepath.Join(dest, ".git")) {
+			t.Error("a refused bundle created a repository")
+		}
+	})
+}
+
+func TestBundleRefused(t *testing.T) {
+	withGitEnv(t)
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	newStateRepo(t, "main")
+	bundle := createTestBundle(t, "")
+
+	cases := map[string]struct {
+		change func(manifest *BundleManifest, history []byte) []byte
+		want   string
+	}{
+		"checksum mismatch": {func(manifest *BundleManifest, history []byte) []byte {
+			damaged := bytes.Clone(history)
+			damaged[len(damaged)-1] ^= 0xff
+			return damaged
+		}, "checksum mismatch"},
+		"newer format": {func(manifest *BundleManifest, history []byte) []byte {
+			manifest.Version = "2"
+			return history
+		}, `bundle format "2" is not supported`},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			changed := rewriteTestBundle(t, bundle, c.change)
+			dest := withConfigDir(t)
+			if err := applyBundle(changed, false); err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("apply = %v, want %q", err, c.want)
+			}
+			if exists(filepath.Join(dest, ".git")) {
+				t.Error("a refused bundle created a repository")
+			}
+		})
+	}
+}
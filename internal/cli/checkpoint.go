@@ -1,9 +1,9 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -22,12 +22,20 @@ func checkpointCmd() *cobra.Command {
 			if len(args) > 0 {
 				message = args[0]
 			}
-			return createCheckpoint(message)
+			return withLock(cmd.CommandPath(), func() error {
+				return createCheckpoint(message)
+			})
 		},
 	}
 }
 
 func createCheckpoint(message string) error {
+	return createCheckpointAs(kindCheckpoint, message)
+}
+
+// createCheckpointAs commits the tracked files, recording kind in the
+// commit so 'spirit log' can tell manual, backup and daemon checkpoints apart.
+func createCheckpointAs(kind, message string) error {
 	// Check if spirit is initialized
 	if _, err := os.Stat(ConfigDir); os.IsNotExist(err) {
 		return fmt.Errorf("spirit not initialized. Run: spirit init")
@@ -42,16 +50,19 @@ func createCheckpoint(message string) error {
 		}
 	}
 
+	// Keep spirit.json in step with IDENTITY.md and SOUL.md
+	if err := syncIdentityConfig(); err != nil {
+		var newer *NewerVersionError
+		if errors.As(err, &newer) {
+			return err
+		}
+		fmt.Printf("⚠️  Could not update spirit.json from IDENTITY.md/SOUL.md: %v\n", err)
+	}
+
 	// Load tracked files
-	tracked, err := loadTrackedFiles()
+	tracked, err := trackedFilesOrDefault(ConfigDir)
 	if err != nil {
-		// Fallback to defaults
-		tracked = []string{
-			"IDENTITY.md", "SOUL.md", "AGENTS.md", "TOOLS.md",
-			"PROJECTS.md", "HEARTBEAT.md", "README.md",
-			"spirit.json", ".spirit-tracked", "memory/*.md",
-			"projects/*.md", "context/*.md",
-		}
+		return err
 	}
 
 	// Check which tracked files exist
@@ -80,17 +91,28 @@ func createCheckpoint(message string) error {
 		return fmt.Errorf("no files to checkpoint")
 	}
 
+	// Tracked files deleted since the last checkpoint are staged as removals
+	stageFiles := append(existingFiles, deletedTrackedFiles(tracked)...)
+
+	redacted, err := guardSecrets(existingFiles)
+	if err != nil {
+		return err
+	}
+
 	// Stage files
 	fmt.Printf("➕ Staging %d files...\n", len(existingFiles))
-	if err := gitAddFiles(existingFiles); err != nil {
+	if err := gitAddFiles(stageFiles); err != nil {
 		return fmt.Errorf("git add failed: %w", err)
 	}
+	if err := stageRedacted(redacted); err != nil {
+		return err
+	}
 
 	// Create commit with provided message
 	commitMsg := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), message)
 	fmt.Println("💾 Creating checkpoint...")
-	if err := gitCommit(commitMsg); err != nil {
-		if strings.Contains(err.Error(), "nothing") {
+	if err := gitCommit(spiritCommitMessage(kind, commitMsg)); err != nil {
+		if errors.Is(err, ErrNothingToCommit) {
 			fmt.Println("✅ Already up to date (no changes)")
 			return nil
 		}
@@ -98,15 +120,15 @@ func createCheckpoint(message string) error {
 	}
 
 	// Get commit hash for reference
-	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
-	cmd.Dir = ConfigDir
-	output, _ := cmd.Output()
-	commitHash := strings.TrimSpace(string(output))
+	commitHash, _ := gitHead()
+	if len(commitHash) > 7 {
+		commitHash = commitHash[:7]
+	}
 
 	fmt.Printf("🌌 Checkpoint created: %s\n", commitHash)
 	fmt.Printf("   Message: %s\n", message)
 	fmt.Printf("   Files: %d tracked\n", len(existingFiles))
-	
+
 	// Show hint about sync if remote exists
 	if remoteURL, _ := getRemoteURL(); remoteURL != "" {
 		fmt.Println("\n   Tip: Run 'spirit sync' to push to remote")
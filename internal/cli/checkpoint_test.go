@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestTrackedFilesOrDefault(t *testing.T) {
+	dir := t.TempDir()
+	if tracked, err := trackedFilesOrDefault(dir); err != nil || !reflect.DeepEqual(tracked, defaultTrackedFiles) {
+		t.Errorf("without .spirit-tracked = %v, %v; want the defaults", tracked, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".spirit-tracked"), []byte(`{"version": "9.0.0", "files": ["*.md"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var newer *NewerVersionError
+	if _, err := trackedFilesOrDefault(dir); !errors.As(err, &newer) {
+		t.Errorf("with a newer .spirit-tracked = %v, want NewerVersionError", err)
+	}
+}
+
+func TestCheckpointRefusesNewerConfig(t *testing.T) {
+	for _, file := range []string{"spirit.json", ".spirit-tracked"} {
+		t.Run(file, func(t *testing.T) {
+			withGitEnv(t)
+			dir := newStateRepo(t, "main")
+			head := runGit(t, dir, "rev-parse", "HEAD")
+
+			path := filepath.Join(dir, file)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newer := regexp.MustCompile(`"version": "[0-9.]+"`).ReplaceAll(data, []byte(`"version": "9.0.0"`))
+			if err := os.WriteFile(path, newer, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var versionErr *NewerVersionError
+			if err := createCheckpoint("newer"); !errors.As(err, &versionErr) {
+				t.Errorf("checkpoint = %v, want NewerVersionError", err)
+			}
+			if now := runGit(t, dir, "rev-parse", "HEAD"); now != head {
+				t.Error("checkpoint committed anyway")
+			}
+		})
+	}
+}
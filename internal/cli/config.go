@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Check spirit.json and .spirit-tracked against their schemas",
+		Long: `spirit.json and .spirit-tracked record the version of their format.
+Files from an older spirit are upgraded when they are loaded, keeping the
+original as <file>.<version>.bak; files from a newer spirit are refused.
+
+Examples:
+  spirit config validate
+  spirit config validate ~/agents/orion/spirit.json
+  spirit config schema spirit.json > spirit.schema.json`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate [file...]",
+		Short: "Validate spirit.json and .spirit-tracked",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return validateConfigFiles(args)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:       "schema <spirit.json|.spirit-tracked>",
+		Short:     "Print the JSON Schema of a file",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"spirit.json", ".spirit-tracked"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := schemaFileFor(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Print(f.schema)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// validateConfigFiles reports the problems in each file without changing
+// it; an older version is checked as it will be once upgraded.
+func validateConfigFiles(paths []string) error {
+	if len(paths) == 0 {
+		paths = []string{filepath.Join(ConfigDir, "spirit.json"), filepath.Join(ConfigDir, ".spirit-tracked")}
+	}
+
+	invalid := 0
+	for _, path := range paths {
+		f, err := schemaFileFor(path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", path, err)
+			invalid++
+			continue
+		}
+
+		version, problems, err := f.validate(data)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", path, err)
+			invalid++
+			continue
+		}
+		if len(problems) > 0 {
+			fmt.Printf("❌ %s\n", path)
+			for _, p := range problems {
+				fmt.Printf("   %s\n", p)
+			}
+			invalid++
+			continue
+		}
+
+		switch version {
+		case f.version:
+			fmt.Printf("✅ %s (version %s)\n", path, version)
+		case "":
+			fmt.Printf("✅ %s (unversioned, upgraded to %s when next loaded)\n", path, f.version)
+		default:
+			fmt.Printf("✅ %s (version %s, upgraded to %s when next loaded)\n", path, version, f.version)
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d files invalid", invalid, len(paths))
+	}
+	return nil
+}
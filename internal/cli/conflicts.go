@@ -0,0 +1,330 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// conflictsFile records the changes pulls could not merge. It is local to
+// the machine and never committed.
+const conflictsFile = ".spirit-conflicts.json"
+
+// Conflict is a change both sides made to the same entry, project row or
+// file. Kept is the text the merge left in the file.
+type Conflict struct {
+	File     string    `json:"file"`
+	Entry    string    `json:"entry,omitempty"` // empty for the whole file
+	Local    string    `json:"local"`
+	Remote   string    `json:"remote"`
+	Kept     string    `json:"kept"`
+	Recorded time.Time `json:"recorded"`
+}
+
+func newConflict(file, entry string, local, remote *string, kept string) Conflict {
+	return Conflict{File: file, Entry: entry, Local: deref(local), Remote: deref(remote), Kept: kept}
+}
+
+// Name describes where the conflict is, e.g. "PROJECTS.md › project P-2".
+func (c Conflict) Name() string {
+	if c.Entry == "" {
+		return c.File
+	}
+	return c.File + " › " + c.Entry
+}
+
+// KeptSide says which version the merge left in the file.
+func (c Conflict) KeptSide() string {
+	switch {
+	case sameEntry(&c.Kept, &c.Local):
+		return "local"
+	case sameEntry(&c.Kept, &c.Remote):
+		return "remote"
+	}
+	return "merged"
+}
+
+func loadConflicts() []Conflict {
+	conflicts := []Conflict{}
+	data, err := os.ReadFile(filepath.Join(ConfigDir, conflictsFile))
+	if err != nil {
+		return conflicts
+	}
+	json.Unmarshal(data, &conflicts)
+	return conflicts
+}
+
+func saveConflicts(conflicts []Conflict) error {
+	path := filepath.Join(ConfigDir, conflictsFile)
+	if len(conflicts) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(conflicts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// recordConflicts adds conflicts to the list, replacing older ones for the
+// same entry.
+func recordConflicts(conflicts []Conflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if err := ensureGitignore(conflictsFile); err != nil {
+		return err
+	}
+
+	existing := loadConflicts()
+	for _, c := range conflicts {
+		c.Recorded = time.Now()
+		replaced := false
+		for i := range existing {
+			if existing[i].File == c.File && existing[i].Entry == c.Entry {
+				existing[i] = c
+				replaced = true
+			}
+		}
+		if !replaced {
+			existing = append(existing, c)
+		}
+	}
+	return saveConflicts(existing)
+}
+
+// pullKeepingLocal retries a pull that stopped on conflicting lines in
+// files, keeping the local side of each conflicting hunk. The remote
+// version of every such file is recorded for 'spirit conflicts'. If the
+// retry fails as well, cause is returned.
+func pullKeepingLocal(dir, remote, branch string, files []string, cause error) error {
+	g := execGit{}
+	ref, err := g.trackingRef(dir, remote, branch)
+	if err != nil {
+		return cause
+	}
+
+	conflicts := []Conflict{}
+	for _, file := range files {
+		local, _ := os.ReadFile(filepath.Join(dir, file))
+		theirs, _ := g.ReadFile(dir, ref, file)
+		if isEncrypted(theirs) {
+			if theirs, err = decryptContent(theirs); err != nil {
+				return cause
+			}
+		}
+		conflicts = append(conflicts, Conflict{File: file, Local: string(local), Remote: string(theirs)})
+	}
+
+	// While rebasing, "theirs" is the local commit being replayed; the merge
+	// driver keeps the local side of what it cannot merge
+	if _, err := g.runEnv(dir, []string{keepLocalEnv + "=1"}, "pull", "--quiet", "--rebase", "-X", "theirs", remote, branch); err != nil {
+		g.run(dir, "rebase", "--abort")
+		return cause
+	}
+	for i := range conflicts {
+		kept, _ := os.ReadFile(filepath.Join(dir, conflicts[i].File))
+		conflicts[i].Kept = string(kept)
+	}
+	return recordConflicts(conflicts)
+}
+
+func conflictsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "conflicts",
+		Short: "List changes a sync could not merge",
+		Long: `When a sync pulls changes to the same memory entry, project row or file
+from another machine, the local version is kept and the remote one is
+listed here until you choose between them.
+
+Examples:
+  spirit conflicts
+  spirit conflicts show 1
+  spirit conflicts resolve 1 --keep=remote`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listConflicts()
+		},
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show <n>",
+		Short: "Show both versions of a conflict",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := findConflict(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("⚔️  %s\n", c.Name())
+			fmt.Printf("   Recorded %s ago, %s version kept\n", formatDuration(time.Since(c.Recorded)), c.KeptSide())
+			fmt.Printf("\n--- local\n%s", orDeleted(c.Local))
+			fmt.Printf("\n--- remote\n%s", orDeleted(c.Remote))
+			return nil
+		},
+	})
+
+	resolveCmd := &cobra.Command{
+		Use:   "resolve <n>",
+		Short: "Settle a conflict on the local, remote or both versions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keep, _ := cmd.Flags().GetString("keep")
+			return withLock(cmd.CommandPath(), func() error {
+				return resolveConflict(args[0], keep)
+			})
+		},
+	}
+	resolveCmd.Flags().String("keep", "local", "Version to keep: local, remote or both")
+	cmd.AddCommand(resolveCmd)
+
+	return cmd
+}
+
+func listConflicts() error {
+	conflicts := loadConflicts()
+	if len(conflicts) == 0 {
+		fmt.Println("✅ No conflicts to resolve")
+		return nil
+	}
+
+	if len(conflicts) == 1 {
+		fmt.Print("⚔️  1 unresolved conflict\n\n")
+	} else {
+		fmt.Printf("⚔️  %d unresolved conflicts\n\n", len(conflicts))
+	}
+	for i, c := range conflicts {
+		fmt.Printf("   %d. %s (%s ago, %s kept)\n", i+1, c.Name(), formatDuration(time.Since(c.Recorded)), c.KeptSide())
+		fmt.Printf("        local:  %s\n", conflictPreview(c.Local))
+		fmt.Printf("        remote: %s\n", conflictPreview(c.Remote))
+	}
+	fmt.Println()
+	fmt.Println("   Compare:  spirit conflicts show <n>")
+	fmt.Println("   Resolve:  spirit conflicts resolve <n> --keep=local|remote|both")
+	return nil
+}
+
+func findConflict(arg string) (Conflict, int, error) {
+	conflicts := loadConflicts()
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(conflicts) {
+		return Conflict{}, 0, fmt.Errorf("no conflict %s (see: spirit conflicts)", arg)
+	}
+	return conflicts[n-1], n - 1, nil
+}
+
+// resolveConflict replaces the kept text with the chosen version in
+// ConfigDir and in the source directory, then drops the conflict.
+func resolveConflict(arg, keep string) error {
+	c, index, err := findConflict(arg)
+	if err != nil {
+		return err
+	}
+
+	var chosen string
+	switch keep {
+	case "local":
+		chosen = c.Local
+	case "remote":
+		chosen = c.Remote
+	case "both":
+		chosen = strings.TrimRight(c.Local, "\n") + "\n" + c.Remote
+		if strings.HasPrefix(c.Remote, "#") {
+			chosen = strings.TrimRight(c.Local, "\n") + "\n\n" + c.Remote
+		}
+	default:
+		return fmt.Errorf("--keep must be local, remote or both")
+	}
+
+	if !sameEntry(&chosen, &c.Kept) {
+		if err := applyResolution(c, chosen, index+1); err != nil {
+			return err
+		}
+	}
+
+	conflicts := loadConflicts()
+	conflicts = append(conflicts[:index], conflicts[index+1:]...)
+	if err := saveConflicts(conflicts); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Resolved %s: kept %s\n", c.Name(), keep)
+	fmt.Println("   Run 'spirit sync' to share the resolution")
+	return nil
+}
+
+func applyResolution(c Conflict, chosen string, n int) error {
+	dirs := []string{ConfigDir}
+	if sourceDir := getSourceDir(); sourceDir != ConfigDir {
+		dirs = append(dirs, sourceDir)
+	}
+
+	applied := false
+	for _, dir := range dirs {
+		path := filepath.Join(dir, c.File)
+		if c.Entry == "" {
+			if err := os.WriteFile(path, []byte(chosen), 0644); err != nil {
+				return err
+			}
+			applied = true
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		content := string(data)
+		// The source directory may still hold the local version
+		for _, current := range []string{c.Kept, c.Local} {
+			if current == "" || !strings.Contains(content, current) {
+				continue
+			}
+			// Keep the blank lines that separated the entry from the next
+			replacement := chosen
+			if replacement != "" {
+				replacement = strings.TrimRight(chosen, "\n") + current[len(strings.TrimRight(current, "\n")):]
+			}
+			content = strings.Replace(content, current, replacement, 1)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return err
+			}
+			applied = true
+			break
+		}
+	}
+	if !applied {
+		return fmt.Errorf("%s changed since the conflict was recorded; edit it by hand, then: spirit conflicts resolve %d --keep=local", c.File, n)
+	}
+	return nil
+}
+
+// conflictPreview squeezes a version onto one line.
+func conflictPreview(text string) string {
+	if text == "" {
+		return "(deleted)"
+	}
+	line := strings.Join(strings.Fields(text), " ")
+	if len([]rune(line)) > 70 {
+		line = string([]rune(line)[:67]) + "..."
+	}
+	return line
+}
+
+func orDeleted(text string) string {
+	if text == "" {
+		return "(deleted)\n"
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text
+}
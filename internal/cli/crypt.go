@@ -0,0 +1,429 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+)
+
+// Tracked files are encrypted with age before they reach git history or any
+// backend. In git this is done with a clean/smudge filter, so the working
+// tree stays plaintext for the agent while every committed blob is
+// ciphertext. Object backends encrypt blobs and manifests themselves.
+
+const ageHeader = "age-encryption.org/v1"
+
+// EncryptionConfig is stored in ConfigDir/encryption.json. It is never
+// tracked: recipients are public, identities live in ConfigDir/keys/.
+type EncryptionConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Mode       string   `json:"mode"` // "x25519" or "passphrase"
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+func loadEncryptionConfig() (*EncryptionConfig, error) {
+	var config EncryptionConfig
+	data, err := os.ReadFile(filepath.Join(ConfigDir, "encryption.json"))
+	if os.IsNotExist(err) {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse encryption.json: %w", err)
+	}
+	return &config, nil
+}
+
+func saveEncryptionConfig(config *EncryptionConfig) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(ConfigDir, "encryption.json"), data, 0600)
+}
+
+func encryptionEnabled() bool {
+	config, err := loadEncryptionConfig()
+	return err == nil && config.Enabled
+}
+
+func keysDir() string {
+	return filepath.Join(ConfigDir, "keys")
+}
+
+// ageRecipients returns who new ciphertext is encrypted to.
+func ageRecipients(config *EncryptionConfig) ([]age.Recipient, error) {
+	if config.Mode == "passphrase" {
+		passphrase := os.Getenv("SPIRIT_PASSPHRASE")
+		if passphrase == "" {
+			return nil, fmt.Errorf("SPIRIT_PASSPHRASE is not set")
+		}
+		r, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []age.Recipient{r}, nil
+	}
+
+	if len(config.Recipients) == 0 {
+		return nil, fmt.Errorf("no encryption recipients configured. Run: spirit key generate")
+	}
+	return age.ParseRecipients(strings.NewReader(strings.Join(config.Recipients, "\n")))
+}
+
+// ageIdentities returns every key that may decrypt existing ciphertext:
+// the current identity, retired identities kept after a rotation, and the
+// passphrase when one is set.
+func ageIdentities() ([]age.Identity, error) {
+	identities := []age.Identity{}
+	if passphrase := os.Getenv("SPIRIT_PASSPHRASE"); passphrase != "" {
+		id, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, id)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(keysDir(), "*.txt"))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		identities = append(identities, ids...)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no decryption key in %s and SPIRIT_PASSPHRASE is not set", keysDir())
+	}
+	return identities, nil
+}
+
+func isEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(ageHeader))
+}
+
+func encryptContent(config *EncryptionConfig, plaintext []byte) ([]byte, error) {
+	recipients, err := ageRecipients(config)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	w, err := age.Encrypt(&out, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// decryptContent returns plaintext for age ciphertext and leaves anything
+// else untouched, so callers can use it on every file they read back.
+func decryptContent(content []byte) ([]byte, error) {
+	if !isEncrypted(content) {
+		return content, nil
+	}
+	identities, err := ageIdentities()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(content), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// decryptTree decrypts every encrypted file below dir in place. It is used
+// after pulling state from a backend that stores ciphertext.
+func decryptTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil || !isEncrypted(content) {
+			return err
+		}
+		plaintext, err := decryptContent(content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return os.WriteFile(path, plaintext, info.Mode())
+	})
+}
+
+// blobKey derives the key blobs are named with from secret material: the
+// passphrase, or the identities of the configured recipients. Anyone who
+// can list a store but not decrypt it cannot recompute a name, and so
+// cannot confirm a guess at a file's content.
+func blobKey(config *EncryptionConfig) ([]byte, error) {
+	var secret string
+	if config.Mode == "passphrase" {
+		secret = os.Getenv("SPIRIT_PASSPHRASE")
+		if secret == "" {
+			return nil, fmt.Errorf("SPIRIT_PASSPHRASE is not set")
+		}
+	} else {
+		identities, err := ageIdentities()
+		if err != nil {
+			return nil, err
+		}
+		recipients := map[string]bool{}
+		for _, r := range config.Recipients {
+			recipients[r] = true
+		}
+		keys := []string{}
+		for _, id := range identities {
+			if x, ok := id.(*age.X25519Identity); ok && recipients[x.Recipient().String()] {
+				keys = append(keys, x.String())
+			}
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("naming encrypted blobs needs the key of a recipient in %s", keysDir())
+		}
+		sort.Strings(keys)
+		secret = strings.Join(keys, "\n")
+	}
+	return hkdf.Key(sha256.New, []byte(secret), nil, "spirit blob names", sha256.Size)
+}
+
+// blobName hides the plaintext hash of a file behind a keyed hash, while
+// still letting identical content share one object.
+func blobName(key []byte, sum string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sum))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+const gitattributesContent = `# Managed by spirit: tracked files are encrypted in git history
+* filter=spirit diff=spirit
+.gitattributes !filter !diff
+.gitignore !filter !diff
+.spirit-tracked !filter !diff
+`
+
+// installCryptFilter registers the spirit clean/smudge filter in the
+// ConfigDir repository and applies it to every file.
+func installCryptFilter() error {
+	exe, err := spiritExecutable()
+	if err != nil {
+		return err
+	}
+
+	settings := [][2]string{
+		{"filter.spirit.clean", exe + " crypt clean %f"},
+		{"filter.spirit.smudge", exe + " crypt smudge %f"},
+		// Refuse to commit plaintext if the filter cannot run
+		{"filter.spirit.required", "true"},
+		{"diff.spirit.textconv", exe + " crypt textconv"},
+	}
+	for _, kv := range settings {
+		if _, err := gitOutput("config", kv[0], kv[1]); err != nil {
+			return fmt.Errorf("git config %s: %w", kv[0], err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(ConfigDir, ".gitattributes"), []byte(gitattributesContent), 0644); err != nil {
+		return err
+	}
+	return ensureGitignore("keys/", "encryption.json")
+}
+
+// spiritExecutable returns the resolved path of the running binary, for
+// the git configuration that calls back into spirit.
+func spiritExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return exe, nil
+}
+
+// ensureGitignore adds entries to ConfigDir/.gitignore if they are missing.
+func ensureGitignore(entries ...string) error {
+	path := filepath.Join(ConfigDir, ".gitignore")
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(string(data), "\n")
+	content := strings.TrimRight(string(data), "\n")
+	for _, entry := range entries {
+		found := false
+		for _, line := range lines {
+			if strings.TrimSpace(line) == entry {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if content != "" {
+				content += "\n"
+			}
+			content += entry
+		}
+	}
+	return os.WriteFile(path, []byte(content+"\n"), 0644)
+}
+
+// reencryptTracked re-runs the clean filter on every file in the index with
+// fresh ciphertext and commits the result.
+func reencryptTracked(message string) error {
+	if err := requireGitBinary("encryption"); err != nil {
+		return err
+	}
+	if _, err := gitOutput("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return nil // nothing committed yet
+	}
+	cmd := exec.Command("git", "add", "--renormalize", ".")
+	cmd.Dir = ConfigDir
+	cmd.Env = append(os.Environ(), "SPIRIT_CRYPT_FORCE=1")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git add --renormalize: %s", strings.TrimSpace(string(output)))
+	}
+	if err := gitCommit(message); err != nil && !errors.Is(err, ErrNothingToCommit) {
+		return err
+	}
+	return nil
+}
+
+func cryptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "crypt",
+		Short:  "Git filter used for encryption (internal)",
+		Hidden: true,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:  "clean [path]",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := ""
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return cryptClean(path, os.Stdin, os.Stdout)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:  "smudge [path]",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cryptSmudge(os.Stdin, os.Stdout)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:  "textconv <file>",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return cryptSmudge(f, os.Stdout)
+		},
+	})
+
+	return cmd
+}
+
+// cryptClean encrypts plaintext on its way into git. age ciphertext is
+// randomized, so when the plaintext matches what HEAD already holds the
+// existing ciphertext is reused; otherwise every file would look modified.
+func cryptClean(path string, in io.Reader, out io.Writer) error {
+	// git runs filters from the top of the work tree
+	if wd, err := os.Getwd(); err == nil {
+		ConfigDir = wd
+	}
+
+	plaintext, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(plaintext) {
+		_, err := out.Write(plaintext)
+		return err
+	}
+
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if !config.Enabled {
+		_, err := out.Write(plaintext)
+		return err
+	}
+
+	if path != "" && os.Getenv("SPIRIT_CRYPT_FORCE") == "" {
+		cmd := exec.Command("git", "cat-file", "blob", "HEAD:"+path)
+		cmd.Dir = ConfigDir
+		if previous, err := cmd.Output(); err == nil && isEncrypted(previous) {
+			if decrypted, err := decryptContent(previous); err == nil && bytes.Equal(decrypted, plaintext) {
+				_, err := out.Write(previous)
+				return err
+			}
+		}
+	}
+
+	ciphertext, err := encryptContent(config, plaintext)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(ciphertext)
+	return err
+}
+
+// cryptSmudge decrypts ciphertext on checkout. Without a key the ciphertext
+// is written as-is so a checkout never fails.
+func cryptSmudge(in io.Reader, out io.Writer) error {
+	if wd, err := os.Getwd(); err == nil {
+		if _, err := os.Stat(filepath.Join(wd, "encryption.json")); err == nil {
+			ConfigDir = wd
+		}
+	}
+
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	plaintext, err := decryptContent(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "spirit: cannot decrypt (%v), leaving ciphertext\n", err)
+		plaintext = content
+	}
+	_, err = out.Write(plaintext)
+	return err
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestBlobKeyNeedsThePassphrase(t *testing.T) {
+	withConfigDir(t)
+	config := &EncryptionConfig{Enabled: true, Mode: "passphrase"}
+
+	t.Setenv("SPIRIT_PASSPHRASE", "")
+	if _, err := blobKey(config); err == nil {
+		t.Error("blob key derived without a passphrase")
+	}
+	t.Setenv("SPIRIT_PASSPHRASE", "one")
+	one, err := blobKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SPIRIT_PASSPHRASE", "two")
+	two, err := blobKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(one, two) || blobName(one, "sum") == blobName(two, "sum") {
+		t.Error("different passphrases name blobs alike")
+	}
+}
+
+func TestBlobKeyNeedsTheIdentity(t *testing.T) {
+	withConfigDir(t)
+	t.Setenv("SPIRIT_PASSPHRASE", "")
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &EncryptionConfig{Enabled: true, Mode: "x25519", Recipients: []string{identity.Recipient().String()}}
+
+	// The recipients are public: they alone must not be enough
+	if _, err := blobKey(config); err == nil {
+		t.Error("blob key derived from the recipients alone")
+	}
+
+	if err := os.MkdirAll(keysDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(keysDir(), "identity.txt"), []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	first, err := blobKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := blobKey(config)
+	if err != nil || !bytes.Equal(first, again) {
+		t.Errorf("blob key is not stable: %v", err)
+	}
+}
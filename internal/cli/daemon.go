@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+func daemonCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Watch tracked files and checkpoint on change",
+		Long: `Run in the foreground and watch SPIRIT_SOURCE_DIR (or ~/.spirit/) for
+changes to tracked files.
+
+A burst of edits is debounced into a single checkpoint. Checkpoints are
+pushed to all configured backends on a fixed cadence. On SIGINT or SIGTERM,
+pending edits are checkpointed before the daemon exits.
+
+Examples:
+  spirit daemon
+  spirit daemon --debounce=10s --push-interval=5m
+  SPIRIT_SOURCE_DIR=/workspace spirit daemon`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			debounce, _ := cmd.Flags().GetDuration("debounce")
+			pushInterval, _ := cmd.Flags().GetDuration("push-interval")
+			if !cmd.Flags().Changed("push-interval") {
+				// Follow the interval set with 'spirit autobackup --interval'
+				if config, err := loadAutoBackupConfig(); err == nil {
+					if d, err := parseBackupInterval(config.Interval); err == nil {
+						pushInterval = d
+					}
+				}
+			}
+			return runDaemon(debounce, pushInterval)
+		},
+	}
+
+	cmd.Flags().Duration("debounce", 30*time.Second, "Quiet period after the last edit before checkpointing")
+	cmd.Flags().Duration("push-interval", 15*time.Minute, "How often to push new checkpoints to backends (0 to never push; defaults to the autobackup interval)")
+
+	return cmd
+}
+
+// shutdownLockWait is how long a stopping daemon waits for the lock to
+// checkpoint pending edits, well within systemd's default stop timeout.
+const shutdownLockWait = 5 * time.Second
+
+func runDaemon(debounce, pushInterval time.Duration) error {
+	if _, err := os.Stat(ConfigDir); os.IsNotExist(err) {
+		return fmt.Errorf("spirit not initialized. Run: spirit init")
+	}
+	if debounce <= 0 {
+		return fmt.Errorf("--debounce must be positive")
+	}
+
+	// Nobody sees a blocked checkpoint, so secrets are redacted by default
+	unattended = true
+
+	sourceDir := getSourceDir()
+	tracked, err := trackedFilesOrDefault(ConfigDir)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot start watcher: %w", err)
+	}
+	defer watcher.Close()
+	watchTrackedDirs(watcher, sourceDir, tracked)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var pushC <-chan time.Time
+	if pushInterval > 0 {
+		ticker := time.NewTicker(pushInterval)
+		defer ticker.Stop()
+		pushC = ticker.C
+	}
+
+	debounceTimer := time.NewTimer(debounce)
+	debounceTimer.Stop()
+	pushRetry := time.NewTimer(debounce)
+	pushRetry.Stop()
+	pending := map[string]bool{}
+	unpushed := false
+
+	// The lock is only tried, never waited for, so edits and signals keep
+	// being handled while another command holds it
+	push := func() {
+		if !unpushed {
+			return
+		}
+		release, err := tryLock("spirit daemon (push)")
+		if err != nil {
+			daemonLogf("⏳ Push postponed: %v", err)
+			pushRetry.Reset(debounce)
+			return
+		}
+		defer release()
+		daemonLogf("☁️ Pushing to backends...")
+		err = syncToBackends()
+		if recordErr := recordBackupResult(err); recordErr != nil {
+			daemonLogf("⚠️  Could not update autobackup.json: %v", recordErr)
+		}
+		if err != nil {
+			daemonLogf("⚠️  Push failed: %v", err)
+			return
+		}
+		unpushed = false
+	}
+
+	daemonLogf("👁️  Watching %s (debounce %s, push every %s)", sourceDir, debounce, pushInterval)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			relPath, err := filepath.Rel(sourceDir, event.Name)
+			if err != nil {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watchTrackedDirs(watcher, sourceDir, tracked)
+				}
+			}
+			if relPath == ".spirit-tracked" {
+				if reloaded, err := trackedFilesOrDefault(ConfigDir); err != nil {
+					daemonLogf("⚠️  Kept the previous tracked files: %v", err)
+				} else {
+					tracked = reloaded
+					watchTrackedDirs(watcher, sourceDir, tracked)
+					daemonLogf("🔁 Reloaded .spirit-tracked")
+				}
+			}
+			if !matchesTracked(relPath, tracked) {
+				continue
+			}
+			pending[relPath] = true
+			debounceTimer.Reset(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			daemonLogf("⚠️  Watcher error: %v", err)
+
+		case <-debounceTimer.C:
+			// The lock is held only while checkpointing, so scheduled
+			// backups can run alongside the daemon
+			release, err := tryLock("spirit daemon (checkpoint)")
+			if err != nil {
+				daemonLogf("⏳ Checkpoint postponed: %v", err)
+				debounceTimer.Reset(debounce)
+				continue
+			}
+			checkpointed := daemonCheckpoint(sourceDir, tracked, pending)
+			release()
+			if !checkpointed {
+				// Keep the edits for the next attempt rather than waiting
+				// for the same files to change again
+				daemonLogf("⏳ Retrying %d pending files in %s", len(pending), debounce)
+				debounceTimer.Reset(debounce)
+				continue
+			}
+			unpushed = true
+			pending = map[string]bool{}
+
+		case <-pushC:
+			push()
+
+		case <-pushRetry.C:
+			push()
+
+		case sig := <-signals:
+			daemonLogf("🛑 Received %s, shutting down", sig)
+			if len(pending) == 0 {
+				return nil
+			}
+			// Wait briefly at most: the service manager kills the daemon
+			// if it takes too long to stop
+			release, err := tryLock("spirit daemon (checkpoint)")
+			for deadline := time.Now().Add(shutdownLockWait); errors.Is(err, ErrLocked) && time.Now().Before(deadline); {
+				time.Sleep(250 * time.Millisecond)
+				release, err = tryLock("spirit daemon (checkpoint)")
+			}
+			if err != nil {
+				daemonLogf("⚠️  %d pending files left for the next checkpoint: %v", len(pending), err)
+				return nil
+			}
+			defer release()
+			if !daemonCheckpoint(sourceDir, tracked, pending) {
+				daemonLogf("⚠️  %d pending files left for the next checkpoint", len(pending))
+			}
+			return nil
+		}
+	}
+}
+
+// watchTrackedDirs adds the source directory and every existing directory
+// referenced by a tracked pattern to the watcher. fsnotify is not
+// recursive, so this is re-run whenever a directory is created.
+func watchTrackedDirs(watcher *fsnotify.Watcher, sourceDir string, tracked []string) {
+	dirs := map[string]bool{sourceDir: true}
+	for _, pattern := range tracked {
+		dirs[filepath.Join(sourceDir, filepath.Dir(pattern))] = true
+	}
+	for dir := range dirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if err := watcher.Add(dir); err != nil {
+				daemonLogf("⚠️  Cannot watch %s: %v", dir, err)
+			}
+		}
+	}
+}
+
+// daemonCheckpoint records the pending edits as one checkpoint and reports
+// whether it succeeded.
+func daemonCheckpoint(sourceDir string, tracked []string, pending map[string]bool) bool {
+	files := make([]string, 0, len(pending))
+	for f := range pending {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	message := fmt.Sprintf("Auto-checkpoint: %d files changed", len(files))
+	if len(files) == 1 {
+		message = "Auto-checkpoint: " + files[0]
+	}
+	daemonLogf("📸 %s", message)
+
+	if sourceDir != ConfigDir {
+		if _, err := stageSourceFiles(sourceDir, tracked, deleteStale, false); err != nil {
+			daemonLogf("⚠️  Staging failed: %v", err)
+			return false
+		}
+	}
+	if err := createCheckpointAs(kindAuto, message); err != nil {
+		daemonLogf("⚠️  Checkpoint failed: %v", err)
+		return false
+	}
+	return true
+}
+
+func daemonLogf(format string, args ...interface{}) {
+	fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
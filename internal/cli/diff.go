@@ -0,0 +1,394 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// structuredFiles are compared per Markdown heading and table row rather
+// than line by line.
+var structuredFiles = map[string]bool{
+	"IDENTITY.md": true,
+	"SOUL.md":     true,
+	"PROJECTS.md": true,
+}
+
+func diffCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff [from] [to]",
+		Short: "Show what changed between the working state and a checkpoint",
+		Long: `Compare the tracked files of two states. Without arguments, the working
+state (SPIRIT_SOURCE_DIR or ~/.spirit/) is compared with the last
+checkpoint. A state is a commit, tag or timestamp, as for 'spirit restore'.
+
+IDENTITY.md, SOUL.md and PROJECTS.md are compared per Markdown heading
+and table row.
+
+Examples:
+  spirit diff
+  spirit diff HEAD~3
+  spirit diff "2026-01-01 09:00" HEAD`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, to := "HEAD", ""
+			if len(args) > 0 {
+				from = args[0]
+			}
+			if len(args) > 1 {
+				to = args[1]
+			}
+			return showDiff(from, to)
+		},
+	}
+}
+
+// diffState is the tracked files of one side of a diff, keyed by slash path.
+type diffState struct {
+	Label string
+	Files map[string][]byte
+}
+
+// loadDiffState reads a checkpoint, or the working state when ref is empty.
+func loadDiffState(ref string) (*diffState, error) {
+	if ref == "" {
+		sourceDir := getSourceDir()
+		tracked, err := trackedFilesOrDefault(ConfigDir)
+		if err != nil {
+			return nil, err
+		}
+		state := &diffState{Label: "working state (" + sourceDir + ")", Files: map[string][]byte{}}
+		for _, f := range collectTrackedFiles(sourceDir, tracked) {
+			content, err := os.ReadFile(filepath.Join(sourceDir, f))
+			if err != nil {
+				return nil, err
+			}
+			state.Files[filepath.ToSlash(f)] = content
+		}
+		return state, nil
+	}
+
+	commit, err := resolveRestoreRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readCheckpointFiles(commit, trackedPatternsAt(commit))
+	if err != nil {
+		return nil, err
+	}
+	state := &diffState{Label: fmt.Sprintf("%s (%s)", ref, commit[:7]), Files: map[string][]byte{}}
+	for _, e := range entries {
+		state.Files[e.Path] = e.Content
+	}
+	return state, nil
+}
+
+func showDiff(fromRef, toRef string) error {
+	if _, err := os.Stat(filepath.Join(ConfigDir, ".git")); os.IsNotExist(err) {
+		return fmt.Errorf("no checkpoint history in %s. Run: spirit checkpoint", ConfigDir)
+	}
+
+	from, err := loadDiffState(fromRef)
+	if err != nil {
+		return err
+	}
+	to, err := loadDiffState(toRef)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔍 %s → %s\n\n", from.Label, to.Label)
+
+	paths := map[string]bool{}
+	for p := range from.Files {
+		paths[p] = true
+	}
+	for p := range to.Files {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	changed := 0
+	for _, p := range sorted {
+		before, inFrom := from.Files[p]
+		after, inTo := to.Files[p]
+		if inFrom && inTo && bytes.Equal(before, after) {
+			continue
+		}
+		changed++
+
+		marker := "~"
+		switch {
+		case !inFrom:
+			marker = "+"
+		case !inTo:
+			marker = "-"
+		}
+
+		if !structuredFiles[path.Base(p)] {
+			added, removed := diffLines(before, after)
+			fmt.Printf("   %s %s (+%d -%d lines)\n", marker, p, added, removed)
+			continue
+		}
+
+		fmt.Printf("   %s %s\n", marker, p)
+		for _, line := range diffMarkdown(p, string(before), string(after)) {
+			fmt.Printf("       %s\n", line)
+		}
+	}
+
+	if changed == 0 {
+		fmt.Println("   No changes")
+		return nil
+	}
+	if changed == 1 {
+		fmt.Println("\n   1 file changed")
+	} else {
+		fmt.Printf("\n   %d files changed\n", changed)
+	}
+	return nil
+}
+
+// diffLines counts lines added and removed between two texts, ignoring order.
+func diffLines(before, after []byte) (added, removed int) {
+	counts := map[string]int{}
+	for _, line := range splitLines(string(before)) {
+		counts[line]--
+	}
+	for _, line := range splitLines(string(after)) {
+		counts[line]++
+	}
+	for _, n := range counts {
+		if n > 0 {
+			added += n
+		} else {
+			removed -= n
+		}
+	}
+	return added, removed
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// markdownSection is the content under one heading. Items are list
+// entries and loose lines keyed by their text; rows are table rows keyed
+// by their first cell.
+type markdownSection struct {
+	Title string
+	Items map[string]int
+	Lines map[string]int
+	Rows  map[string]string
+}
+
+// parseMarkdownSections splits a document into sections by heading. The
+// document title (a level-1 heading) is not part of section names.
+func parseMarkdownSections(content string) (map[string]*markdownSection, []string) {
+	sections := map[string]*markdownSection{}
+	order := []string{}
+	headings := []string{}
+	current := ""
+
+	section := func(title string) *markdownSection {
+		s, ok := sections[title]
+		if !ok {
+			s = &markdownSection{Title: title, Items: map[string]int{}, Lines: map[string]int{}, Rows: map[string]string{}}
+			sections[title] = s
+			order = append(order, title)
+		}
+		return s
+	}
+
+	lines := splitLines(content)
+	inFence := false
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+		}
+
+		if strings.HasPrefix(line, "#") && !inFence {
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			title := strings.TrimSpace(line[level:])
+			if level == 1 {
+				headings = headings[:0]
+				current = ""
+				continue
+			}
+			// Keep the parents of this heading: levels 2..level-1
+			if depth := level - 2; depth < len(headings) {
+				headings = headings[:depth]
+			}
+			headings = append(headings, title)
+			current = strings.Join(headings, " › ")
+			section(current)
+			continue
+		}
+
+		s := section(current)
+		switch {
+		case inFence:
+			s.Lines[line]++
+		case strings.HasPrefix(line, "|"):
+			if isTableSeparator(line) {
+				continue
+			}
+			// The header row is the one directly above the separator
+			if i+1 < len(lines) && isTableSeparator(strings.TrimSpace(lines[i+1])) {
+				continue
+			}
+			cells := strings.Split(strings.Trim(line, "|"), "|")
+			s.Rows[strings.TrimSpace(cells[0])] = line
+		case isListItem(line):
+			s.Items[line]++
+		default:
+			s.Lines[line]++
+		}
+	}
+	return sections, order
+}
+
+func isTableSeparator(line string) bool {
+	return strings.HasPrefix(line, "|") && strings.Trim(line, "|-: ") == ""
+}
+
+func isListItem(line string) bool {
+	if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "+ ") {
+		return true
+	}
+	digits := strings.TrimLeft(line, "0123456789")
+	return len(digits) < len(line) && strings.HasPrefix(digits, ". ")
+}
+
+// diffMarkdown describes the changes to a structured file, one line per
+// changed section, e.g. "SOUL.md › Boundaries: +1 rule".
+func diffMarkdown(file, before, after string) []string {
+	itemNoun, rowNoun := "item", "row"
+	switch path.Base(file) {
+	case "SOUL.md":
+		itemNoun = "rule"
+	case "PROJECTS.md":
+		rowNoun = "project"
+	}
+
+	old, oldOrder := parseMarkdownSections(before)
+	cur, curOrder := parseMarkdownSections(after)
+
+	titles := append([]string{}, curOrder...)
+	for _, t := range oldOrder {
+		if _, ok := cur[t]; !ok {
+			titles = append(titles, t)
+		}
+	}
+
+	empty := &markdownSection{Items: map[string]int{}, Lines: map[string]int{}, Rows: map[string]string{}}
+	report := []string{}
+	for _, title := range titles {
+		name := path.Base(file)
+		if title != "" {
+			name += " › " + title
+		}
+		o, inOld := old[title]
+		c, inCur := cur[title]
+		switch {
+		case !inOld:
+			o = empty
+			if before != "" {
+				name += " (new section)"
+			}
+		case !inCur:
+			c = empty
+			if after != "" {
+				name += " (removed section)"
+			}
+		}
+
+		changes := []string{}
+		added, removed := countChanges(o.Items, c.Items)
+		changes = appendCount(changes, "+", added, itemNoun)
+		changes = appendCount(changes, "-", removed, itemNoun)
+
+		rowsAdded, rowsRemoved, rowsChanged := []string{}, []string{}, []string{}
+		for key, row := range c.Rows {
+			if previous, ok := o.Rows[key]; !ok {
+				rowsAdded = append(rowsAdded, key)
+			} else if previous != row {
+				rowsChanged = append(rowsChanged, key)
+			}
+		}
+		for key := range o.Rows {
+			if _, ok := c.Rows[key]; !ok {
+				rowsRemoved = append(rowsRemoved, key)
+			}
+		}
+		changes = appendCount(changes, "+", len(rowsAdded), rowNoun)
+		changes = appendCount(changes, "-", len(rowsRemoved), rowNoun)
+		changes = appendCount(changes, "~", len(rowsChanged), rowNoun)
+
+		added, removed = countChanges(o.Lines, c.Lines)
+		changes = appendCount(changes, "+", added, "line")
+		changes = appendCount(changes, "-", removed, "line")
+
+		if len(changes) == 0 {
+			if inOld && inCur {
+				continue
+			}
+			report = append(report, name)
+			continue
+		}
+		report = append(report, fmt.Sprintf("%s: %s", name, strings.Join(changes, ", ")))
+
+		for _, group := range []struct {
+			marker string
+			keys   []string
+		}{{"+", rowsAdded}, {"-", rowsRemoved}, {"~", rowsChanged}} {
+			sort.Strings(group.keys)
+			for _, key := range group.keys {
+				report = append(report, fmt.Sprintf("  %s %s", group.marker, key))
+			}
+		}
+	}
+	return report
+}
+
+func countChanges(before, after map[string]int) (added, removed int) {
+	for line, n := range after {
+		if d := n - before[line]; d > 0 {
+			added += d
+		}
+	}
+	for line, n := range before {
+		if d := n - after[line]; d > 0 {
+			removed += d
+		}
+	}
+	return added, removed
+}
+
+func appendCount(changes []string, sign string, n int, noun string) []string {
+	if n == 0 {
+		return changes
+	}
+	if n != 1 {
+		noun += "s"
+	}
+	return append(changes, fmt.Sprintf("%s%d %s", sign, n, noun))
+}
@@ -0,0 +1,676 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// spirit doctor runs a catalogue of named checks over the state directory,
+// its git repository, the tracked config, the backends and auto-backup.
+// Each check passes, warns or fails with a remedy. Checks only read; the
+// repairs that cannot lose anything are applied with --fix.
+
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// doctorResult is the outcome of a check. fix, when set, is a safe repair
+// for the problem it reports.
+type doctorResult struct {
+	Status  string
+	Message string
+	Remedy  string
+	fix     func() error
+}
+
+func checkPass(format string, args ...interface{}) doctorResult {
+	return doctorResult{Status: doctorPass, Message: fmt.Sprintf(format, args...)}
+}
+
+func checkWarn(message, remedy string) doctorResult {
+	return doctorResult{Status: doctorWarn, Message: message, Remedy: remedy}
+}
+
+func checkFail(message, remedy string) doctorResult {
+	return doctorResult{Status: doctorFail, Message: message, Remedy: remedy}
+}
+
+func (r doctorResult) withFix(fix func() error) doctorResult {
+	r.fix = fix
+	return r
+}
+
+type doctorCheck struct {
+	Name string
+	// needsConfig and needsRepo skip the check while spirit.json cannot be
+	// read or ConfigDir has no git repository; other checks report that.
+	needsConfig bool
+	needsRepo   bool
+	run         func() doctorResult
+}
+
+func doctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the SPIRIT setup and repair what is safe to",
+		// Failed checks are the report, not a usage mistake
+		SilenceUsage: true,
+		Long: `Run a series of named checks over ~/.spirit, its git repository, the
+tracked config, the backends and auto-backup. Each check passes, warns or
+fails, and says how to fix what it found.
+
+With --fix, the repairs that cannot lose anything are applied: relinking a
+dangling workspace .spirit-tracked, upgrading old config files, tightening
+the permissions of keys and files holding secrets, reattaching a detached
+HEAD to its branch, removing a stale lock, reinstalling the git encryption
+filter or the auto-backup schedule.
+
+Exits non-zero when any check fails.
+
+Examples:
+  spirit doctor
+  spirit doctor --fix`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fix, _ := cmd.Flags().GetBool("fix")
+			if !fix {
+				return runDoctor(false)
+			}
+			return withLock(cmd.CommandPath(), func() error {
+				return runDoctor(true)
+			})
+		},
+	}
+
+	cmd.Flags().Bool("fix", false, "Apply the safe repairs")
+
+	return cmd
+}
+
+func runDoctor(fix bool) error {
+	fmt.Println("🩺 SPIRIT Doctor")
+	fmt.Println()
+	fmt.Printf("   Config: %s\n", ConfigDir)
+	if sourceDir := getSourceDir(); sourceDir != ConfigDir {
+		fmt.Printf("   Source: %s\n", sourceDir)
+	}
+	fmt.Println()
+
+	if info, err := os.Stat(ConfigDir); err != nil || !info.IsDir() {
+		printDoctorResult("config-dir", checkFail("not initialized", "spirit init"), false)
+		return fmt.Errorf("spirit not initialized. Run: spirit init")
+	}
+
+	counts := map[string]int{}
+	fixable := 0
+	for _, check := range doctorChecks() {
+		if check.needsRepo && !hasGitRepo() {
+			continue
+		}
+		if check.needsConfig {
+			if _, err := doctorConfig(); err != nil {
+				continue
+			}
+		}
+
+		result := check.run()
+		repaired := ""
+		if result.Status != doctorPass && result.fix != nil {
+			if !fix {
+				fixable++
+			} else if err := result.fix(); err != nil {
+				repaired = fmt.Sprintf("🔧 repair failed: %v", err)
+			} else {
+				repaired = "🔧 repaired: " + result.Message
+				result = check.run()
+			}
+		}
+		printDoctorResult(check.Name, result, fix)
+		if repaired != "" {
+			fmt.Printf("     %-16s %s\n", "", repaired)
+		}
+		counts[result.Status]++
+	}
+
+	fmt.Println()
+	fmt.Printf("   %d passed, %d warnings, %d failed\n", counts[doctorPass], counts[doctorWarn], counts[doctorFail])
+	if fixable > 0 {
+		fmt.Printf("   Run 'spirit doctor --fix' to repair %d of them\n", fixable)
+	}
+	if counts[doctorFail] > 0 {
+		return fmt.Errorf("%d checks failed", counts[doctorFail])
+	}
+	return nil
+}
+
+func printDoctorResult(name string, r doctorResult, fixing bool) {
+	icon := map[string]string{doctorPass: "✓", doctorWarn: "⚠", doctorFail: "✗"}[r.Status]
+	fmt.Printf("   %s %-16s %s\n", icon, name, r.Message)
+	if r.Status == doctorPass || r.Remedy == "" {
+		return
+	}
+	remedy := r.Remedy
+	if r.fix != nil && !fixing {
+		remedy += " (or: spirit doctor --fix)"
+	}
+	fmt.Printf("     %-16s → %s\n", "", remedy)
+}
+
+// doctorChecks is the catalogue, in the order it is reported. Every
+// backend in spirit.json gets a check of its own.
+func doctorChecks() []doctorCheck {
+	checks := []doctorCheck{
+		{Name: "config-dir", run: checkConfigDir},
+		{Name: "spirit-json", run: checkSpiritJSON},
+		{Name: "tracked-config", run: checkTrackedConfig},
+		{Name: "source-dir", needsConfig: true, run: checkSourceDir},
+		{Name: "tracked-files", run: checkTrackedFiles},
+		{Name: "secret-perms", run: checkSecretPerms},
+		{Name: "private-perms", run: checkPrivatePerms},
+		{Name: "identity", needsConfig: true, run: checkIdentity},
+		{Name: "git-repo", run: checkGitRepo},
+		{Name: "git-head", needsRepo: true, run: checkGitHead},
+		{Name: "conflicts", needsRepo: true, run: checkConflicts},
+		{Name: "lock", run: checkLock},
+		{Name: "encryption", run: checkEncryption},
+	}
+
+	if config, err := doctorConfig(); err == nil {
+		backends, err := backendsFor(config)
+		if err != nil {
+			checks = append(checks, doctorCheck{Name: "backends", run: func() doctorResult {
+				return checkFail(err.Error(), "fix the backends section of spirit.json")
+			}})
+		}
+		for _, backend := range backends {
+			backend := backend
+			checks = append(checks, doctorCheck{Name: "backend:" + backend.Name(), run: func() doctorResult {
+				return checkBackend(backend)
+			}})
+		}
+	}
+
+	return append(checks, doctorCheck{Name: "autobackup", run: checkAutoBackup})
+}
+
+// doctorConfig reads spirit.json as it is, without the upgrade loadConfig
+// applies, so that checking never changes the file.
+func doctorConfig() (*Config, error) {
+	data, err := os.ReadFile(filepath.Join(ConfigDir, "spirit.json"))
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := configSchemaFile.validate(data); err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse spirit.json: %w", err)
+	}
+	return &config, nil
+}
+
+func hasGitRepo() bool {
+	_, err := os.Stat(filepath.Join(ConfigDir, ".git"))
+	return err == nil
+}
+
+// workspaceDir returns the workspace path recorded by 'spirit init
+// --workspace', or "" outside workspace mode.
+func workspaceDir(config *Config) string {
+	names := []string{}
+	for name, bc := range config.Backends {
+		if bc.Type == "workspace" && bc.Config["path"] != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return config.Backends[names[0]].Config["path"]
+}
+
+// readTrackedPatterns returns the patterns of .spirit-tracked, or the
+// defaults a sync falls back to when it cannot be read.
+func readTrackedPatterns() []string {
+	data, err := os.ReadFile(filepath.Join(ConfigDir, ".spirit-tracked"))
+	if err != nil {
+		return defaultTrackedFiles
+	}
+	var tracked TrackedConfig
+	if err := json.Unmarshal(data, &tracked); err != nil || len(tracked.Files) == 0 {
+		return defaultTrackedFiles
+	}
+	return tracked.Files
+}
+
+func writeTrackedConfig(path string, files []string) error {
+	data, err := json.MarshalIndent(TrackedConfig{Version: trackedVersion, Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// restrictPerms removes group and other access from each path.
+func restrictPerms(paths []string) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(path, info.Mode().Perm()&^0077); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkConfigDir() doctorResult {
+	info, err := os.Stat(ConfigDir)
+	if err != nil {
+		return checkFail(err.Error(), "spirit init")
+	}
+	if info.Mode().Perm()&0002 != 0 {
+		return checkWarn("writable by every user", fmt.Sprintf("chmod o-w %s", ConfigDir)).withFix(func() error {
+			return os.Chmod(ConfigDir, info.Mode().Perm()&^0022)
+		})
+	}
+	return checkPass("%s", ConfigDir)
+}
+
+func checkSpiritJSON() doctorResult {
+	path := filepath.Join(ConfigDir, "spirit.json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return checkFail("spirit.json is missing", "spirit init, or spirit restore from a backend")
+	}
+	return checkSchemaFile(configSchemaFile, path)
+}
+
+// checkSchemaFile validates a versioned config file. An older version is
+// only a warning: it is upgraded the next time it is loaded.
+func checkSchemaFile(f schemaFile, path string) doctorResult {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return checkFail(err.Error(), "")
+	}
+	version, problems, err := f.validate(data)
+	switch {
+	case err != nil:
+		return checkFail(err.Error(), "spirit config validate")
+	case len(problems) > 0:
+		message := problems[0]
+		if len(problems) > 1 {
+			message += fmt.Sprintf(" (and %d more)", len(problems)-1)
+		}
+		return checkFail(message, "spirit config validate, then correct "+f.name+" by hand")
+	case version != f.version:
+		from := "unversioned"
+		if version != "" {
+			from = "version " + version
+		}
+		return checkWarn(fmt.Sprintf("%s, older than %s", from, f.version), "upgraded the next time spirit loads it").withFix(func() error {
+			return f.upgradeFile(path)
+		})
+	}
+	return checkPass("version %s", version)
+}
+
+func checkTrackedConfig() doctorResult {
+	path := filepath.Join(ConfigDir, ".spirit-tracked")
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return checkWarn(".spirit-tracked is missing; syncs use the built-in file list", "write the list you want to .spirit-tracked").withFix(func() error {
+			return writeTrackedConfig(path, defaultTrackedFiles)
+		})
+	}
+	if err != nil {
+		return checkFail(err.Error(), "")
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, _ := os.Readlink(path)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return checkFail("dangling symlink to "+target, "recreate the workspace .spirit-tracked, or run spirit init --workspace again").withFix(func() error {
+				return repairTrackedLink(path, target)
+			})
+		}
+	}
+	return checkSchemaFile(trackedSchemaFile, path)
+}
+
+// repairTrackedLink points a dangling .spirit-tracked symlink at the
+// workspace's file when there is one, recreates the missing target when
+// its directory still exists, and otherwise replaces the link with a file.
+func repairTrackedLink(path, target string) error {
+	if config, err := doctorConfig(); err == nil {
+		if workspace := workspaceDir(config); workspace != "" {
+			candidate := filepath.Join(workspace, ".spirit-tracked")
+			if _, err := os.Stat(candidate); err == nil {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+				return os.Symlink(candidate, path)
+			}
+		}
+	}
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	if info, err := os.Stat(filepath.Dir(target)); err == nil && info.IsDir() {
+		return writeTrackedConfig(target, defaultTrackedFiles)
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return writeTrackedConfig(path, defaultTrackedFiles)
+}
+
+func checkSourceDir() doctorResult {
+	config, _ := doctorConfig()
+	workspace := workspaceDir(config)
+	sourceDir := os.Getenv("SPIRIT_SOURCE_DIR")
+
+	if workspace != "" {
+		if info, err := os.Stat(workspace); err != nil || !info.IsDir() {
+			return checkFail(fmt.Sprintf("workspace %s does not exist", workspace), "restore the workspace, or correct its path in spirit.json")
+		}
+	}
+	switch {
+	case sourceDir != "":
+		if info, err := os.Stat(sourceDir); err != nil || !info.IsDir() {
+			return checkFail(fmt.Sprintf("SPIRIT_SOURCE_DIR is %s, which does not exist", sourceDir), "correct or unset SPIRIT_SOURCE_DIR")
+		}
+		if workspace != "" && filepath.Clean(sourceDir) != filepath.Clean(workspace) {
+			return checkWarn(fmt.Sprintf("SPIRIT_SOURCE_DIR is %s, but the workspace is %s", sourceDir, workspace), "export SPIRIT_SOURCE_DIR="+workspace)
+		}
+		return checkPass("%s (SPIRIT_SOURCE_DIR)", sourceDir)
+	case workspace != "":
+		return checkFail(fmt.Sprintf("workspace mode uses %s, but SPIRIT_SOURCE_DIR is not set", workspace), "export SPIRIT_SOURCE_DIR="+workspace)
+	}
+	return checkPass("%s", ConfigDir)
+}
+
+func checkTrackedFiles() doctorResult {
+	sourceDir := getSourceDir()
+	patterns := readTrackedPatterns()
+	files := collectTrackedFiles(sourceDir, patterns)
+	if len(files) == 0 {
+		return checkFail(fmt.Sprintf("no tracked files in %s", sourceDir), "check the patterns in .spirit-tracked, or set SPIRIT_SOURCE_DIR")
+	}
+	return checkPass("%d files match %d patterns", len(files), len(patterns))
+}
+
+// checkSecretPerms looks for secrets in the tracked files. A file holding
+// one that other users can read fails; the repair makes it private, which
+// does not remove the secret.
+func checkSecretPerms() doctorResult {
+	sourceDir := getSourceDir()
+	files := collectTrackedFiles(sourceDir, readTrackedPatterns())
+	findings, _, err := scanSecrets(sourceDir, files)
+	if err != nil {
+		return checkWarn(fmt.Sprintf("secret scan failed: %v", err), "check "+allowlistFile)
+	}
+	if len(findings) == 0 {
+		return checkPass("no secrets in %d tracked files", len(files))
+	}
+
+	withSecrets, exposed, paths := []string{}, []string{}, []string{}
+	seen := map[string]bool{}
+	for _, f := range findings {
+		if seen[f.Path] {
+			continue
+		}
+		seen[f.Path] = true
+		withSecrets = append(withSecrets, f.Path)
+		info, err := os.Stat(filepath.Join(sourceDir, f.Path))
+		if err != nil || info.Mode().Perm()&0077 == 0 {
+			continue
+		}
+		exposed = append(exposed, f.Path)
+		paths = append(paths, filepath.Join(sourceDir, f.Path))
+		if sourceDir != ConfigDir {
+			staged := filepath.Join(ConfigDir, f.Path)
+			if _, err := os.Stat(staged); err == nil {
+				paths = append(paths, staged)
+			}
+		}
+	}
+
+	remedy := "remove them, use --secrets=redact, or allow them in " + allowlistFile
+	if len(exposed) > 0 {
+		return checkFail(fmt.Sprintf("secrets that other users can read in %s", strings.Join(exposed, ", ")), "chmod 600 them, then "+remedy).withFix(func() error {
+			return restrictPerms(paths)
+		})
+	}
+	return checkWarn(fmt.Sprintf("%d possible secrets in %s", len(findings), strings.Join(withSecrets, ", ")), remedy)
+}
+
+// checkPrivatePerms makes sure the files only spirit should read are
+// private: spirit.json and its settings, and the encryption keys.
+func checkPrivatePerms() doctorResult {
+	paths := []string{}
+	for _, name := range []string{"spirit.json", "autobackup.json", "encryption.json"} {
+		paths = append(paths, filepath.Join(ConfigDir, name))
+	}
+	keys, _ := filepath.Glob(filepath.Join(keysDir(), "*.txt"))
+	paths = append(paths, keys...)
+
+	open, keysOpen := []string{}, false
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0077 == 0 {
+			continue
+		}
+		open = append(open, path)
+		if filepath.Dir(path) == keysDir() {
+			keysOpen = true
+		}
+	}
+	if len(open) == 0 {
+		return checkPass("settings and keys are private")
+	}
+
+	names := []string{}
+	for _, path := range open {
+		rel, _ := filepath.Rel(ConfigDir, path)
+		names = append(names, rel)
+	}
+	message := fmt.Sprintf("%s readable by other users", strings.Join(names, ", "))
+	result := checkWarn(message, "chmod 600 them")
+	if keysOpen {
+		result = checkFail(message, "chmod 600 them, and rotate the key with spirit key rotate if others may have read it")
+	}
+	return result.withFix(func() error {
+		return restrictPerms(open)
+	})
+}
+
+func checkIdentity() doctorResult {
+	config, _ := doctorConfig()
+	drift, updated := identityDrift(config, getSourceDir())
+	if len(drift) == 0 {
+		return checkPass("spirit.json matches IDENTITY.md/SOUL.md")
+	}
+	if configNewerThanMarkdown(getSourceDir()) {
+		return checkWarn(fmt.Sprintf("spirit.json was edited after IDENTITY.md/SOUL.md (%s)", strings.Join(drift, ", ")), "spirit render updates the Markdown")
+	}
+	return checkWarn(fmt.Sprintf("spirit.json differs from IDENTITY.md/SOUL.md (%s)", strings.Join(drift, ", ")), "spirit checkpoint updates it, or spirit render rewrites the Markdown").withFix(func() error {
+		return saveConfig(updated)
+	})
+}
+
+func checkGitRepo() doctorResult {
+	if !hasGitRepo() {
+		return checkFail("no git repository", "spirit sync creates it").withFix(gitInit)
+	}
+	return checkPass("%s", filepath.Join(ConfigDir, ".git"))
+}
+
+// checkGitHead fails on an interrupted rebase and on a detached HEAD,
+// which is reattached when a branch points at the same commit.
+func checkGitHead() doctorResult {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(ConfigDir, ".git", dir)); err == nil {
+			return checkFail("a rebase was interrupted", fmt.Sprintf("git -C %s rebase --abort, then spirit sync", ConfigDir))
+		}
+	}
+	git, err := activeGit()
+	if err != nil {
+		return checkFail(err.Error(), "")
+	}
+	if branch, err := git.CurrentBranch(ConfigDir); err == nil {
+		return checkPass("on branch %s", branch)
+	}
+
+	head, err := git.Head(ConfigDir)
+	if err != nil {
+		return checkFail(fmt.Sprintf("cannot read HEAD: %v", err), "")
+	}
+	branches, _ := git.Branches(ConfigDir)
+	names := make([]string, 0, len(branches))
+	for name, hash := range branches {
+		if hash == head {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		branch := names[0]
+		return checkFail("HEAD is detached at "+head[:7], fmt.Sprintf("git -C %s checkout %s", ConfigDir, branch)).withFix(func() error {
+			return git.AttachHead(ConfigDir, branch)
+		})
+	}
+	return checkFail(fmt.Sprintf("HEAD is detached at %s, which no branch contains", head[:7]), fmt.Sprintf("git -C %s checkout -b <branch> to keep these commits", ConfigDir))
+}
+
+func checkConflicts() doctorResult {
+	if unmerged, _ := gitOutput("diff", "--name-only", "--diff-filter=U"); unmerged != "" {
+		return checkFail("unmerged files: "+strings.ReplaceAll(unmerged, "\n", ", "), fmt.Sprintf("git -C %s status", ConfigDir))
+	}
+	if n := len(loadConflicts()); n > 0 {
+		return checkWarn(fmt.Sprintf("%d unresolved conflicts", n), "spirit conflicts")
+	}
+	return checkPass("none")
+}
+
+func checkLock() doctorResult {
+	holder, err := readLock()
+	switch {
+	case err != nil:
+		return checkFail(err.Error(), "")
+	case holder == nil || holder.PID == os.Getpid():
+		return checkPass("free")
+	case holder.Stale():
+		return checkWarn("stale lock left by "+holder.String(), "rm "+lockPath()).withFix(func() error {
+			removeLockIf(*holder)
+			return nil
+		})
+	}
+	return checkWarn("held by "+holder.String(), "wait for it to finish")
+}
+
+func checkEncryption() doctorResult {
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return checkFail(err.Error(), "")
+	}
+	if !config.Enabled {
+		return checkPass("off")
+	}
+
+	if _, err := ageRecipients(config); err != nil {
+		remedy := "spirit key generate"
+		if config.Mode == "passphrase" {
+			remedy = "export SPIRIT_PASSPHRASE"
+		}
+		return checkFail(err.Error(), remedy)
+	}
+	if _, err := ageIdentities(); err != nil {
+		return checkFail(err.Error(), "restore keys/ from your key backup, or export SPIRIT_PASSPHRASE")
+	}
+
+	if hasGitRepo() {
+		clean, err := gitOutput("config", "filter.spirit.clean")
+		if err != nil || clean == "" {
+			return checkFail("the git encryption filter is not installed", "reinstall it from the current spirit binary").withFix(installCryptFilter)
+		}
+		exe := strings.TrimSuffix(clean, " crypt clean %f")
+		if _, err := os.Stat(exe); err != nil {
+			return checkFail(fmt.Sprintf("the git encryption filter runs %s, which no longer exists", exe), "reinstall it from the current spirit binary").withFix(installCryptFilter)
+		}
+	}
+	return checkPass("on (%s)", config.Mode)
+}
+
+// checkBackend makes sure a git backend's remote is set up before asking
+// any backend whether it is reachable.
+func checkBackend(backend Backend) doctorResult {
+	if gb, ok := backend.(*gitBackend); ok && hasGitRepo() {
+		git, err := activeGit()
+		if err != nil {
+			return checkFail(err.Error(), "")
+		}
+		current, err := git.RemoteURL(ConfigDir, gb.remote)
+		switch {
+		case err != nil && gb.url == "":
+			return checkFail(fmt.Sprintf("no remote %q configured", gb.remote), fmt.Sprintf("git -C %s remote add %s <url>", ConfigDir, gb.remote))
+		case err != nil, gb.url != "" && current != gb.url:
+			return checkWarn(fmt.Sprintf("remote %q is not set to %s", gb.remote, gb.url), "the next sync sets it").withFix(func() error {
+				return gb.ensureRemote(ConfigDir)
+			})
+		}
+	}
+	if err := backend.Health(); err != nil {
+		return checkFail(err.Error(), fmt.Sprintf("check backends.%s in spirit.json", backend.Name()))
+	}
+	return checkPass("healthy")
+}
+
+func checkAutoBackup() doctorResult {
+	config, err := loadAutoBackupConfig()
+	if err != nil {
+		return checkFail(err.Error(), "correct or remove autobackup.json")
+	}
+	if !config.Enabled {
+		return checkWarn("off", "spirit autobackup --interval=15m --install=systemd")
+	}
+	if config.Scheduler == "" {
+		return checkWarn("enabled, but no scheduler runs it", "spirit autobackup --install=systemd (or cron)")
+	}
+
+	interval, err := parseBackupInterval(config.Interval)
+	if err != nil {
+		return checkFail(err.Error(), "spirit autobackup --interval=15m")
+	}
+	if err := verifySchedule(config.Scheduler); err != nil {
+		return checkFail(err.Error(), fmt.Sprintf("spirit autobackup --install=%s", config.Scheduler)).withFix(func() error {
+			if err := uninstallSchedule(); err != nil {
+				return err
+			}
+			return installSchedule(config.Scheduler, interval)
+		})
+	}
+
+	if config.LastResult == "failed" {
+		return checkFail(fmt.Sprintf("last backup failed %s ago: %s", formatDuration(time.Since(config.LastAttempt)), config.LastError), "spirit backup")
+	}
+	if config.LastBackup.IsZero() {
+		return checkPass("%s every %s, no backup yet", config.Scheduler, config.Interval)
+	}
+	since := time.Since(config.LastBackup)
+	if since > 3*interval {
+		return checkWarn(fmt.Sprintf("last backup %s ago, expected every %s", formatDuration(since), config.Interval), "spirit autobackup --status")
+	}
+	return checkPass("%s every %s, last %s ago", config.Scheduler, config.Interval, formatDuration(since))
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stateFiles records the content and mode of every file in dir outside .git.
+func stateFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if info.Mode().IsRegular() {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files[rel] = info.Mode().String() + " " + info.ModTime().Format(time.RFC3339Nano) + " " + string(content)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestDoctorFixDanglingTrackedLink(t *testing.T) {
+	withGitEnv(t)
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+
+	t.Run("target directory exists", func(t *testing.T) {
+		dir := newStateRepo(t, "main")
+		workspace := t.TempDir()
+		link := filepath.Join(dir, ".spirit-tracked")
+		if err := os.Remove(link); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(workspace, ".spirit-tracked"), link); err != nil {
+			t.Fatal(err)
+		}
+		if result := checkTrackedConfig(); result.Status != doctorFail || result.fix == nil {
+			t.Fatalf("check = %+v, want a fixable failure", result)
+		}
+
+		runDoctor(true)
+		if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("the link was replaced: %v", err)
+		}
+		if tracked, err := loadTrackedFilesFrom(workspace); err != nil || !reflect.DeepEqual(tracked, defaultTrackedFiles) {
+			t.Errorf("workspace .spirit-tracked = %v, %v; want the defaults", tracked, err)
+		}
+		if result := checkTrackedConfig(); result.Status != doctorPass {
+			t.Errorf("after --fix: %+v", result)
+		}
+	})
+
+	t.Run("target directory gone", func(t *testing.T) {
+		dir := newStateRepo(t, "main")
+		link := filepath.Join(dir, ".spirit-tracked")
+		if err := os.Remove(link); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(t.TempDir(), "gone", ".spirit-tracked"), link); err != nil {
+			t.Fatal(err)
+		}
+
+		runDoctor(true)
+		info, err := os.Lstat(link)
+		if err != nil || !info.Mode().IsRegular() {
+			t.Fatalf(".spirit-tracked is not a file: %v", err)
+		}
+		if result := checkTrackedConfig(); result.Status != doctorPass {
+			t.Errorf("after --fix: %+v", result)
+		}
+	})
+}
+
+func TestDoctorFixSecretPerms(t *testing.T) {
+	withGitEnv(t)
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	dir := newStateRepo(t, "main")
+	path := filepath.Join(dir, "memory/2026-01.md")
+	content := "- token " + fakeGitHubToken + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result := checkSecretPerms(); result.Status != doctorFail || !strings.Contains(result.Message, "memory/2026-01.md") {
+		t.Fatalf("check = %+v, want memory/2026-01.md reported", result)
+	}
+
+	runDoctor(true)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if got := readTestFile(t, path); got != content {
+		t.Error("--fix changed the file's content")
+	}
+	if result := checkSecretPerms(); result.Status != doctorWarn {
+		t.Errorf("after --fix: %+v, want only a warning about the secret", result)
+	}
+}
+
+func TestDoctorFixLeavesPassingChecks(t *testing.T) {
+	withGitEnv(t)
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	dir := newStateRepo(t, "main")
+	runGit(t, dir, "remote", "add", "origin", newBareRepo(t, "main"))
+	head := runGit(t, dir, "rev-parse", "HEAD")
+	before := stateFiles(t, dir)
+
+	if err := runDoctor(true); err != nil {
+		t.Fatalf("doctor on a healthy state = %v", err)
+	}
+	if after := stateFiles(t, dir); !reflect.DeepEqual(after, before) {
+		t.Errorf("--fix changed a healthy state:\nbefore %q\nafter  %q", before, after)
+	}
+	if now := runGit(t, dir, "rev-parse", "HEAD"); now != head {
+		t.Error("--fix committed")
+	}
+
+	// Repairing one check touches nothing the others looked at
+	path := filepath.Join(dir, "memory/2026-01.md")
+	if err := os.WriteFile(path, []byte("- token "+fakeGitHubToken+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	before = stateFiles(t, dir)
+	runDoctor(true)
+	after := stateFiles(t, dir)
+	if !strings.HasPrefix(after["memory/2026-01.md"], "-rw------- ") {
+		t.Errorf("memory/2026-01.md = %q, want it made private", after["memory/2026-01.md"])
+	}
+	delete(before, "memory/2026-01.md")
+	delete(after, "memory/2026-01.md")
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("--fix changed files it did not report:\nbefore %q\nafter  %q", before, after)
+	}
+}
@@ -0,0 +1,313 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Errors reported by the git engines. Callers test for them with errors.Is
+// instead of matching git's (localised) output.
+var (
+	ErrGitUnavailable       = errors.New("needs the git binary, which is not installed")
+	ErrNotRepository        = errors.New("not a git repository")
+	ErrNoCommits            = errors.New("no commits yet")
+	ErrNothingToCommit      = errors.New("nothing to commit")
+	ErrUnknownRevision      = errors.New("unknown revision")
+	ErrRemoteNotFound       = errors.New("remote not configured")
+	ErrRemoteBranchNotFound = errors.New("remote branch not found")
+	ErrRemoteUnreachable    = errors.New("remote unreachable")
+	ErrAuthFailed           = errors.New("authentication failed")
+	ErrDiverged             = errors.New("local and remote history have diverged")
+	ErrConflict             = errors.New("conflicting changes")
+)
+
+// GitError is a failed git operation. Err is one of the errors above when
+// the failure is understood, or nil; Detail is what git reported.
+type GitError struct {
+	Op     string
+	Err    error
+	Detail string
+}
+
+func (e *GitError) Error() string {
+	if e.Err == nil {
+		return "git " + e.Op + ": " + e.Detail
+	}
+	msg := "git " + e.Op + ": " + e.Err.Error()
+	if e.Detail != "" {
+		msg += " (" + e.Detail + ")"
+	}
+	return msg
+}
+
+func (e *GitError) Unwrap() error { return e.Err }
+
+// MergeConflictError is a pull that stopped because both sides changed the
+// same lines of Files. It matches ErrConflict.
+type MergeConflictError struct {
+	Files []string
+}
+
+func (e *MergeConflictError) Error() string {
+	return "conflicting changes in " + strings.Join(e.Files, ", ")
+}
+
+func (e *MergeConflictError) Is(target error) bool { return target == ErrConflict }
+
+// gitEngine is the git implementation the state repository is driven with.
+// The native engine is built in; the exec engine runs the git binary and is
+// needed for features git implements through configuration, such as the
+// encryption filter.
+type gitEngine interface {
+	Name() string
+	Init(dir string) error
+	RemoteURL(dir, remote string) (string, error)
+	SetRemote(dir, remote, url string) error
+	// ListBranches returns the branch names of a remote or URL.
+	ListBranches(dir, remote string) ([]string, error)
+	// Add stages paths, including deletions. No paths stages everything.
+	Add(dir string, paths []string) error
+	// StageContent stages content as path without touching the file in
+	// the worktree.
+	StageContent(dir, path string, content []byte) error
+	// Commit returns the new commit hash, or ErrNothingToCommit.
+	Commit(dir, message string) (string, error)
+	Head(dir string) (string, error)
+	LastCommitTime(dir string) (time.Time, error)
+	IndexedFiles(dir string) ([]string, error)
+	IsDirty(dir string) (bool, error)
+	CurrentBranch(dir string) (string, error)
+	// RemoteHead returns the branch the remote's HEAD points at, or
+	// ErrRemoteBranchNotFound when it cannot tell.
+	RemoteHead(dir, remote string) (string, error)
+	// AheadBehind counts the commits the current branch and the fetched
+	// remote branch each have that the other lacks.
+	AheadBehind(dir, remote, branch string) (ahead, behind int, err error)
+	Fetch(dir, remote string) error
+	// Pull brings the current branch up to date with the fetched remote
+	// branch, or returns ErrRemoteBranchNotFound if it does not exist.
+	Pull(dir, remote, branch string) error
+	// Push pushes the current branch to the remote branch. The first push
+	// of a branch without upstream sets it to the remote branch.
+	Push(dir, remote, branch string) error
+
+	// ResolveCommit returns the full hash of the commit a hash, abbreviated
+	// hash, branch, remote branch or tag names, or ErrUnknownRevision.
+	ResolveCommit(dir, rev string) (string, error)
+	// Log lists the commits reachable from rev, newest first. A non-zero
+	// since leaves out older commits and a positive limit caps the count.
+	Log(dir, rev string, since time.Time, limit int) ([]commitInfo, error)
+	// Files lists the regular files of a commit, leaving out symlinks.
+	Files(dir, commit string) ([]gitFile, error)
+	// ReadFile returns path as stored in a commit, before any filter.
+	ReadFile(dir, commit, path string) ([]byte, error)
+	// Tag creates a lightweight tag pointing at commit.
+	Tag(dir, name, commit string) error
+	// Branches maps each local branch to the commit it points at.
+	Branches(dir string) (map[string]string, error)
+	// AttachHead points HEAD at branch without touching the worktree.
+	AttachHead(dir, branch string) error
+}
+
+// commitInfo is one commit of a history.
+type commitInfo struct {
+	Hash    string
+	Time    time.Time // committer date
+	Message string    // full message, trailers included
+	// FilesChanged counts the files changed from the first parent, or
+	// added by a root commit. Merges count none, as in git log --shortstat.
+	FilesChanged int
+}
+
+// Subject returns the first line of the message.
+func (c commitInfo) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return strings.TrimSpace(subject)
+}
+
+// Trailer returns the value of a "Key: value" trailer in the last
+// paragraph of the message.
+func (c commitInfo) Trailer(key string) string {
+	paragraphs := strings.Split(strings.TrimSpace(c.Message), "\n\n")
+	if len(paragraphs) < 2 {
+		return ""
+	}
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if value, ok := strings.CutPrefix(line, key+":"); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// gitFile is a regular file stored in a commit.
+type gitFile struct {
+	Path string
+	Size int64
+}
+
+// activeGit selects the git engine from SPIRIT_GIT ("native" or "exec").
+// By default the native engine is used, unless encryption is enabled: its
+// clean/smudge filter only runs under the git binary.
+func activeGit() (gitEngine, error) {
+	mode := os.Getenv("SPIRIT_GIT")
+	switch mode {
+	case "", "auto":
+		if encryptionEnabled() {
+			if _, err := newExecGit(); err != nil {
+				return nil, fmt.Errorf("encryption %w", err)
+			}
+			return execGit{}, nil
+		}
+		return nativeGit{}, nil
+	case "native":
+		if encryptionEnabled() {
+			return nil, fmt.Errorf("SPIRIT_GIT=native cannot be used with encryption, which needs the git binary")
+		}
+		return nativeGit{}, nil
+	case "exec":
+		if _, err := newExecGit(); err != nil {
+			return nil, fmt.Errorf("SPIRIT_GIT=exec %w", err)
+		}
+		return execGit{}, nil
+	default:
+		return nil, fmt.Errorf("unknown SPIRIT_GIT %q (use native or exec)", mode)
+	}
+}
+
+func gitInit() error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	return git.Init(ConfigDir)
+}
+
+func getRemoteURL() (string, error) {
+	git, err := activeGit()
+	if err != nil {
+		return "", err
+	}
+	return git.RemoteURL(ConfigDir, "origin")
+}
+
+func gitAddAll() error {
+	return gitAddFiles(nil)
+}
+
+func gitAddFiles(files []string) error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	return git.Add(ConfigDir, files)
+}
+
+func gitCommit(message string) error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	_, err = git.Commit(ConfigDir, message)
+	return err
+}
+
+func gitHead() (string, error) {
+	git, err := activeGit()
+	if err != nil {
+		return "", err
+	}
+	return git.Head(ConfigDir)
+}
+
+func gitIndexedFiles() ([]string, error) {
+	git, err := activeGit()
+	if err != nil {
+		return nil, err
+	}
+	return git.IndexedFiles(ConfigDir)
+}
+
+// gitFetch fetches remote into dir. A missing or unreachable remote is not
+// an error, so pushes and restores can carry on with what was fetched before.
+func gitFetch(dir, remote string) error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	err = git.Fetch(dir, remote)
+	if err != nil && !errors.Is(err, ErrRemoteUnreachable) && !errors.Is(err, ErrRemoteNotFound) {
+		return err
+	}
+	return nil
+}
+
+// resolveBranch picks the remote branch to pull from and push to: the
+// configured one, else the branch the remote's HEAD points at, else the
+// current branch, which an empty remote will take.
+func resolveBranch(git gitEngine, dir, remote, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	branch, err := git.RemoteHead(dir, remote)
+	if errors.Is(err, ErrRemoteBranchNotFound) {
+		return git.CurrentBranch(dir)
+	}
+	return branch, err
+}
+
+// gitPull brings dir up to date with the remote branch. A branch that does
+// not exist yet has nothing to pull. The native engine only fast-forwards,
+// so diverged histories are rebased with the git binary when there is one.
+func gitPull(dir, remote, branch string) error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	return pullWith(git, dir, remote, branch)
+}
+
+// pullWith pulls through the given engine, merging memory logs and
+// PROJECTS.md and keeping the local side of anything that conflicts.
+func pullWith(git gitEngine, dir, remote, branch string) error {
+	// Rebases run the git binary, which hands memory logs and PROJECTS.md
+	// to the spirit merge driver
+	if _, lookErr := newExecGit(); lookErr == nil {
+		if err := installMergeDriver(dir); err != nil {
+			return err
+		}
+	}
+	recorded := len(loadConflicts())
+
+	err := git.Pull(dir, remote, branch)
+	if errors.Is(err, ErrDiverged) && git.Name() != "exec" {
+		if fallback, lookErr := newExecGit(); lookErr == nil {
+			err = fallback.Pull(dir, remote, branch)
+		}
+	}
+	var conflict *MergeConflictError
+	if errors.As(err, &conflict) {
+		err = pullKeepingLocal(dir, remote, branch, conflict.Files, err)
+	}
+	if err != nil && !errors.Is(err, ErrRemoteBranchNotFound) {
+		return err
+	}
+
+	if n := len(loadConflicts()) - recorded; n == 1 {
+		fmt.Println("⚔️  1 conflicting change kept the local version. Review it: spirit conflicts")
+	} else if n > 1 {
+		fmt.Printf("⚔️  %d conflicting changes kept the local version. Review them: spirit conflicts\n", n)
+	}
+	return nil
+}
+
+func gitPush(dir, remote, branch string) error {
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	return git.Push(dir, remote, branch)
+}
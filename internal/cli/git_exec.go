@@ -0,0 +1,445 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execGit drives the git binary. Outcomes are read from exit codes and
+// plumbing commands; only failures to reach a remote, which all exit with
+// 128, are told apart by git's messages, read in the C locale.
+type execGit struct{}
+
+func newExecGit() (gitEngine, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrGitUnavailable
+	}
+	return execGit{}, nil
+}
+
+func (execGit) Name() string { return "exec" }
+
+// run executes git in dir. A failure is a GitError carrying git's stderr.
+func (g execGit) run(dir string, args ...string) (string, error) {
+	return g.runEnv(dir, nil, args...)
+}
+
+// runEnv is run with extra environment variables, e.g. for the merge
+// driver.
+func (execGit) runEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", &GitError{Op: args[0], Detail: stderrOf(err)}
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// runRemote executes a git command that talks to a remote, without
+// prompting for credentials. Failures to reach the remote or to log in are
+// reported as ErrRemoteUnreachable and ErrAuthFailed.
+func (execGit) runRemote(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.Output()
+	if err != nil {
+		detail := stderrOf(err)
+		return "", &GitError{Op: args[0], Err: remoteFailure(detail), Detail: detail}
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func stderrOf(err error) string {
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return err.Error()
+}
+
+// remoteFailures are the messages git prints, in the C locale, for each
+// kind of remote failure. Authentication comes first: an SSH key that is
+// refused also reads "Could not read from remote repository".
+var remoteFailures = []struct {
+	err      error
+	messages []string
+}{
+	{ErrAuthFailed, []string{
+		"Authentication failed", "could not read Username", "could not read Password",
+		"terminal prompts disabled", "Permission denied (publickey", "HTTP Basic: Access denied",
+		"The requested URL returned error: 401", "The requested URL returned error: 403",
+	}},
+	{ErrRemoteUnreachable, []string{
+		"Could not resolve host", "Connection refused", "Connection timed out",
+		"Operation timed out", "Network is unreachable", "No route to host", "Failed to connect",
+		"does not appear to be a git repository", "Repository not found",
+		"The requested URL returned error: 404", "Could not read from remote repository",
+		"unable to access",
+	}},
+	{ErrDiverged, []string{"non-fast-forward", "fetch first"}},
+}
+
+// remoteFailure returns the engine error for what git reported, or nil.
+func remoteFailure(detail string) error {
+	for _, failure := range remoteFailures {
+		for _, message := range failure.messages {
+			if strings.Contains(detail, message) {
+				return failure.err
+			}
+		}
+	}
+	return nil
+}
+
+// exitCode runs git in dir and returns its exit status, for commands that
+// answer a question through it.
+func (execGit) exitCode(dir string, args ...string) (int, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+func (g execGit) Init(dir string) error {
+	_, err := g.run(dir, "init", "--quiet")
+	return err
+}
+
+func (g execGit) RemoteURL(dir, remote string) (string, error) {
+	// git config exits with 1 when the key is not set
+	if code, err := g.exitCode(dir, "config", "--get", "remote."+remote+".url"); err != nil {
+		return "", err
+	} else if code == 1 {
+		return "", &GitError{Op: "remote", Err: ErrRemoteNotFound, Detail: remote}
+	}
+	return g.run(dir, "remote", "get-url", remote)
+}
+
+func (g execGit) SetRemote(dir, remote, url string) error {
+	if _, err := g.RemoteURL(dir, remote); errors.Is(err, ErrRemoteNotFound) {
+		_, err = g.run(dir, "remote", "add", remote, url)
+		return err
+	}
+	_, err := g.run(dir, "remote", "set-url", remote, url)
+	return err
+}
+
+func (g execGit) ListBranches(dir, remote string) ([]string, error) {
+	output, err := g.runRemote(dir, "ls-remote", "--heads", remote)
+	if err != nil {
+		return nil, err
+	}
+	branches := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			branches = append(branches, strings.TrimPrefix(fields[1], "refs/heads/"))
+		}
+	}
+	return branches, nil
+}
+
+func (g execGit) Add(dir string, paths []string) error {
+	args := append([]string{"add", "-A", "--"}, paths...)
+	_, err := g.run(dir, args...)
+	return err
+}
+
+func (g execGit) StageContent(dir, path string, content []byte) error {
+	// --path applies the attributes of path, such as the encryption filter
+	cmd := exec.Command("git", "hash-object", "-w", "--stdin", "--path="+filepath.ToSlash(path))
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(content)
+	output, err := cmd.Output()
+	if err != nil {
+		return &GitError{Op: "hash-object", Detail: stderrOf(err)}
+	}
+	hash := strings.TrimSpace(string(output))
+	_, err = g.run(dir, "update-index", "--add", "--cacheinfo", "100644,"+hash+","+filepath.ToSlash(path))
+	return err
+}
+
+func (g execGit) Commit(dir, message string) (string, error) {
+	// diff --cached --quiet exits with 0 when nothing is staged
+	code, err := g.exitCode(dir, "diff", "--cached", "--quiet")
+	if err != nil {
+		return "", err
+	}
+	if code == 0 {
+		return "", &GitError{Op: "commit", Err: ErrNothingToCommit}
+	}
+	if _, err := g.run(dir, "commit", "--quiet", "-m", message); err != nil {
+		return "", err
+	}
+	return g.Head(dir)
+}
+
+func (g execGit) Head(dir string) (string, error) {
+	hash, err := g.run(dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		return "", &GitError{Op: "rev-parse", Err: ErrNoCommits}
+	}
+	return hash, nil
+}
+
+func (g execGit) LastCommitTime(dir string) (time.Time, error) {
+	if _, err := g.Head(dir); err != nil {
+		return time.Time{}, err
+	}
+	output, err := g.run(dir, "log", "-1", "--format=%ct")
+	if err != nil {
+		return time.Time{}, err
+	}
+	ts, err := strconv.ParseInt(output, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(ts, 0), nil
+}
+
+func (g execGit) IndexedFiles(dir string) ([]string, error) {
+	output, err := g.run(dir, "ls-files")
+	if err != nil || output == "" {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+func (g execGit) IsDirty(dir string) (bool, error) {
+	output, err := g.run(dir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	return output != "", nil
+}
+
+func (g execGit) Fetch(dir, remote string) error {
+	if _, err := g.RemoteURL(dir, remote); err != nil {
+		return err
+	}
+	_, err := g.runRemote(dir, "fetch", "--quiet", remote)
+	return err
+}
+
+func (g execGit) CurrentBranch(dir string) (string, error) {
+	return g.run(dir, "symbolic-ref", "--short", "HEAD")
+}
+
+func (g execGit) RemoteHead(dir, remote string) (string, error) {
+	// ls-remote --symref prints "ref: refs/heads/<branch>\tHEAD" first
+	output, err := g.runRemote(dir, "ls-remote", "--symref", remote, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if target, ok := strings.CutPrefix(line, "ref: refs/heads/"); ok {
+			return strings.TrimSuffix(target, "\tHEAD"), nil
+		}
+	}
+	// HEAD names a branch that was never pushed: settle for the only one
+	if branches, err := g.ListBranches(dir, remote); err == nil && len(branches) == 1 {
+		return branches[0], nil
+	}
+	return "", &GitError{Op: "ls-remote", Err: ErrRemoteBranchNotFound, Detail: remote + " HEAD"}
+}
+
+// trackingRef returns the fetched ref of a remote branch, if it exists.
+func (g execGit) trackingRef(dir, remote, branch string) (string, error) {
+	ref := "refs/remotes/" + remote + "/" + branch
+	if code, err := g.exitCode(dir, "rev-parse", "--verify", "--quiet", ref); err != nil {
+		return "", err
+	} else if code != 0 {
+		return "", &GitError{Op: "rev-parse", Err: ErrRemoteBranchNotFound, Detail: remote + "/" + branch}
+	}
+	return ref, nil
+}
+
+func (g execGit) AheadBehind(dir, remote, branch string) (int, int, error) {
+	ref, err := g.trackingRef(dir, remote, branch)
+	if err != nil {
+		return 0, 0, err
+	}
+	output, err := g.run(dir, "rev-list", "--left-right", "--count", "HEAD..."+ref)
+	if err != nil {
+		return 0, 0, err
+	}
+	var ahead, behind int
+	if _, err := fmt.Sscanf(output, "%d\t%d", &ahead, &behind); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+func (g execGit) Pull(dir, remote, branch string) error {
+	if _, err := g.trackingRef(dir, remote, branch); err != nil {
+		return err
+	}
+	// Files redacted in the index differ from the worktree: stash them aside
+	_, err := g.runRemote(dir, "pull", "--quiet", "--rebase", "--autostash", remote, branch)
+	if err == nil {
+		return nil
+	}
+	// Leave the repository as it was, and say which files collided
+	unmerged, listErr := g.run(dir, "diff", "--name-only", "--diff-filter=U")
+	if listErr != nil || unmerged == "" {
+		return err
+	}
+	if _, abortErr := g.run(dir, "rebase", "--abort"); abortErr != nil {
+		return abortErr
+	}
+	return &GitError{Op: "pull", Err: &MergeConflictError{Files: strings.Split(unmerged, "\n")}}
+}
+
+func (g execGit) Push(dir, remote, branch string) error {
+	local, err := g.CurrentBranch(dir)
+	if err != nil {
+		return err
+	}
+	if _, err := g.Head(dir); err != nil {
+		return err
+	}
+	args := []string{"push", "--quiet"}
+	// git config exits with 1 when the branch has no upstream yet
+	if code, err := g.exitCode(dir, "config", "--get", "branch."+local+".remote"); err != nil {
+		return err
+	} else if code == 1 {
+		args = append(args, "--set-upstream")
+	}
+	_, err = g.runRemote(dir, append(args, remote, local+":refs/heads/"+branch)...)
+	return err
+}
+
+func (g execGit) ResolveCommit(dir, rev string) (string, error) {
+	hash, err := g.run(dir, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil || hash == "" {
+		return "", &GitError{Op: "rev-parse", Err: ErrUnknownRevision, Detail: rev}
+	}
+	return hash, nil
+}
+
+func (g execGit) Log(dir, rev string, since time.Time, limit int) ([]commitInfo, error) {
+	args := []string{"log", "--format=%x1e%H%x1f%ct%x1f%B%x1f", "--shortstat"}
+	if !since.IsZero() {
+		args = append(args, fmt.Sprintf("--since=%d", since.Unix()))
+	}
+	if limit > 0 {
+		args = append(args, "-n", strconv.Itoa(limit))
+	}
+	output, err := g.run(dir, append(args, rev, "--")...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []commitInfo{}
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.Split(record, "\x1f")
+		if len(fields) < 4 {
+			continue
+		}
+		ts, _ := strconv.ParseInt(fields[1], 10, 64)
+		commit := commitInfo{Hash: fields[0], Time: time.Unix(ts, 0), Message: strings.TrimSpace(fields[2])}
+		// " 3 files changed, 10 insertions(+)"
+		fmt.Sscanf(strings.TrimSpace(fields[3]), "%d file", &commit.FilesChanged)
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+func (g execGit) Files(dir, commit string) ([]gitFile, error) {
+	output, err := g.run(dir, "ls-tree", "-r", "-l", "-z", commit)
+	if err != nil {
+		return nil, err
+	}
+	files := []gitFile{}
+	for _, entry := range strings.Split(output, "\x00") {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 || fields[1] != "blob" || fields[0] == "120000" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		files = append(files, gitFile{Path: path, Size: size})
+	}
+	return files, nil
+}
+
+func (g execGit) ReadFile(dir, commit, path string) ([]byte, error) {
+	cmd := exec.Command("git", "cat-file", "blob", commit+":"+filepath.ToSlash(path))
+	cmd.Dir = dir
+	content, err := cmd.Output()
+	if err != nil {
+		return nil, &GitError{Op: "cat-file", Detail: commit + ":" + path}
+	}
+	return content, nil
+}
+
+func (g execGit) Tag(dir, name, commit string) error {
+	_, err := g.run(dir, "tag", name, commit)
+	return err
+}
+
+func (g execGit) Branches(dir string) (map[string]string, error) {
+	output, err := g.run(dir, "for-each-ref", "--format=%(objectname) %(refname:lstrip=2)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	branches := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if hash, name, ok := strings.Cut(line, " "); ok {
+			branches[name] = hash
+		}
+	}
+	return branches, nil
+}
+
+func (g execGit) AttachHead(dir, branch string) error {
+	_, err := g.run(dir, "symbolic-ref", "HEAD", "refs/heads/"+branch)
+	return err
+}
+
+// requireGitBinary fails with ErrGitUnavailable, naming what needs it, when
+// the git binary is not installed.
+func requireGitBinary(what string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("%s %w", what, ErrGitUnavailable)
+	}
+	return nil
+}
+
+// gitOutput runs a git command in ConfigDir and returns its trimmed stdout.
+// Only what the built-in engine cannot do, such as installing the
+// encryption filter, still goes through it.
+func gitOutput(args ...string) (string, error) {
+	return gitOutputIn(ConfigDir, args...)
+}
+
+func gitOutputIn(dir string, args ...string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git %s %w", args[0], ErrGitUnavailable)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,795 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func init() {
+	// Serve local remotes in-process instead of through git-upload-pack
+	client.InstallProtocol("file", localTransport{server.NewClient(server.DefaultLoader)})
+}
+
+// localTransport serves file remotes without a git binary. Commits the
+// remote has never seen are dropped from the client's haves: git
+// upload-pack ignores them, the go-git server fails on them.
+type localTransport struct {
+	transport.Transport
+}
+
+func (t localTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	sto, err := server.DefaultLoader.Load(ep)
+	if err != nil {
+		return nil, err
+	}
+	session, err := t.Transport.NewUploadPackSession(ep, auth)
+	if err != nil {
+		return nil, err
+	}
+	return &localUploadSession{UploadPackSession: session, objects: sto}, nil
+}
+
+type localUploadSession struct {
+	transport.UploadPackSession
+	objects storer.EncodedObjectStorer
+}
+
+func (s *localUploadSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
+	known := req.Haves[:0]
+	for _, h := range req.Haves {
+		if s.objects.HasEncodedObject(h) == nil {
+			known = append(known, h)
+		}
+	}
+	req.Haves = known
+	return s.UploadPackSession.UploadPack(ctx, req)
+}
+
+// nativeGit is the built-in git engine. It needs no git binary, but does
+// not run filters or hooks.
+type nativeGit struct{}
+
+func (nativeGit) Name() string { return "native" }
+
+func (nativeGit) open(dir string) (*git.Repository, error) {
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, &GitError{Op: "open", Err: ErrNotRepository, Detail: dir}
+	}
+	return repo, err
+}
+
+func (nativeGit) Init(dir string) error {
+	// Honour init.defaultBranch like git init does
+	branch := "master"
+	if cfg, err := config.LoadConfig(config.GlobalScope); err == nil && cfg.Init.DefaultBranch != "" {
+		branch = cfg.Init.DefaultBranch
+	}
+	_, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName(branch)},
+	})
+	if errors.Is(err, git.ErrRepositoryAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+func (g nativeGit) RemoteURL(dir, remote string) (string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	r, err := repo.Remote(remote)
+	if err != nil {
+		return "", nativeError("remote", err)
+	}
+	if urls := r.Config().URLs; len(urls) > 0 {
+		return urls[0], nil
+	}
+	return "", &GitError{Op: "remote", Err: ErrRemoteNotFound, Detail: remote}
+}
+
+func (g nativeGit) SetRemote(dir, remote, url string) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	if r, ok := cfg.Remotes[remote]; ok {
+		r.URLs = []string{url}
+	} else {
+		cfg.Remotes[remote] = &config.RemoteConfig{
+			Name:  remote,
+			URLs:  []string{url},
+			Fetch: []config.RefSpec{config.RefSpec("+refs/heads/*:refs/remotes/" + remote + "/*")},
+		}
+	}
+	return repo.SetConfig(cfg)
+}
+
+func (g nativeGit) ListBranches(dir, remote string) ([]string, error) {
+	// remote is either a configured remote or a URL, as for git ls-remote
+	url := remote
+	if repo, err := g.open(dir); err == nil {
+		if r, err := repo.Remote(remote); err == nil && len(r.Config().URLs) > 0 {
+			url = r.Config().URLs[0]
+		}
+	}
+	auth, err := remoteAuth(url)
+	if err != nil {
+		return nil, err
+	}
+	r := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "anonymous", URLs: []string{url}})
+	refs, err := r.List(&git.ListOptions{Auth: auth})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, nativeError("ls-remote", err)
+	}
+	branches := []string{}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches = append(branches, ref.Name().Short())
+		}
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+func (g nativeGit) Add(dir string, paths []string) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nativeError("add", wt.AddWithOptions(&git.AddOptions{All: true}))
+	}
+	for _, p := range paths {
+		// A path missing from the worktree is staged as a removal
+		opts := &git.AddOptions{Path: filepath.ToSlash(p), SkipStatus: true}
+		if err := wt.AddWithOptions(opts); err != nil {
+			return nativeError("add", err)
+		}
+	}
+	return nil
+}
+
+func (g nativeGit) StageContent(dir, path string, content []byte) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	obj := repo.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return nativeError("hash-object", err)
+	}
+
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nativeError("update-index", err)
+	}
+	name := filepath.ToSlash(path)
+	entry, err := idx.Entry(name)
+	if err != nil {
+		entry = idx.Add(name)
+	}
+	entry.Hash = hash
+	entry.Mode = filemode.Regular
+	entry.Size = uint32(len(content))
+	entry.ModifiedAt = time.Now()
+	return nativeError("update-index", repo.Storer.SetIndex(idx))
+}
+
+func (g nativeGit) Commit(dir, message string) (string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return "", err
+	}
+	staged := false
+	for _, s := range status {
+		if s.Staging != git.Unmodified && s.Staging != git.Untracked {
+			staged = true
+			break
+		}
+	}
+	if !staged {
+		return "", &GitError{Op: "commit", Err: ErrNothingToCommit}
+	}
+
+	opts := &git.CommitOptions{}
+	if err := opts.Validate(repo); errors.Is(err, git.ErrMissingAuthor) {
+		// No user.name/user.email anywhere: sign as the agent on this host
+		opts.Author = defaultSignature()
+	}
+	hash, err := wt.Commit(message, opts)
+	if err != nil {
+		return "", nativeError("commit", err)
+	}
+	return hash.String(), nil
+}
+
+// defaultSignature names the agent from spirit.json and the host.
+func defaultSignature() *object.Signature {
+	name := "spirit"
+	if cfg, err := loadConfig(); err == nil && cfg.Identity.Name != "" {
+		name = cfg.Identity.Name
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	return &object.Signature{Name: name, Email: "spirit@" + host, When: time.Now()}
+}
+
+func (g nativeGit) Head(dir string) (string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", nativeError("rev-parse", err)
+	}
+	return head.Hash().String(), nil
+}
+
+func (g nativeGit) LastCommitTime(dir string) (time.Time, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return time.Time{}, nativeError("log", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return commit.Committer.When, nil
+}
+
+func (g nativeGit) IndexedFiles(dir string) ([]string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(idx.Entries))
+	for _, e := range idx.Entries {
+		files = append(files, e.Name)
+	}
+	return files, nil
+}
+
+func (g nativeGit) IsDirty(dir string) (bool, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return false, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return false, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return false, err
+	}
+	return !status.IsClean(), nil
+}
+
+func (g nativeGit) Fetch(dir, remote string) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	url, err := g.RemoteURL(dir, remote)
+	if err != nil {
+		return err
+	}
+	auth, err := remoteAuth(url)
+	if err != nil {
+		return err
+	}
+	err = repo.Fetch(&git.FetchOptions{RemoteName: remote, Auth: auth})
+	if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) || errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil
+	}
+	return nativeError("fetch", err)
+}
+
+func (g nativeGit) CurrentBranch(dir string) (string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		return "", &GitError{Op: "symbolic-ref", Detail: "HEAD is detached"}
+	}
+	return head.Target().Short(), nil
+}
+
+func (g nativeGit) RemoteHead(dir, remote string) (string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	r, err := repo.Remote(remote)
+	if err != nil {
+		return "", nativeError("ls-remote", err)
+	}
+	if len(r.Config().URLs) == 0 {
+		return "", &GitError{Op: "ls-remote", Err: ErrRemoteNotFound, Detail: remote + " has no URL"}
+	}
+	auth, err := remoteAuth(r.Config().URLs[0])
+	if err != nil {
+		return "", err
+	}
+	refs, err := r.List(&git.ListOptions{Auth: auth})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return "", nativeError("ls-remote", err)
+	}
+
+	branches := map[plumbing.ReferenceName]bool{}
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		switch {
+		case ref.Name() == plumbing.HEAD:
+			head = ref
+		case ref.Name().IsBranch():
+			branches[ref.Name()] = true
+		}
+	}
+	if head != nil && head.Type() == plumbing.SymbolicReference && branches[head.Target()] {
+		return head.Target().Short(), nil
+	}
+	// HEAD names a branch that was never pushed: settle for the only one
+	if len(branches) == 1 {
+		for name := range branches {
+			return name.Short(), nil
+		}
+	}
+	return "", &GitError{Op: "ls-remote", Err: ErrRemoteBranchNotFound, Detail: remote + " HEAD"}
+}
+
+// trackingRef returns the fetched ref of a remote branch.
+func (nativeGit) trackingRef(repo *git.Repository, remote, branch string) (*plumbing.Reference, error) {
+	ref, err := repo.Reference(plumbing.NewRemoteReferenceName(remote, branch), true)
+	if err != nil {
+		return nil, &GitError{Op: "rev-parse", Err: ErrRemoteBranchNotFound, Detail: remote + "/" + branch}
+	}
+	return ref, nil
+}
+
+func (g nativeGit) AheadBehind(dir, remote, branch string) (int, int, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	upstream, err := g.trackingRef(repo, remote, branch)
+	if err != nil {
+		return 0, 0, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return 0, 0, nativeError("rev-list", err)
+	}
+	ours, err := ancestors(repo, head.Hash())
+	if err != nil {
+		return 0, 0, err
+	}
+	theirs, err := ancestors(repo, upstream.Hash())
+	if err != nil {
+		return 0, 0, err
+	}
+	ahead, behind := 0, 0
+	for h := range ours {
+		if !theirs[h] {
+			ahead++
+		}
+	}
+	for h := range theirs {
+		if !ours[h] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// ancestors returns every commit reachable from hash, itself included.
+func ancestors(repo *git.Repository, hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	iter, err := repo.Log(&git.LogOptions{From: hash})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[plumbing.Hash]bool{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+	return seen, err
+}
+
+// Pull fast-forwards the current branch. Unlike git pull --rebase, local
+// commits are never rewritten: a diverged history is reported instead.
+func (g nativeGit) Pull(dir, remote, branch string) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	upstream, err := g.trackingRef(repo, remote, branch)
+	if err != nil {
+		return err
+	}
+
+	head, err := repo.Head()
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+		// No local commits yet: adopt the remote history
+	case err != nil:
+		return err
+	case head.Hash() == upstream.Hash():
+		return nil
+	default:
+		local, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+		theirs, err := repo.CommitObject(upstream.Hash())
+		if err != nil {
+			return err
+		}
+		if ahead, err := theirs.IsAncestor(local); err != nil || ahead {
+			return err
+		}
+		if behind, err := local.IsAncestor(theirs); err != nil {
+			return err
+		} else if !behind {
+			return &GitError{Op: "pull", Err: ErrDiverged, Detail: "install git to rebase, or merge by hand"}
+		}
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := wt.Reset(&git.ResetOptions{Commit: upstream.Hash(), Mode: git.MergeReset}); err != nil {
+		return nativeError("pull", err)
+	}
+	return nil
+}
+
+func (g nativeGit) Push(dir, remote, branch string) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nativeError("push", err)
+	}
+	url, err := g.RemoteURL(dir, remote)
+	if err != nil {
+		return err
+	}
+	auth, err := remoteAuth(url)
+	if err != nil {
+		return err
+	}
+	target := plumbing.NewBranchReferenceName(branch)
+	err = repo.Push(&git.PushOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(head.Name().String() + ":" + target.String())},
+		Auth:       auth,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nativeError("push", err)
+	}
+
+	// Track the remote branch after the first push, like git push -u
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	local := head.Name().Short()
+	if b, ok := cfg.Branches[local]; ok && b.Remote != "" {
+		return nil
+	}
+	cfg.Branches[local] = &config.Branch{Name: local, Remote: remote, Merge: target}
+	return repo.SetConfig(cfg)
+}
+
+func (g nativeGit) ResolveCommit(dir, rev string) (string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return "", err
+	}
+	// Annotated tags resolve to the commit they point at
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", &GitError{Op: "rev-parse", Err: ErrUnknownRevision, Detail: rev}
+	}
+	if _, err := repo.CommitObject(*hash); err != nil {
+		return "", &GitError{Op: "rev-parse", Err: ErrUnknownRevision, Detail: rev}
+	}
+	return hash.String(), nil
+}
+
+func (g nativeGit) Log(dir, rev string, since time.Time, limit int) ([]commitInfo, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	from, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, &GitError{Op: "log", Err: ErrUnknownRevision, Detail: rev}
+	}
+	options := &git.LogOptions{From: *from, Order: git.LogOrderCommitterTime}
+	if !since.IsZero() {
+		options.Since = &since
+	}
+	iter, err := repo.Log(options)
+	if err != nil {
+		return nil, nativeError("log", err)
+	}
+	defer iter.Close()
+
+	commits := []commitInfo{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if limit > 0 && len(commits) == limit {
+			return storer.ErrStop
+		}
+		changed, err := filesChanged(c)
+		if err != nil {
+			return err
+		}
+		commits = append(commits, commitInfo{
+			Hash:         c.Hash.String(),
+			Time:         c.Committer.When,
+			Message:      strings.TrimSpace(c.Message),
+			FilesChanged: changed,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, nativeError("log", err)
+	}
+	return commits, nil
+}
+
+// filesChanged counts the files a commit changed from its only parent, or
+// added as a root commit.
+func filesChanged(c *object.Commit) (int, error) {
+	if c.NumParents() > 1 {
+		return 0, nil
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return 0, err
+	}
+	var parentTree *object.Tree
+	if c.NumParents() == 1 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return 0, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return 0, err
+		}
+	}
+	changes, err := object.DiffTreeWithOptions(context.Background(), parentTree, tree, object.DefaultDiffTreeOptions)
+	if err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
+
+// commitTree returns the tree of the commit rev names.
+func (g nativeGit) commitTree(dir, rev string) (*object.Tree, error) {
+	hash, err := g.ResolveCommit(dir, rev)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := g.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+func (g nativeGit) Files(dir, commit string) ([]gitFile, error) {
+	tree, err := g.commitTree(dir, commit)
+	if err != nil {
+		return nil, err
+	}
+	files := []gitFile{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode.IsFile() && f.Mode != filemode.Symlink {
+			files = append(files, gitFile{Path: f.Name, Size: f.Size})
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (g nativeGit) ReadFile(dir, commit, path string) ([]byte, error) {
+	tree, err := g.commitTree(dir, commit)
+	if err != nil {
+		return nil, err
+	}
+	file, err := tree.File(filepath.ToSlash(path))
+	if err != nil {
+		return nil, &GitError{Op: "cat-file", Detail: commit + ":" + path}
+	}
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func (g nativeGit) Tag(dir, name, commit string) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	hash, err := g.ResolveCommit(dir, commit)
+	if err != nil {
+		return err
+	}
+	_, err = repo.CreateTag(name, plumbing.NewHash(hash), nil)
+	return err
+}
+
+func (g nativeGit) Branches(dir string) (map[string]string, error) {
+	repo, err := g.open(dir)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	branches := map[string]string{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		branches[ref.Name().Short()] = ref.Hash().String()
+		return nil
+	})
+	return branches, err
+}
+
+func (g nativeGit) AttachHead(dir, branch string) error {
+	repo, err := g.open(dir)
+	if err != nil {
+		return err
+	}
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(branch))
+	return repo.Storer.SetReference(head)
+}
+
+// remoteAuth picks credentials for a remote URL. HTTPS remotes use a token
+// from SPIRIT_GIT_TOKEN, GITHUB_TOKEN or GITLAB_TOKEN; SSH remotes use the
+// SSH agent, or the default key when no agent is running.
+func remoteAuth(rawURL string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch ep.Protocol {
+	case "http", "https":
+		if ep.User != "" {
+			return nil, nil
+		}
+		for _, env := range []struct{ name, user string }{
+			{"SPIRIT_GIT_TOKEN", "x-access-token"},
+			{"GITHUB_TOKEN", "x-access-token"},
+			{"GITLAB_TOKEN", "oauth2"},
+		} {
+			if token := os.Getenv(env.name); token != "" {
+				return &http.BasicAuth{Username: env.user, Password: token}, nil
+			}
+		}
+	case "ssh":
+		if os.Getenv("SSH_AUTH_SOCK") != "" {
+			return nil, nil
+		}
+		home, _ := os.UserHomeDir()
+		for _, key := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			path := filepath.Join(home, ".ssh", key)
+			if _, err := os.Stat(path); err == nil {
+				return ssh.NewPublicKeysFromFile(ep.User, path, "")
+			}
+		}
+	}
+	return nil, nil
+}
+
+// nativeError maps go-git errors onto the engine errors.
+func nativeError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var typed error
+	switch {
+	case errors.Is(err, git.ErrRemoteNotFound):
+		typed = ErrRemoteNotFound
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+		typed = ErrNoCommits
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed):
+		typed = ErrAuthFailed
+	case errors.Is(err, transport.ErrRepositoryNotFound), isNetworkError(err):
+		typed = ErrRemoteUnreachable
+	case errors.Is(err, git.ErrNonFastForwardUpdate), errors.Is(err, git.ErrForceNeeded):
+		typed = ErrDiverged
+	}
+	if typed == nil {
+		return &GitError{Op: op, Detail: err.Error()}
+	}
+	return &GitError{Op: op, Err: typed, Detail: err.Error()}
+}
+
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr)
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// engines returns both git engines, named for subtests.
+func engines() map[string]gitEngine {
+	return map[string]gitEngine{"native": nativeGit{}, "exec": execGit{}}
+}
+
+func TestEnginesReadHistory(t *testing.T) {
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "tagged\n\nSpirit-Kind: backup\nSpirit-Host: vm")
+	runGit(t, dir, "tag", "-a", "-m", "annotated", "v1")
+	head := runGit(t, dir, "rev-parse", "HEAD")
+	first := runGit(t, dir, "rev-parse", "HEAD~2")
+
+	for name, git := range engines() {
+		t.Run(name, func(t *testing.T) {
+			for _, rev := range []string{"HEAD", "main", "v1", head[:7]} {
+				if got, err := git.ResolveCommit(dir, rev); err != nil || got != head {
+					t.Errorf("ResolveCommit(%s) = %s, %v; want %s", rev, got, err, head)
+				}
+			}
+			if _, err := git.ResolveCommit(dir, "nope"); !errors.Is(err, ErrUnknownRevision) {
+				t.Errorf("ResolveCommit(nope) = %v, want ErrUnknownRevision", err)
+			}
+
+			commits, err := git.Log(dir, "HEAD", time.Time{}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(commits) != 3 || commits[0].Hash != head || commits[2].Hash != first {
+				t.Fatalf("Log = %+v", commits)
+			}
+			if changed := [3]int{commits[0].FilesChanged, commits[1].FilesChanged, commits[2].FilesChanged}; changed != [3]int{0, 1, 4} {
+				t.Errorf("files changed = %v, want [0 1 4]", changed)
+			}
+			if c := commits[0]; c.Subject() != "tagged" || c.Trailer(kindTrailer) != "backup" || c.Trailer(hostTrailer) != "vm" {
+				t.Errorf("subject %q, kind %q, host %q", c.Subject(), c.Trailer(kindTrailer), c.Trailer(hostTrailer))
+			}
+			if limited, err := git.Log(dir, "HEAD", time.Time{}, 1); err != nil || len(limited) != 1 {
+				t.Errorf("Log with limit 1 = %d commits, %v", len(limited), err)
+			}
+			if recent, err := git.Log(dir, "HEAD", time.Now().Add(time.Hour), 0); err != nil || len(recent) != 0 {
+				t.Errorf("Log since an hour ahead = %d commits, %v", len(recent), err)
+			}
+
+			files, err := git.Files(dir, first)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 4 {
+				t.Errorf("Files = %+v, want 4 files", files)
+			}
+			content, err := git.ReadFile(dir, first, "memory/2026-01.md")
+			if err != nil || string(content) != "- first day\n" {
+				t.Errorf("ReadFile = %q, %v", content, err)
+			}
+			if _, err := git.ReadFile(dir, first, "missing.md"); err == nil {
+				t.Error("ReadFile of a missing file succeeded")
+			}
+		})
+	}
+}
+
+func TestEnginesAttachDetachedHead(t *testing.T) {
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+
+	for name, git := range engines() {
+		t.Run(name, func(t *testing.T) {
+			runGit(t, dir, "checkout", "--quiet", "--detach")
+			if _, err := git.CurrentBranch(dir); err == nil {
+				t.Fatal("CurrentBranch succeeded on a detached HEAD")
+			}
+			head, _ := git.Head(dir)
+			branches, err := git.Branches(dir)
+			if err != nil || branches["main"] != head {
+				t.Fatalf("Branches = %v, %v; want main at %s", branches, err, head)
+			}
+			if err := git.AttachHead(dir, "main"); err != nil {
+				t.Fatal(err)
+			}
+			if branch, err := git.CurrentBranch(dir); err != nil || branch != "main" {
+				t.Errorf("CurrentBranch = %s, %v; want main", branch, err)
+			}
+		})
+	}
+}
+
+func TestExecUnreachableRemote(t *testing.T) {
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+	missing := filepath.Join(t.TempDir(), "gone.git")
+	runGit(t, dir, "remote", "add", "origin", missing)
+
+	git := execGit{}
+	if err := git.Fetch(dir, "origin"); !errors.Is(err, ErrRemoteUnreachable) {
+		t.Errorf("Fetch = %v, want ErrRemoteUnreachable", err)
+	}
+	if _, err := git.ListBranches(dir, "origin"); !errors.Is(err, ErrRemoteUnreachable) {
+		t.Errorf("ListBranches = %v, want ErrRemoteUnreachable", err)
+	}
+	if err := git.Push(dir, "origin", "main"); !errors.Is(err, ErrRemoteUnreachable) {
+		t.Errorf("Push = %v, want ErrRemoteUnreachable", err)
+	}
+
+	t.Setenv("SPIRIT_GIT", "exec")
+	if err := gitFetch(dir, "origin"); err != nil {
+		t.Errorf("gitFetch of an unreachable remote = %v, want nil", err)
+	}
+}
+
+func TestRemoteFailure(t *testing.T) {
+	cases := map[string]error{
+		"fatal: Authentication failed for 'https://github.com/o/r.git/'":                                  ErrAuthFailed,
+		"git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.":   ErrAuthFailed,
+		"fatal: could not read Username for 'https://github.com': terminal prompts disabled":              ErrAuthFailed,
+		"fatal: unable to access 'https://nohost.invalid/r.git/': Could not resolve host: nohost.invalid": ErrRemoteUnreachable,
+		"ssh: connect to host example.com port 22: Connection refused":                                    ErrRemoteUnreachable,
+		"! [rejected]        main -> main (fetch first)":                                                  ErrDiverged,
+		"fatal: bad object HEAD": nil,
+	}
+	for detail, want := range cases {
+		if got := remoteFailure(detail); got != want {
+			t.Errorf("remoteFailure(%q) = %v, want %v", detail, got, want)
+		}
+	}
+}
+
+func TestNativeRemoteHeadWithoutURL(t *testing.T) {
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+	runGit(t, dir, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+
+	if _, err := (nativeGit{}).RemoteHead(dir, "origin"); !errors.Is(err, ErrRemoteNotFound) {
+		t.Errorf("RemoteHead = %v, want ErrRemoteNotFound", err)
+	}
+}
@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IDENTITY.md and SOUL.md are where an agent's identity really lives.
+// spirit.json keeps a copy in its identity and soul sections, refreshed
+// from the Markdown on every checkpoint, so tools that only read the JSON
+// see the same agent.
+//
+// IDENTITY.md is a list of fields:
+//
+//	- **Name:** Orion
+//	- **Emoji:** 🌌
+//
+// SOUL.md has "## Core Truths", "## Boundaries" and "## Vibe" sections.
+
+var identityFieldPattern = regexp.MustCompile(`^[-*]?\s*\**([A-Za-z][A-Za-z ]*?)\**\s*:\s*\**\s*(.+)$`)
+
+// identityFields reads the "- **Key:** value" or "Key: value" lines of
+// IDENTITY.md, keyed by lower-cased name. The first occurrence wins and
+// placeholders such as "_(none yet)_" are skipped.
+func identityFields(content string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		m := identityFieldPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(m[1]))
+		value := strings.TrimSpace(m[2])
+		// The pattern takes the opening ** of "Key: **value**"; drop its closing
+		if strings.Count(value, "**")%2 == 1 {
+			value = strings.TrimSpace(strings.TrimSuffix(value, "**"))
+		}
+		if n := len(value); n > 1 && (value[0] == '_' || value[0] == '*') && value[n-1] == value[0] {
+			value = strings.TrimSpace(value[1 : n-1])
+		}
+		if strings.Trim(value, "_*") == "" || strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+			continue
+		}
+		if _, ok := fields[key]; !ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// parseIdentity reads the identity fields of IDENTITY.md. "Creature" is
+// the OpenClaw name for the description.
+func parseIdentity(content string) Identity {
+	fields := identityFields(content)
+	description := fields["description"]
+	if description == "" {
+		description = fields["creature"]
+	}
+	return Identity{
+		Name:        fields["name"],
+		Emoji:       fields["emoji"],
+		Email:       fields["email"],
+		Description: description,
+	}
+}
+
+// parseSoul reads the Core Truths, Boundaries and Vibe sections of
+// SOUL.md. A section's items are its bullets, or its paragraphs when it
+// has none; the vibe is the section as one paragraph.
+func parseSoul(content string) Soul {
+	var soul Soul
+	sections := markdownSections(content)
+	if body, ok := sections["core truths"]; ok {
+		soul.CoreTruths = sectionItems(body)
+	}
+	if body, ok := sections["boundaries"]; ok {
+		soul.Boundaries = sectionItems(body)
+	}
+	if body, ok := sections["vibe"]; ok {
+		soul.Vibe = strings.Join(strings.Fields(strings.Join(sectionItems(body), " ")), " ")
+	}
+	return soul
+}
+
+// markdownSections maps each lower-cased "## " heading to the lines below
+// it, up to the next heading of the same or a higher level.
+func markdownSections(content string) map[string][]string {
+	sections := map[string][]string{}
+	current := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "# ") || strings.HasPrefix(trimmed, "## ") {
+			current = ""
+			if strings.HasPrefix(trimmed, "## ") {
+				current = strings.ToLower(strings.TrimSpace(trimmed[3:]))
+				if _, ok := sections[current]; !ok {
+					sections[current] = []string{}
+				}
+			}
+			continue
+		}
+		if current != "" {
+			sections[current] = append(sections[current], line)
+		}
+	}
+	return sections
+}
+
+// sectionItems splits a section into its bullets or, without any, its
+// paragraphs, each joined onto one line. Comment lines are placeholders.
+func sectionItems(lines []string) []string {
+	bullets := []string{}
+	paragraphs := []string{}
+	paragraph := []string{}
+	flush := func() {
+		if len(paragraph) > 0 {
+			paragraphs = append(paragraphs, strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || trimmed == "---" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "<!--"):
+			flush()
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			flush()
+			bullets = append(bullets, strings.TrimSpace(trimmed[2:]))
+		case len(bullets) > 0 && strings.HasPrefix(line, " "):
+			bullets[len(bullets)-1] += " " + trimmed
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+	if len(bullets) > 0 {
+		return bullets
+	}
+	return paragraphs
+}
+
+// identityFromMarkdown reads IDENTITY.md and SOUL.md in dir. Fields the
+// Markdown leaves out are left empty, as is everything when a file is
+// missing.
+func identityFromMarkdown(dir string) (Identity, Soul, bool) {
+	var identity Identity
+	var soul Soul
+	found := false
+	vibe := ""
+	if data, err := os.ReadFile(filepath.Join(dir, "IDENTITY.md")); err == nil {
+		identity = parseIdentity(string(data))
+		vibe = identityFields(string(data))["vibe"]
+		found = true
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "SOUL.md")); err == nil {
+		soul = parseSoul(string(data))
+		found = true
+	}
+	// IDENTITY.md often sums the vibe up when SOUL.md has no section for it
+	if soul.Vibe == "" {
+		soul.Vibe = vibe
+	}
+	return identity, soul, found
+}
+
+// identityDrift lists the spirit.json fields that differ from what the
+// Markdown in dir says, and returns the config as the Markdown would have
+// it. Fields the Markdown does not define are kept.
+func identityDrift(config *Config, dir string) ([]string, *Config) {
+	identity, soul, found := identityFromMarkdown(dir)
+	if !found {
+		return nil, config
+	}
+	updated := *config
+	drift := []string{}
+	set := func(name string, current *string, value string) {
+		if value != "" && *current != value {
+			drift = append(drift, name)
+			*current = value
+		}
+	}
+	setList := func(name string, current *[]string, value []string) {
+		if len(value) > 0 && strings.Join(*current, "\n") != strings.Join(value, "\n") {
+			drift = append(drift, name)
+			*current = value
+		}
+	}
+	set("name", &updated.Identity.Name, identity.Name)
+	set("emoji", &updated.Identity.Emoji, identity.Emoji)
+	set("email", &updated.Identity.Email, identity.Email)
+	set("description", &updated.Identity.Description, identity.Description)
+	set("vibe", &updated.Soul.Vibe, soul.Vibe)
+	setList("core truths", &updated.Soul.CoreTruths, soul.CoreTruths)
+	setList("boundaries", &updated.Soul.Boundaries, soul.Boundaries)
+	return drift, &updated
+}
+
+// syncIdentityConfig brings spirit.json in ConfigDir in step with
+// IDENTITY.md and SOUL.md. When spirit.json was edited after the Markdown,
+// it is left alone and a warning points to 'spirit render', which carries
+// the edit over to the Markdown.
+func syncIdentityConfig() error {
+	config, err := loadConfig()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	drift, updated := identityDrift(config, ConfigDir)
+	if len(drift) == 0 {
+		return nil
+	}
+
+	if configNewerThanMarkdown(ConfigDir) {
+		fmt.Printf("⚠️  spirit.json was edited after IDENTITY.md/SOUL.md and differs from them (%s); kept as is. Run 'spirit render' to update the Markdown\n", strings.Join(drift, ", "))
+		return nil
+	}
+	fmt.Printf("🪪 spirit.json updated from IDENTITY.md/SOUL.md: %s\n", strings.Join(drift, ", "))
+	return saveConfig(updated)
+}
+
+// configNewerThanMarkdown reports whether spirit.json was written after the
+// IDENTITY.md and SOUL.md in dir.
+func configNewerThanMarkdown(dir string) bool {
+	config, err := os.Stat(filepath.Join(ConfigDir, "spirit.json"))
+	if err != nil {
+		return false
+	}
+	for _, name := range []string{"IDENTITY.md", "SOUL.md"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !config.ModTime().After(info.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// repoIdentity returns the repository's own IDENTITY.md and SOUL.md.
+func repoIdentity(t *testing.T) (identity, soul string) {
+	t.Helper()
+	return readTestFile(t, "../../IDENTITY.md"), readTestFile(t, "../../SOUL.md")
+}
+
+func TestIdentityFields(t *testing.T) {
+	identity, _ := repoIdentity(t)
+	cases := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{"repo IDENTITY.md", identity, map[string]string{
+			"name":     "Orion",
+			"emoji":    "🌌",
+			"email":    "orion.gopi@proton.me",
+			"creature": "AI assistant — a digital familiar, something between a helpful ghost and a competent friend",
+			"vibe":     "Sharp but warm. Competent without being corporate. **Builder, not just talker.**",
+		}},
+		{"plain lines", "Name: Vega\nEmoji: ⭐\n", map[string]string{"name": "Vega", "emoji": "⭐"}},
+		{"bold key outside the colon", "* **Name**: Vega\n", map[string]string{"name": "Vega"}},
+		{"bold value", "- Name: **Vega**\n- Vibe: _calm_\n", map[string]string{"name": "Vega", "vibe": "calm"}},
+		{"placeholders", "- **Avatar:** _(none yet)_\n- **Email:** (TBD)\n- **Name:**\n", map[string]string{}},
+		{"first wins", "- **Name:** _(pick one)_\n- **Name:** Vega\n- **Name:** Lyra\n", map[string]string{"name": "Vega"}},
+		{"prose", "Built with Gopi (2026-02-15):\nJust some text.\n", map[string]string{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := identityFields(c.content)
+			for key, want := range c.want {
+				if got[key] != want {
+					t.Errorf("%s = %q, want %q", key, got[key], want)
+				}
+			}
+			if len(c.want) == 0 && len(got) != 0 {
+				t.Errorf("fields = %q, want none", got)
+			}
+			if _, ok := got["avatar"]; ok {
+				t.Error("placeholder avatar was read")
+			}
+		})
+	}
+
+	if got := parseIdentity(identity); got.Description != "AI assistant — a digital familiar, something between a helpful ghost and a competent friend" {
+		t.Errorf("description = %q, want the creature", got.Description)
+	}
+}
+
+func TestParseSoul(t *testing.T) {
+	_, soul := repoIdentity(t)
+	got := parseSoul(soul)
+	if len(got.CoreTruths) != 5 || !strings.HasPrefix(got.CoreTruths[0], "**Be genuinely helpful, not performatively helpful.**") || !strings.HasSuffix(got.CoreTruths[4], "Treat it with respect.") {
+		t.Errorf("core truths = %q, want the five paragraphs", got.CoreTruths)
+	}
+	wantBoundaries := []string{
+		"Private things stay private. Period.",
+		"When in doubt, ask before acting externally.",
+		"Never send half-baked replies to messaging surfaces.",
+		"You're not the user's voice — be careful in group chats.",
+	}
+	if !reflect.DeepEqual(got.Boundaries, wantBoundaries) {
+		t.Errorf("boundaries = %q", got.Boundaries)
+	}
+	if !strings.HasPrefix(got.Vibe, "Be the assistant you'd actually want to talk to.") || !strings.HasSuffix(got.Vibe, "Just... good.") {
+		t.Errorf("vibe = %q", got.Vibe)
+	}
+
+	cases := []struct {
+		name, content string
+		want          Soul
+	}{
+		{"bullets win over paragraphs",
+			"## Core Truths\nIntro text.\n\n- Be helpful\n- Have opinions\n  that wrap\n",
+			Soul{CoreTruths: []string{"Be helpful", "Have opinions that wrap"}}},
+		{"paragraphs joined onto one line",
+			"## Boundaries\nPrivate things\nstay private.\n\nAsk first.\n",
+			Soul{Boundaries: []string{"Private things stay private.", "Ask first."}}},
+		{"vibe as one paragraph",
+			"## Vibe\n- Sharp\n- warm\n",
+			Soul{Vibe: "Sharp warm"}},
+		{"comment placeholders",
+			"## Core Truths\n<!-- What do you believe? -->\n\n## Boundaries\n\n## Vibe\n<!-- vibe -->\n",
+			Soul{CoreTruths: []string{}, Boundaries: []string{}}},
+		{"subsections stay in their section",
+			"## Boundaries\n- Ask first\n### Groups\n- Not the user's voice\n# Appendix\n- not a boundary\n",
+			Soul{Boundaries: []string{"Ask first", "Not the user's voice"}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := parseSoul(c.content); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("parseSoul = %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestIdentityDrift(t *testing.T) {
+	identity, soul := repoIdentity(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{"IDENTITY.md": identity, "SOUL.md": soul} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	created := time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC)
+	config := &Config{
+		Identity: Identity{Name: "Orion", Emoji: "✨", CreatedAt: created},
+		Soul:     Soul{Vibe: "old", Boundaries: parseSoul(soul).Boundaries},
+	}
+
+	drift, updated := identityDrift(config, dir)
+	if want := []string{"emoji", "email", "description", "vibe", "core truths"}; !reflect.DeepEqual(drift, want) {
+		t.Errorf("drift = %v, want %v", drift, want)
+	}
+	if updated.Identity.Emoji != "🌌" || updated.Identity.Email != "orion.gopi@proton.me" || !updated.Identity.CreatedAt.Equal(created) {
+		t.Errorf("updated identity = %+v", updated.Identity)
+	}
+	if config.Identity.Emoji != "✨" {
+		t.Error("identityDrift changed the config it was given")
+	}
+
+	// Without the Markdown nothing drifts
+	if drift, _ := identityDrift(config, t.TempDir()); len(drift) != 0 {
+		t.Errorf("drift without Markdown = %v", drift)
+	}
+}
+
+func TestSyncIdentityConfigKeepsNewerEdit(t *testing.T) {
+	dir := withConfigDir(t)
+	identity, soul := repoIdentity(t)
+	for name, content := range map[string]string{"IDENTITY.md": identity, "SOUL.md": soul} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := saveConfig(&Config{Identity: Identity{Name: "Vega", Emoji: "⭐"}}); err != nil {
+		t.Fatal(err)
+	}
+	touch := func(name string, at time.Time) {
+		if err := os.Chtimes(filepath.Join(dir, name), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+
+	// spirit.json edited after the Markdown is kept
+	touch("IDENTITY.md", now.Add(-2*time.Hour))
+	touch("SOUL.md", now.Add(-2*time.Hour))
+	touch("spirit.json", now.Add(-time.Hour))
+	if err := syncIdentityConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config, err := loadConfig(); err != nil || config.Identity.Name != "Vega" {
+		t.Errorf("newer spirit.json was overwritten: %+v, %v", config, err)
+	}
+
+	// Once the Markdown is edited again it wins
+	touch("IDENTITY.md", now)
+	if err := syncIdentityConfig(); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Identity.Name != "Orion" || config.Identity.Emoji != "🌌" || len(config.Soul.Boundaries) != 4 {
+		t.Errorf("spirit.json not updated from the Markdown: %+v", config)
+	}
+}
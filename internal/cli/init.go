@@ -26,16 +26,28 @@ type Soul struct {
 }
 
 type Config struct {
-	Version   string             `json:"version"`
-	Backends  map[string]Backend `json:"backends"`
-	Identity  Identity           `json:"identity"`
-	Soul      Soul               `json:"soul"`
-	CreatedAt time.Time          `json:"created_at"`
+	Version   string                   `json:"version"`
+	Backends  map[string]BackendConfig `json:"backends"`
+	Identity  Identity                 `json:"identity"`
+	Soul      Soul                     `json:"soul"`
+	Sync      *SyncConfig              `json:"sync,omitempty"`
+	CreatedAt time.Time                `json:"created_at"`
 }
 
-type Backend struct {
-	Type   string            `json:"type"`
-	Config map[string]string `json:"config"`
+// BackendConfig is a backend entry in spirit.json. Type selects the
+// implementation from the backend registry.
+type BackendConfig struct {
+	Type     string            `json:"type"`
+	Config   map[string]string `json:"config"`
+	Disabled bool              `json:"disabled,omitempty"`
+}
+
+// SyncConfig controls how a sync pushes to the backends. Concurrency is
+// how many push at once (default 4); Quorum is how many must succeed for
+// the sync to succeed (default all of them).
+type SyncConfig struct {
+	Concurrency int `json:"concurrency,omitempty"`
+	Quorum      int `json:"quorum,omitempty"`
 }
 
 type TrackedConfig struct {
@@ -108,7 +120,7 @@ func initializeSpiritWorkspace(name, emoji, email, workspaceDir string) error {
 
 	// Default tracked files (OpenClaw-friendly)
 	trackedConfig := TrackedConfig{
-		Version: "1.0.0",
+		Version: trackedVersion,
 		Files: []string{
 			"IDENTITY.md",
 			"SOUL.md",
@@ -138,18 +150,11 @@ func initializeSpiritWorkspace(name, emoji, email, workspaceDir string) error {
 		return fmt.Errorf("failed to create symlink: %w", err)
 	}
 
-	// Create identity files in workspace
-	identityContent := fmt.Sprintf("# %s %s\n\nName: %s\nEmoji: %s\n", emoji, name, name, emoji)
-	os.WriteFile(filepath.Join(workspaceDir, "IDENTITY.md"), []byte(identityContent), 0644)
-
-	soulContent := "# SOUL\n\nTODO: Define personality, behavior, boundaries\n"
-	os.WriteFile(filepath.Join(workspaceDir, "SOUL.md"), []byte(soulContent), 0644)
-
 	// Write spirit.json with workspace reference
 	config := Config{
-		Version: "1.1.0",
+		Version:  configVersion,
 		Identity: Identity{Name: name, Emoji: emoji, Email: email, CreatedAt: time.Now()},
-		Backends: map[string]Backend{
+		Backends: map[string]BackendConfig{
 			"workspace": {Type: "workspace", Config: map[string]string{"path": workspaceDir}},
 		},
 		CreatedAt: time.Now(),
@@ -158,6 +163,11 @@ func initializeSpiritWorkspace(name, emoji, email, workspaceDir string) error {
 	configPath := filepath.Join(ConfigDir, "spirit.json")
 	os.WriteFile(configPath, configData, 0600)
 
+	// Create identity files in workspace, keeping any the agent already has
+	if err := writeStarterFiles(workspaceDir, &config, "IDENTITY.md", "SOUL.md"); err != nil {
+		return err
+	}
+
 	// Write README
 	readmeContent := fmt.Sprintf(`# SPIRIT State for %s %s
 
@@ -195,7 +205,7 @@ func initializeSpirit(name, emoji, email string) error {
 	}
 
 	trackedConfig := TrackedConfig{
-		Version: "1.0.0",
+		Version: trackedVersion,
 		Files: []string{
 			"IDENTITY.md", "SOUL.md", "AGENTS.md", "TOOLS.md",
 			"memory/*.md", "projects/*.md", "context/*.md",
@@ -205,25 +215,52 @@ func initializeSpirit(name, emoji, email string) error {
 	os.WriteFile(filepath.Join(ConfigDir, ".spirit-tracked"), trackedData, 0644)
 
 	config := Config{
-		Version: "1.0.0",
-		Identity: Identity{Name: name, Emoji: emoji, Email: email, CreatedAt: time.Now()},
+		Version:   configVersion,
+		Identity:  Identity{Name: name, Emoji: emoji, Email: email, CreatedAt: time.Now()},
 		CreatedAt: time.Now(),
 	}
 	configData, _ := json.MarshalIndent(config, "", "  ")
 	os.WriteFile(filepath.Join(ConfigDir, "spirit.json"), configData, 0600)
 
-	readmeContent := fmt.Sprintf("# SPIRIT State for %s %s\n\nRun: spirit sync\n", emoji, name)
-	os.WriteFile(filepath.Join(ConfigDir, "README.md"), []byte(readmeContent), 0644)
+	if err := writeStarterFiles(ConfigDir, &config, "IDENTITY.md", "SOUL.md", "README.md"); err != nil {
+		return err
+	}
 
 	fmt.Printf("🌌 SPIRIT initialized for '%s'\n", name)
 	fmt.Printf("📁 State directory: %s\n", ConfigDir)
 	return nil
 }
 
+func loadConfig() (*Config, error) {
+	path := filepath.Join(ConfigDir, "spirit.json")
+	if err := configSchemaFile.upgradeFile(path); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse spirit.json: %w", err)
+	}
+	return &config, nil
+}
+
+func saveConfig(config *Config) error {
+	config.Version = configVersion
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(ConfigDir, "spirit.json"), data, 0600)
+}
+
 func formatBulletList(items []string) string {
 	var result strings.Builder
 	for _, item := range items {
-		result.WriteString(fmt.Sprintf("- %s\n", item))
+		// Further lines are indented so they stay part of the bullet
+		result.WriteString(fmt.Sprintf("- %s\n", strings.ReplaceAll(item, "\n", "\n  ")))
 	}
 	return result.String()
 }
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+)
+
+func keyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Manage encryption keys",
+		Long: `Encrypt SPIRIT state before it leaves this machine.
+
+Once a key is generated, every tracked file is stored as age ciphertext in
+git history and on object backends. The working tree stays plaintext, and
+restore and migrate decrypt transparently.
+
+The private key is kept in ~/.spirit/keys/ and is never synced. Back it up
+somewhere safe: without it, preserved state cannot be recovered.
+
+Examples:
+  spirit key generate
+  SPIRIT_PASSPHRASE=... spirit key generate --passphrase
+  spirit key add-recipient age1...
+  spirit key rotate`,
+	}
+
+	generate := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a key and enable encryption",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			passphrase, _ := cmd.Flags().GetBool("passphrase")
+			return withLock(cmd.CommandPath(), func() error {
+				return generateKey(passphrase)
+			})
+		},
+	}
+	generate.Flags().Bool("passphrase", false, "Encrypt with SPIRIT_PASSPHRASE instead of an X25519 key")
+
+	cmd.AddCommand(generate)
+	cmd.AddCommand(&cobra.Command{
+		Use:   "add-recipient <age1...>",
+		Short: "Also encrypt to another public key (e.g. a second machine)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withLock(cmd.CommandPath(), func() error {
+				return addRecipient(args[0])
+			})
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rotate",
+		Short: "Replace this machine's key and re-encrypt tracked files",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withLock(cmd.CommandPath(), func() error {
+				return rotateKey()
+			})
+		},
+	})
+
+	return cmd
+}
+
+func identityPath() string {
+	return filepath.Join(keysDir(), "identity.txt")
+}
+
+// writeNewIdentity creates a fresh X25519 identity in keys/identity.txt and
+// returns its public key.
+func writeNewIdentity() (string, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(keysDir(), 0700); err != nil {
+		return "", err
+	}
+
+	recipient := identity.Recipient().String()
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), recipient, identity.String())
+	if err := os.WriteFile(identityPath(), []byte(content), 0600); err != nil {
+		return "", err
+	}
+	return recipient, nil
+}
+
+// ownRecipient returns the public key of this machine's current identity.
+func ownRecipient() (string, error) {
+	data, err := os.ReadFile(identityPath())
+	if err != nil {
+		return "", err
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	for _, id := range identities {
+		if x, ok := id.(*age.X25519Identity); ok {
+			return x.Recipient().String(), nil
+		}
+	}
+	return "", fmt.Errorf("no X25519 identity in %s", identityPath())
+}
+
+func generateKey(passphrase bool) error {
+	if _, err := os.Stat(ConfigDir); os.IsNotExist(err) {
+		return fmt.Errorf("spirit not initialized. Run: spirit init")
+	}
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if config.Enabled {
+		return fmt.Errorf("encryption already enabled (%s). Use: spirit key rotate", config.Mode)
+	}
+
+	if passphrase {
+		if os.Getenv("SPIRIT_PASSPHRASE") == "" {
+			return fmt.Errorf("set SPIRIT_PASSPHRASE before running with --passphrase")
+		}
+		config = &EncryptionConfig{Enabled: true, Mode: "passphrase"}
+		fmt.Println("🔑 Using passphrase from SPIRIT_PASSPHRASE")
+	} else {
+		if _, err := os.Stat(identityPath()); err == nil {
+			return fmt.Errorf("%s already exists. Use: spirit key rotate", identityPath())
+		}
+		recipient, err := writeNewIdentity()
+		if err != nil {
+			return fmt.Errorf("cannot create key: %w", err)
+		}
+		config = &EncryptionConfig{Enabled: true, Mode: "x25519", Recipients: []string{recipient}}
+		fmt.Printf("🔑 Generated %s\n", identityPath())
+		fmt.Printf("   Public key: %s\n", recipient)
+	}
+
+	if err := saveEncryptionConfig(config); err != nil {
+		return err
+	}
+	if err := installCryptFilter(); err != nil {
+		return fmt.Errorf("cannot install git filter: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(ConfigDir, ".git")); err == nil {
+		if err := gitAddFiles([]string{".gitattributes", ".gitignore"}); err != nil {
+			return fmt.Errorf("git add failed: %w", err)
+		}
+		if err := reencryptTracked("Enable encryption"); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("✅ Encryption enabled")
+	fmt.Println("   ⚠️  Earlier checkpoints remain plaintext in git history")
+	if passphrase {
+		fmt.Println("   SPIRIT_PASSPHRASE must be set whenever spirit commits or restores")
+	} else {
+		fmt.Println("   Back up keys/identity.txt — it is never synced")
+	}
+	return nil
+}
+
+func addRecipient(recipient string) error {
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if !config.Enabled {
+		return fmt.Errorf("encryption not enabled. Run: spirit key generate")
+	}
+	if config.Mode != "x25519" {
+		return fmt.Errorf("recipients are not used in %s mode", config.Mode)
+	}
+
+	recipient = strings.TrimSpace(recipient)
+	if _, err := age.ParseX25519Recipient(recipient); err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	for _, r := range config.Recipients {
+		if r == recipient {
+			fmt.Println("✅ Recipient already present")
+			return nil
+		}
+	}
+
+	config.Recipients = append(config.Recipients, recipient)
+	if err := saveEncryptionConfig(config); err != nil {
+		return err
+	}
+	if err := reencryptTracked("Add encryption recipient"); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Added recipient %s\n", recipient)
+	fmt.Printf("   Tracked files are now encrypted to %d keys\n", len(config.Recipients))
+	return nil
+}
+
+func rotateKey() error {
+	config, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	if !config.Enabled {
+		return fmt.Errorf("encryption not enabled. Run: spirit key generate")
+	}
+	if config.Mode != "x25519" {
+		return fmt.Errorf("rotate works with X25519 keys; change SPIRIT_PASSPHRASE and run 'spirit key generate' on a fresh state instead")
+	}
+
+	oldRecipient, err := ownRecipient()
+	if err != nil {
+		return fmt.Errorf("cannot read current key: %w", err)
+	}
+
+	// The old identity is kept so earlier checkpoints stay readable
+	retired := filepath.Join(keysDir(), fmt.Sprintf("identity-%s.txt", time.Now().Format("20060102-150405")))
+	if err := os.Rename(identityPath(), retired); err != nil {
+		return err
+	}
+	newRecipient, err := writeNewIdentity()
+	if err != nil {
+		os.Rename(retired, identityPath())
+		return fmt.Errorf("cannot create key: %w", err)
+	}
+
+	recipients := []string{newRecipient}
+	for _, r := range config.Recipients {
+		if r != oldRecipient {
+			recipients = append(recipients, r)
+		}
+	}
+	config.Recipients = recipients
+	if err := saveEncryptionConfig(config); err != nil {
+		return err
+	}
+	if err := reencryptTracked("Rotate encryption key"); err != nil {
+		return err
+	}
+
+	fmt.Printf("🔑 Rotated key\n")
+	fmt.Printf("   New public key: %s\n", newRecipient)
+	fmt.Printf("   Old key kept at %s for earlier checkpoints\n", retired)
+	return nil
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockFile serialises commands that stage, commit or push in ConfigDir.
+// It is advisory: it only keeps spirit processes from running git at the
+// same time.
+const lockFile = ".spirit.lock"
+
+// lockStaleAfter is how long a lock taken on another host is trusted, as
+// its process cannot be checked from here.
+const lockStaleAfter = time.Hour
+
+// lockTimeout and lockNoWait are set by the global --lock-timeout and
+// --no-wait flags.
+var (
+	lockTimeout = 2 * time.Minute
+	lockNoWait  bool
+)
+
+// ErrLocked is returned when another spirit process holds the lock.
+var ErrLocked = errors.New("another spirit command is running")
+
+// LockInfo records who holds the lock.
+type LockInfo struct {
+	PID      int       `json:"pid"`
+	Host     string    `json:"host"`
+	Command  string    `json:"command"`
+	Acquired time.Time `json:"acquired"`
+}
+
+func (l *LockInfo) String() string {
+	return fmt.Sprintf("%s (PID %d on %s, for %s)", l.Command, l.PID, l.Host, formatDuration(time.Since(l.Acquired)))
+}
+
+// Stale reports whether the holder is gone: its process has exited, or it
+// was taken on another host too long ago.
+func (l *LockInfo) Stale() bool {
+	host, _ := os.Hostname()
+	if l.Host == host {
+		return !processAlive(l.PID)
+	}
+	return time.Since(l.Acquired) > lockStaleAfter
+}
+
+func lockPath() string {
+	return filepath.Join(ConfigDir, lockFile)
+}
+
+// readLock returns the current holder, or nil when the lock is free.
+func readLock() (*LockInfo, error) {
+	data, err := os.ReadFile(lockPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		// Not written by spirit: treat it as long abandoned
+		return &LockInfo{Command: "unknown"}, nil
+	}
+	return &info, nil
+}
+
+// acquireLock takes the lock for command, waiting up to lockTimeout for the
+// current holder unless --no-wait is set. The returned function releases it.
+func acquireLock(command string) (func(), error) {
+	return takeLock(command, !lockNoWait)
+}
+
+// tryLock takes the lock for command only if it is free, for callers such
+// as the daemon that retry later instead of blocking.
+func tryLock(command string) (func(), error) {
+	return takeLock(command, false)
+}
+
+func takeLock(command string, wait bool) (func(), error) {
+	if _, err := os.Stat(ConfigDir); os.IsNotExist(err) {
+		// Nothing to protect yet; the command reports the missing state
+		return func() {}, nil
+	}
+	if err := ensureGitignore(lockFile, lockFile+".*"); err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	me := LockInfo{PID: os.Getpid(), Host: host, Command: command, Acquired: time.Now()}
+	data, err := json.Marshal(me)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock is written aside and linked into place, so it never exists
+	// without its content
+	tmp := fmt.Sprintf("%s.%d", lockPath(), me.PID)
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return nil, fmt.Errorf("cannot write lock: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	deadline := time.Now().Add(lockTimeout)
+	waiting := false
+	for {
+		err := os.Link(tmp, lockPath())
+		if err == nil {
+			return func() { releaseLock(me) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("cannot create lock: %w", err)
+		}
+
+		holder, err := readLock()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case holder == nil:
+			continue
+		case holder.PID == me.PID && holder.Host == me.Host:
+			// Already held by this process, e.g. restore's safety checkpoint
+			return func() {}, nil
+		case holder.Stale():
+			fmt.Printf("🔓 Removing stale lock: %s\n", holder)
+			removeLockIf(*holder)
+			continue
+		case !wait:
+			return nil, fmt.Errorf("%w: %s", ErrLocked, holder)
+		case time.Now().After(deadline):
+			return nil, fmt.Errorf("%w: %s (waited %s)", ErrLocked, holder, lockTimeout)
+		case !waiting:
+			fmt.Printf("⏳ Waiting for %s\n", holder)
+			waiting = true
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// releaseLock removes the lock if it is still ours.
+func releaseLock(me LockInfo) {
+	removeLockIf(me)
+}
+
+// removeLockIf removes the lock only while it still records holder, so a
+// lock that was taken over in the meantime is left alone.
+func removeLockIf(holder LockInfo) {
+	current, err := readLock()
+	if err != nil || current == nil {
+		return
+	}
+	if current.PID == holder.PID && current.Host == holder.Host && current.Acquired.Equal(holder.Acquired) {
+		os.Remove(lockPath())
+	}
+}
+
+// withLock runs fn while holding the lock.
+func withLock(command string, fn func() error) error {
+	release, err := acquireLock(command)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
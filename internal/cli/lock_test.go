@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTryLockDoesNotWait(t *testing.T) {
+	withGitEnv(t)
+	newStateRepo(t, "main")
+
+	// Held by the parent process, which outlives the test
+	host, _ := os.Hostname()
+	data, err := json.Marshal(LockInfo{PID: os.Getppid(), Host: host, Command: "spirit sync", Acquired: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath(), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := tryLock("spirit daemon (checkpoint)"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("tryLock = %v, want ErrLocked", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("tryLock waited %s", waited)
+	}
+
+	if err := os.Remove(lockPath()); err != nil {
+		t.Fatal(err)
+	}
+	release, err := tryLock("spirit daemon (checkpoint)")
+	if err != nil {
+		t.Fatalf("tryLock of a free lock = %v", err)
+	}
+	release()
+	if holder, err := readLock(); err != nil || holder != nil {
+		t.Errorf("after release the lock is held by %v, %v", holder, err)
+	}
+}
@@ -0,0 +1,17 @@
+package cli
+
+import "os"
+
+// processAlive reports whether a process with pid exists on this host.
+// FindProcess opens the process on Windows, so it fails once it has exited.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Kinds of commits spirit makes. New commits record their kind and host in
+// trailers; older ones are classified from their message conventions.
+const (
+	kindCheckpoint = "checkpoint"
+	kindSync       = "sync"
+	kindBackup     = "backup"
+	kindAuto       = "auto"
+	kindOther      = "other"
+
+	kindTrailer = "Spirit-Kind"
+	hostTrailer = "Spirit-Host"
+)
+
+// LogEntry is one commit in the SPIRIT history.
+type LogEntry struct {
+	ID           string    `json:"id"`
+	Kind         string    `json:"kind"`
+	Message      string    `json:"message"`
+	FilesChanged int       `json:"files_changed"`
+	Size         int64     `json:"size"`
+	Timestamp    time.Time `json:"timestamp"`
+	Host         string    `json:"host,omitempty"`
+}
+
+var checkpointPrefix = regexp.MustCompile(`^\[\d{2}:\d{2}:\d{2}\] `)
+
+// spiritCommitMessage adds the kind and host trailers to a commit subject.
+func spiritCommitMessage(kind, subject string) string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s\n\n%s: %s\n%s: %s", subject, kindTrailer, kind, hostTrailer, host)
+}
+
+func logCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "List checkpoints, syncs and backups",
+		Long: `Show the SPIRIT history with the kind of each entry, its message, how
+many files it changed, the total size of the tracked state and the host
+it was made on.
+
+Examples:
+  spirit log
+  spirit log --since=24h --kind=auto
+  spirit log --since=2026-01-01 --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, _ := cmd.Flags().GetString("since")
+			kind, _ := cmd.Flags().GetString("kind")
+			asJSON, _ := cmd.Flags().GetBool("json")
+			limit, _ := cmd.Flags().GetInt("limit")
+			return showLog(since, kind, limit, asJSON)
+		},
+	}
+
+	cmd.Flags().String("since", "", "Only entries newer than a duration (24h, 7d) or date")
+	cmd.Flags().String("kind", "", "Only entries of this kind: checkpoint, sync, backup or auto")
+	cmd.Flags().Bool("json", false, "Print entries as JSON")
+	cmd.Flags().IntP("limit", "n", 20, "Maximum number of entries (0 for all)")
+
+	return cmd
+}
+
+func showLog(since, kind string, limit int, asJSON bool) error {
+	switch kind {
+	case "", kindCheckpoint, kindSync, kindBackup, kindAuto, kindOther:
+	default:
+		return fmt.Errorf("unknown kind %q (use checkpoint, sync, backup or auto)", kind)
+	}
+
+	entries, err := readLog(since)
+	if err != nil {
+		return err
+	}
+
+	filtered := []LogEntry{}
+	for _, e := range entries {
+		if kind != "" && e.Kind != kind {
+			continue
+		}
+		if limit > 0 && len(filtered) == limit {
+			break
+		}
+		e.Size = treeSize(e.ID)
+		filtered = append(filtered, e)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(filtered, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(filtered) == 0 {
+		fmt.Println("No entries")
+		return nil
+	}
+	fmt.Println("🌌 SPIRIT log")
+	fmt.Println()
+	for _, e := range filtered {
+		host := e.Host
+		if host == "" {
+			host = "-"
+		}
+		fmt.Printf("   %s  %s  %-10s  %s\n", e.ID[:7], e.Timestamp.Local().Format("2006-01-02 15:04"), e.Kind, e.Message)
+		fmt.Printf("            %d files changed, %s, on %s\n", e.FilesChanged, formatSize(e.Size), host)
+	}
+	return nil
+}
+
+// readLog parses the history of ConfigDir, newest first.
+func readLog(since string) ([]LogEntry, error) {
+	if _, err := os.Stat(ConfigDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("spirit not initialized. Run: spirit init")
+	}
+	var after time.Time
+	if since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			return nil, err
+		}
+		after = t
+	}
+
+	git, err := activeGit()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := git.Head(ConfigDir); errors.Is(err, ErrNoCommits) || errors.Is(err, ErrNotRepository) {
+		return []LogEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	commits, err := git.Log(ConfigDir, "HEAD", after, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read history: %w", err)
+	}
+
+	entries := []LogEntry{}
+	for _, c := range commits {
+		entry := LogEntry{
+			ID:           c.Hash,
+			Timestamp:    c.Time,
+			Host:         c.Trailer(hostTrailer),
+			FilesChanged: c.FilesChanged,
+		}
+		entry.Kind, entry.Message = classifyCommit(c.Subject(), c.Trailer(kindTrailer))
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// classifyCommit returns the kind of a commit and its message without the
+// conventions spirit adds to subjects.
+func classifyCommit(subject, kind string) (string, string) {
+	message := subject
+	if checkpointPrefix.MatchString(subject) {
+		message = checkpointPrefix.ReplaceAllString(subject, "")
+	}
+	if kind != "" {
+		return kind, message
+	}
+
+	switch {
+	case strings.HasPrefix(subject, "SPIRIT sync:"):
+		return kindSync, message
+	case !checkpointPrefix.MatchString(subject):
+		return kindOther, message
+	case strings.HasPrefix(message, "Auto-checkpoint"):
+		return kindAuto, message
+	case strings.HasPrefix(message, "Backup at") || message == "Scheduled backup":
+		return kindBackup, message
+	}
+	return kindCheckpoint, message
+}
+
+// parseSince turns "24h", "7d" or a date into the time entries must follow.
+func parseSince(since string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(since, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, ok := parseRestoreTime(since); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (use e.g. 24h, 7d or 2006-01-02)", since)
+}
+
+// treeSize returns the total size of the files in a commit.
+func treeSize(commit string) int64 {
+	git, err := activeGit()
+	if err != nil {
+		return 0
+	}
+	files, err := git.Files(ConfigDir, commit)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+}
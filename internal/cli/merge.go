@@ -0,0 +1,574 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Daily memory logs and PROJECTS.md are written on every machine an agent
+// runs on, so pulls routinely meet changes to the same file from both
+// sides. git hands them to the spirit merge driver, which merges memory
+// logs entry by entry and PROJECTS.md row by row. When both sides changed
+// the same entry, the local version is kept and the other one is recorded
+// for 'spirit conflicts'. Files that cannot be merged by entry get a plain
+// line merge; if that conflicts too, git is handed conflict markers and the
+// pull is retried keeping the local file (see pullKeepingLocal).
+
+// keepLocalEnv tells the merge driver to keep the local side of a file it
+// cannot merge instead of failing with conflict markers. pullKeepingLocal
+// sets it when it retries a pull.
+const keepLocalEnv = "SPIRIT_MERGE_KEEP_LOCAL"
+
+const mergeAttributes = `# Managed by spirit: merge memory logs and projects by entry
+/memory/*.md merge=spirit
+/PROJECTS.md merge=spirit
+`
+
+// installMergeDriver registers the spirit merge driver in the repository
+// at dir. The attributes live in .git/info, so they are never committed.
+func installMergeDriver(dir string) error {
+	exe, err := spiritExecutable()
+	if err != nil {
+		return err
+	}
+
+	settings := [][2]string{
+		{"merge.spirit.name", "spirit memory log and project merge"},
+		{"merge.spirit.driver", exe + " merge-driver %O %A %B %P"},
+	}
+	for _, kv := range settings {
+		if _, err := gitOutputIn(dir, "config", kv[0], kv[1]); err != nil {
+			return fmt.Errorf("git config %s: %w", kv[0], err)
+		}
+	}
+
+	infoDir, err := gitOutputIn(dir, "rev-parse", "--git-path", "info")
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(infoDir) {
+		infoDir = filepath.Join(dir, infoDir)
+	}
+	attributesPath := filepath.Join(infoDir, "attributes")
+	data, _ := os.ReadFile(attributesPath)
+	if strings.Contains(string(data), mergeAttributes) {
+		return nil
+	}
+	if err := os.MkdirAll(infoDir, 0755); err != nil {
+		return err
+	}
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return os.WriteFile(attributesPath, []byte(content+mergeAttributes), 0644)
+}
+
+func mergeDriverCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "merge-driver <base> <current> <other> <path>",
+		Short:  "Git merge driver for memory logs and PROJECTS.md (internal)",
+		Hidden: true,
+		Args:   cobra.ExactArgs(4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMergeDriver(args[0], args[1], args[2], args[3])
+		},
+	}
+}
+
+// runMergeDriver merges the three versions git passes and writes the
+// result over the current one. Conflicting entries are resolved in favour
+// of the local side and recorded. A file that cannot be merged by entry is
+// merged by line, and left with conflict markers if that fails too.
+func runMergeDriver(basePath, currentPath, otherPath, file string) error {
+	// git runs merge drivers from the top of the work tree
+	if wd, err := os.Getwd(); err == nil {
+		ConfigDir = wd
+	}
+
+	encrypted := false
+	sides := make([]string, 3)
+	for i, p := range []string{basePath, currentPath, otherPath} {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if isEncrypted(content) {
+			encrypted = true
+			if content, err = decryptContent(content); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+		sides[i] = string(content)
+	}
+
+	base, local, remote := sides[0], sides[1], sides[2]
+	// A rebase replays the local commits onto the remote branch, so there
+	// the current side is the remote one
+	if rebaseInProgress() {
+		local, remote = remote, local
+	}
+
+	merged, conflicts, ok := mergeStructured(file, base, local, remote)
+	var conflictErr error
+	if !ok {
+		var clean bool
+		if merged, clean = mergeLines(base, local, remote); !clean {
+			if os.Getenv(keepLocalEnv) != "" {
+				merged, conflicts = local, []Conflict{newConflict(file, "", &local, &remote, local)}
+			} else {
+				conflictErr = fmt.Errorf("%s: conflicting changes", file)
+			}
+		}
+	}
+	out := []byte(merged)
+	if encrypted {
+		config, err := loadEncryptionConfig()
+		if err != nil {
+			return err
+		}
+		if out, err = encryptContent(config, out); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(currentPath, out, 0644); err != nil {
+		return err
+	}
+	if conflictErr != nil {
+		// git reports the file as conflicted, markers and all
+		return conflictErr
+	}
+	return recordConflicts(conflicts)
+}
+
+// mergeLines merges three versions line by line with git merge-file. It
+// reports whether the merge was clean; if not, the result holds conflict
+// markers.
+func mergeLines(base, local, remote string) (string, bool) {
+	dir, err := os.MkdirTemp("", "spirit-merge-")
+	if err != nil {
+		return local, false
+	}
+	defer os.RemoveAll(dir)
+
+	paths := []string{}
+	for i, content := range []string{local, base, remote} {
+		p := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			return local, false
+		}
+		paths = append(paths, p)
+	}
+	// Exits with the number of conflicts, or a negative status on error
+	output, err := exec.Command("git", append([]string{"merge-file", "-p",
+		"-L", "local", "-L", "base", "-L", "remote"}, paths...)...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128 {
+		return string(output), false
+	}
+	if err != nil {
+		return local, false
+	}
+	return string(output), true
+}
+
+func rebaseInProgress() bool {
+	for _, name := range []string{"rebase-merge", "rebase-apply"} {
+		if _, err := os.Stat(filepath.Join(ConfigDir, ".git", name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeStructured merges the base, local and remote versions of a memory
+// log or PROJECTS.md. It reports false when the file cannot be merged by
+// entry.
+func mergeStructured(file, base, local, remote string) (string, []Conflict, bool) {
+	if path.Base(file) == "PROJECTS.md" {
+		return mergeProjects(file, base, local, remote)
+	}
+	return mergeMemoryLog(file, base, local, remote)
+}
+
+// mergeEntry merges one entry, a nil side being one that does not have it:
+// a change on one side wins over no change on the other, and entries both
+// sides appended lines to keep both additions. Otherwise the local version
+// is kept, or the remote one if the local side deleted it, and the merge
+// reports a conflict.
+func mergeEntry(base, local, remote *string) (merged *string, conflict bool) {
+	switch {
+	case sameEntry(local, remote):
+		return local, false
+	case sameEntry(base, local):
+		return remote, false
+	case sameEntry(base, remote):
+		return local, false
+	case base != nil && local != nil && remote != nil:
+		if appended, ok := mergeAppended(*base, *local, *remote); ok {
+			return &appended, false
+		}
+	}
+	if local == nil {
+		return remote, true
+	}
+	return local, true
+}
+
+func sameEntry(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return strings.TrimRight(*a, " \n") == strings.TrimRight(*b, " \n")
+}
+
+// mergeAppended merges two versions that both only added lines at the end
+// of base: the local additions come first, then the remote ones the local
+// side does not already have.
+func mergeAppended(base, local, remote string) (string, bool) {
+	baseLines := splitLines(strings.TrimRight(base, " \n"))
+	localLines := splitLines(strings.TrimRight(local, " \n"))
+	remoteLines := splitLines(strings.TrimRight(remote, " \n"))
+	if !hasLinePrefix(localLines, baseLines) || !hasLinePrefix(remoteLines, baseLines) {
+		return "", false
+	}
+
+	added := map[string]bool{}
+	for _, line := range localLines[len(baseLines):] {
+		added[line] = true
+	}
+	merged := localLines
+	for _, line := range remoteLines[len(baseLines):] {
+		if !added[line] {
+			merged = append(merged, line)
+		}
+	}
+	return strings.Join(merged, "\n") + "\n", true
+}
+
+func hasLinePrefix(lines, prefix []string) bool {
+	if len(prefix) > len(lines) {
+		return false
+	}
+	for i, line := range prefix {
+		if lines[i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+// memoryEntry is one entry of a memory log: a heading and the lines below
+// it, or a list item that starts with a time.
+type memoryEntry struct {
+	// Key is the first line, numbered when it repeats within the file
+	Key  string
+	Text string
+	// At is the entry's timestamp in sortable form, or the one of the
+	// entry before it when it has none
+	At string
+}
+
+// memoryTimePattern matches "09:14", "9:14:05" or "2026-10-17 09:14" at the
+// start of an entry, after any heading or list marker.
+var memoryTimePattern = regexp.MustCompile(`^(?:(\d{4}-\d{2}-\d{2})[T ])?(\d{1,2}):(\d{2})(?::(\d{2}))?\b`)
+
+func memoryTimestamp(line string) string {
+	text := strings.TrimLeft(strings.TrimSpace(line), "#-*+[`_ ")
+	m := memoryTimePattern.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	hour, seconds := m[2], m[4]
+	if len(hour) == 1 {
+		hour = "0" + hour
+	}
+	if seconds == "" {
+		seconds = "00"
+	}
+	return fmt.Sprintf("%s %s:%s:%s", m[1], hour, m[3], seconds)
+}
+
+// parseMemoryLog splits a memory log into the text before its first entry
+// and its entries. Level-1 headings belong to the preamble.
+func parseMemoryLog(content string) (string, []memoryEntry) {
+	preamble := ""
+	entries := []memoryEntry{}
+	seen := map[string]int{}
+	at := ""
+	inFence := false
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+
+		start := false
+		if !inFence {
+			switch {
+			case strings.HasPrefix(trimmed, "##"):
+				start = true
+			case line == strings.TrimLeft(line, " \t") && isListItem(trimmed):
+				start = memoryTimestamp(trimmed) != ""
+			}
+		}
+
+		if start {
+			if ts := memoryTimestamp(trimmed); ts != "" {
+				at = ts
+			}
+			key := trimmed
+			if seen[trimmed]++; seen[trimmed] > 1 {
+				key = fmt.Sprintf("%s (%d)", trimmed, seen[trimmed])
+			}
+			entries = append(entries, memoryEntry{Key: key, Text: line, At: at})
+			continue
+		}
+		if len(entries) == 0 {
+			preamble += line
+		} else {
+			entries[len(entries)-1].Text += line
+		}
+	}
+	return preamble, entries
+}
+
+// mergeMemoryLog treats memory logs as append-only: entries added on
+// either side are kept and ordered by their timestamps. A file without
+// entries that both sides changed cannot be merged this way.
+func mergeMemoryLog(file, base, local, remote string) (string, []Conflict, bool) {
+	basePre, baseEntries := parseMemoryLog(base)
+	localPre, localEntries := parseMemoryLog(local)
+	remotePre, remoteEntries := parseMemoryLog(remote)
+	conflicts := []Conflict{}
+
+	preamble, conflict := mergeEntry(&basePre, &localPre, &remotePre)
+	if conflict && (len(localEntries) == 0 || len(remoteEntries) == 0) {
+		// Free text rather than a log of entries
+		return "", nil, false
+	}
+	if conflict {
+		conflicts = append(conflicts, newConflict(file, "top of file", &localPre, &remotePre, deref(preamble)))
+	}
+
+	index := func(entries []memoryEntry) map[string]*memoryEntry {
+		m := map[string]*memoryEntry{}
+		for i := range entries {
+			m[entries[i].Key] = &entries[i]
+		}
+		return m
+	}
+	baseByKey, localByKey, remoteByKey := index(baseEntries), index(localEntries), index(remoteEntries)
+	entryText := func(e *memoryEntry) *string {
+		if e == nil {
+			return nil
+		}
+		return &e.Text
+	}
+
+	order := append([]memoryEntry{}, localEntries...)
+	for _, e := range remoteEntries {
+		if localByKey[e.Key] == nil {
+			order = append(order, e)
+		}
+	}
+
+	merged := []memoryEntry{}
+	for _, e := range order {
+		b, l, r := baseByKey[e.Key], localByKey[e.Key], remoteByKey[e.Key]
+		if b == nil && l != nil && r != nil && !sameEntry(&l.Text, &r.Text) {
+			// Both sides wrote an entry under the same heading: keep both
+			merged = append(merged, *l, *r)
+			continue
+		}
+		text, conflict := mergeEntry(entryText(b), entryText(l), entryText(r))
+		if conflict {
+			conflicts = append(conflicts, newConflict(file, e.Key, entryText(l), entryText(r), deref(text)))
+		}
+		if text != nil {
+			e.Text = *text
+			merged = append(merged, e)
+		}
+	}
+
+	// Keep the log's direction, oldest or newest first
+	switch {
+	case entriesSorted(localEntries, false) || (len(localEntries) == 0 && entriesSorted(remoteEntries, false)):
+		sort.SliceStable(merged, func(i, j int) bool { return merged[i].At < merged[j].At })
+	case entriesSorted(localEntries, true):
+		sort.SliceStable(merged, func(i, j int) bool { return merged[i].At > merged[j].At })
+	}
+
+	var out strings.Builder
+	out.WriteString(deref(preamble))
+	for _, e := range merged {
+		written := out.String()
+		if written != "" && !strings.HasSuffix(written, "\n") {
+			out.WriteString("\n")
+			written += "\n"
+		}
+		// Headings are set off from the entry before them
+		if strings.HasPrefix(e.Text, "#") && written != "" && !strings.HasSuffix(written, "\n\n") {
+			out.WriteString("\n")
+		}
+		out.WriteString(e.Text)
+	}
+	result := out.String()
+	if result != "" && !strings.HasSuffix(result, "\n") {
+		result += "\n"
+	}
+	return result, conflicts, true
+}
+
+// entriesSorted reports whether entries are in timestamp order, or in
+// reverse order when desc is set. Logs with fewer than two distinct
+// timestamps count as oldest first.
+func entriesSorted(entries []memoryEntry, desc bool) bool {
+	distinct := 0
+	for i := 1; i < len(entries); i++ {
+		a, b := entries[i-1].At, entries[i].At
+		if a == b {
+			continue
+		}
+		distinct++
+		if (a < b) == desc {
+			return false
+		}
+	}
+	return !desc || distinct > 0
+}
+
+// projectTable holds the data rows of one table in PROJECTS.md, keyed by
+// their first cell, the project ID.
+type projectTable struct {
+	Keys []string
+	Rows map[string]string
+}
+
+// parseProjects splits PROJECTS.md into its tables and a skeleton: the
+// document with every table's data rows replaced by a placeholder line.
+func parseProjects(content string) ([]string, map[string]*projectTable) {
+	lines := splitLines(content)
+	skeleton := []string{}
+	tables := map[string]*projectTable{}
+	seen := map[string]int{}
+	heading := ""
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+		skeleton = append(skeleton, line)
+		if strings.HasPrefix(trimmed, "#") {
+			heading = trimmed
+			continue
+		}
+		// A table starts with a header row directly above its separator
+		if !strings.HasPrefix(trimmed, "|") || i+1 >= len(lines) || !isTableSeparator(strings.TrimSpace(lines[i+1])) {
+			continue
+		}
+		i++
+		skeleton = append(skeleton, strings.TrimRight(lines[i], " \t"))
+
+		name := heading + "\n" + trimmed
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, seen[name])
+		}
+		table := &projectTable{Rows: map[string]string{}}
+		tables[name] = table
+		skeleton = append(skeleton, "\x00"+name)
+
+		for i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "|") {
+			i++
+			row := strings.TrimRight(lines[i], " \t")
+			cells := strings.Split(strings.Trim(strings.TrimSpace(row), "|"), "|")
+			id := strings.TrimSpace(cells[0])
+			if _, ok := table.Rows[id]; !ok {
+				table.Keys = append(table.Keys, id)
+			}
+			table.Rows[id] = row
+		}
+	}
+	return skeleton, tables
+}
+
+// mergeProjects merges PROJECTS.md table rows by project ID. Changes to
+// the text around the tables on both sides cannot be merged this way.
+func mergeProjects(file, base, local, remote string) (string, []Conflict, bool) {
+	baseSkel, baseTables := parseProjects(base)
+	localSkel, localTables := parseProjects(local)
+	remoteSkel, remoteTables := parseProjects(remote)
+
+	b, l, r := strings.Join(baseSkel, "\n"), strings.Join(localSkel, "\n"), strings.Join(remoteSkel, "\n")
+	skeleton := localSkel
+	switch {
+	case l == r, r == b:
+	case l == b:
+		skeleton = remoteSkel
+	default:
+		return "", nil, false
+	}
+
+	row := func(tables map[string]*projectTable, name, id string) *string {
+		table := tables[name]
+		if table == nil {
+			return nil
+		}
+		if text, ok := table.Rows[id]; ok {
+			return &text
+		}
+		return nil
+	}
+
+	conflicts := []Conflict{}
+	out := []string{}
+	for _, line := range skeleton {
+		name, ok := strings.CutPrefix(line, "\x00")
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+
+		ids := []string{}
+		listed := map[string]bool{}
+		for _, tables := range []map[string]*projectTable{localTables, remoteTables} {
+			if table := tables[name]; table != nil {
+				for _, id := range table.Keys {
+					if !listed[id] {
+						listed[id] = true
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+
+		for _, id := range ids {
+			localRow, remoteRow := row(localTables, name, id), row(remoteTables, name, id)
+			merged, conflict := mergeEntry(row(baseTables, name, id), localRow, remoteRow)
+			if conflict {
+				conflicts = append(conflicts, newConflict(file, "project "+id, localRow, remoteRow, deref(merged)))
+			}
+			if merged != nil {
+				out = append(out, *merged)
+			}
+		}
+	}
+	return strings.Join(out, "\n") + "\n", conflicts, true
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeMemoryLogInterleaved(t *testing.T) {
+	base := "# 2026-10-17\n\n- 09:00 Standup\n"
+	local := base + "- 10:00 Reviewed the backup PR\n- 12:00 Lunch\n"
+	remote := base + "- 09:30 Answered issues\n- 11:00 Fixed the daemon\n"
+
+	merged, conflicts, ok := mergeStructured("memory/2026-10-17.md", base, local, remote)
+	if !ok || len(conflicts) != 0 {
+		t.Fatalf("merge = ok %v, conflicts %v", ok, conflicts)
+	}
+	want := "# 2026-10-17\n\n- 09:00 Standup\n- 09:30 Answered issues\n- 10:00 Reviewed the backup PR\n- 11:00 Fixed the daemon\n- 12:00 Lunch\n"
+	if merged != want {
+		t.Errorf("merged =\n%s\nwant\n%s", merged, want)
+	}
+
+	// A newest-first log stays newest first
+	base = "# Log\n\n## 09:00\nStandup\n"
+	local = "# Log\n\n## 10:00\nReview\n\n## 09:00\nStandup\n"
+	remote = "# Log\n\n## 11:00\nDaemon\n\n## 09:00\nStandup\n"
+	merged, _, _ = mergeStructured("memory/log.md", base, local, remote)
+	if want := "# Log\n\n## 11:00\nDaemon\n\n## 10:00\nReview\n\n## 09:00\nStandup\n"; merged != want {
+		t.Errorf("newest first merged =\n%s\nwant\n%s", merged, want)
+	}
+}
+
+func TestMergeMemoryLogDuplicates(t *testing.T) {
+	base := "# 2026-10-17\n\n- 09:00 Standup\n"
+	entry := "- 10:00 Reviewed the backup PR\n"
+
+	// The same entry synced to both machines is kept once
+	merged, conflicts, ok := mergeStructured("memory/2026-10-17.md", base, base+entry, base+entry)
+	if !ok || len(conflicts) != 0 || strings.Count(merged, entry) != 1 {
+		t.Errorf("identical entries merged into\n%s(conflicts %v)", merged, conflicts)
+	}
+
+	// Lines both sides appended to one entry are not repeated
+	base = "## 09:00 Standup\n- backups\n"
+	local := base + "- daemon\n- schedule\n"
+	remote := base + "- daemon\n- restore\n"
+	merged, conflicts, _ = mergeStructured("memory/2026-10-17.md", base, local, remote)
+	if want := "## 09:00 Standup\n- backups\n- daemon\n- schedule\n- restore\n"; merged != want || len(conflicts) != 0 {
+		t.Errorf("appended lines merged into\n%s(conflicts %v)", merged, conflicts)
+	}
+
+	// Different entries under one new heading are both kept
+	local = "## 10:00 Review\nlocal notes\n"
+	remote = "## 10:00 Review\nremote notes\n"
+	merged, conflicts, _ = mergeStructured("memory/2026-10-17.md", "", local, remote)
+	if !strings.Contains(merged, "local notes") || !strings.Contains(merged, "remote notes") || len(conflicts) != 0 {
+		t.Errorf("same heading merged into\n%s(conflicts %v)", merged, conflicts)
+	}
+}
+
+func TestMergeMemoryLogConflictingEdit(t *testing.T) {
+	base := "## 09:00 Standup\nbackups\n"
+	merged, conflicts, ok := mergeStructured("memory/2026-10-17.md", base, "## 09:00 Standup\nbackups done\n", "## 09:00 Standup\nbackups failed\n")
+	if !ok || merged != "## 09:00 Standup\nbackups done\n" {
+		t.Errorf("merged = %q, want the local edit", merged)
+	}
+	if len(conflicts) != 1 || conflicts[0].Remote != "## 09:00 Standup\nbackups failed\n" || conflicts[0].KeptSide() != "local" {
+		t.Errorf("conflicts = %+v", conflicts)
+	}
+}
+
+const testProjects = `# Projects
+
+| ID | Project | Status |
+|----|---------|--------|
+| P-1 | spirit | active |
+| P-2 | website | paused |
+`
+
+func TestMergeProjects(t *testing.T) {
+	local := strings.Replace(testProjects, "| P-1 | spirit | active |", "| P-1 | spirit | shipped |", 1)
+	remote := testProjects + "| P-3 | docs | active |\n"
+
+	merged, conflicts, ok := mergeStructured("PROJECTS.md", testProjects, local, remote)
+	if !ok || len(conflicts) != 0 {
+		t.Fatalf("merge = ok %v, conflicts %v", ok, conflicts)
+	}
+	if want := local + "| P-3 | docs | active |\n"; merged != want {
+		t.Errorf("merged =\n%s\nwant\n%s", merged, want)
+	}
+
+	// The same row edited on both sides keeps the local row
+	remote = strings.Replace(testProjects, "| P-1 | spirit | active |", "| P-1 | spirit | archived |", 1)
+	merged, conflicts, _ = mergeStructured("PROJECTS.md", testProjects, local, remote)
+	if merged != local {
+		t.Errorf("merged =\n%s\nwant the local file", merged)
+	}
+	if len(conflicts) != 1 || conflicts[0].Entry != "project P-1" || !strings.Contains(conflicts[0].Remote, "archived") {
+		t.Errorf("conflicts = %+v", conflicts)
+	}
+}
+
+func TestMergeUnparseable(t *testing.T) {
+	// Both sides rewrote the text around the table
+	local := strings.Replace(testProjects, "# Projects", "# Projects (local)", 1)
+	remote := strings.Replace(testProjects, "# Projects", "# Projects (remote)", 1)
+	if _, _, ok := mergeStructured("PROJECTS.md", testProjects, local, remote); ok {
+		t.Error("PROJECTS.md with diverged text merged by entry")
+	}
+	// Free text without entries
+	if _, _, ok := mergeStructured("memory/notes.md", "plain\n", "plain, local\n", "plain, remote\n"); ok {
+		t.Error("memory file without entries merged by entry")
+	}
+
+	merged, clean := mergeLines("a\nb\nc\n", "A\nb\nc\n", "a\nb\nC\n")
+	if !clean || merged != "A\nb\nC\n" {
+		t.Errorf("line merge = %q, %v", merged, clean)
+	}
+	merged, clean = mergeLines("a\n", "local\n", "remote\n")
+	if clean || !strings.Contains(merged, "<<<<<<< local\nlocal\n=======\nremote\n>>>>>>> remote\n") {
+		t.Errorf("conflicting line merge = %q, %v", merged, clean)
+	}
+}
+
+func TestMergeDriverFallsBackToMarkers(t *testing.T) {
+	withGitEnv(t)
+	dir := newStateRepo(t, "main")
+	t.Chdir(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base, other := write("base", "plain\n"), write("other", "plain, remote\n")
+
+	current := write("current", "plain, local\n")
+	if err := runMergeDriver(base, current, other, "memory/notes.md"); err == nil {
+		t.Fatal("merge driver succeeded on conflicting free text")
+	}
+	if got := readTestFile(t, current); !strings.Contains(got, "<<<<<<< local") || !strings.Contains(got, "plain, remote") {
+		t.Errorf("current = %q, want conflict markers", got)
+	}
+	if len(loadConflicts()) != 0 {
+		t.Error("a failed merge recorded conflicts")
+	}
+
+	// The retry keeps the local side and records the remote one
+	t.Setenv(keepLocalEnv, "1")
+	current = write("current", "plain, local\n")
+	if err := runMergeDriver(base, current, other, "memory/notes.md"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, current); got != "plain, local\n" {
+		t.Errorf("current = %q, want the local side", got)
+	}
+	if conflicts := loadConflicts(); len(conflicts) != 1 || conflicts[0].Remote != "plain, remote\n" {
+		t.Errorf("conflicts = %+v", conflicts)
+	}
+}
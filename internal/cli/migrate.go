@@ -5,32 +5,47 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 func migrateCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "migrate [source] [destination]",
 		Short: "Migrate spirit state to new machine/server",
 		Long: `Move your agent's spirit to a new home.
 
 Supports migration between:
 - Local directories
-- Git repositories  
+- Git repositories (github:, gitlab:, or any git URL), with history
 - Different backends (GitHub → S3, etc.)
+- Directory mirrors on removable media (dir:)
+- .spirit archives (see 'spirit export')
+
+Migration exports the source into an archive package and imports it into
+the destination.
 
 Example:
   spirit migrate ~/old-spirit ~/new-spirit
-  spirit migrate github:TheOrionAI/orion-state s3://my-bucket/orion`,
+  spirit migrate current orion.spirit
+  spirit migrate current git@example.com:me/orion-state.git --squash
+  spirit migrate github:TheOrionAI/orion-state s3://my-bucket/orion
+  spirit migrate dir:/media/usb/orion current`,
 		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return migrateSpirit(args[0], args[1])
+			squash, _ := cmd.Flags().GetBool("squash")
+			return withLock(cmd.CommandPath(), func() error {
+				return migrateSpirit(args[0], args[1], squash)
+			})
 		},
 	}
+	cmd.Flags().Bool("squash", false, "Push a single commit instead of the full history to a git destination")
+	return cmd
 }
 
-func migrateSpirit(source, dest string) error {
+func migrateSpirit(source, dest string, squash bool) error {
 	fmt.Printf("🌌 Migrating SPIRIT from '%s' to '%s'\n\n", source, dest)
 
 	// Detect source type and parse
@@ -46,6 +61,7 @@ func migrateSpirit(source, dest string) error {
 	if err != nil {
 		return fmt.Errorf("export failed: %w", err)
 	}
+	defer exportData.Close()
 
 	// Validate export
 	if err := validateExport(exportData); err != nil {
@@ -54,13 +70,13 @@ func migrateSpirit(source, dest string) error {
 
 	// Import to destination
 	fmt.Println("📥 Importing to new location...")
-	if err := importTo(destType, destPath, exportData); err != nil {
+	if err := importTo(destType, destPath, exportData, squash); err != nil {
 		return fmt.Errorf("import failed: %w", err)
 	}
 
 	// Update local config to point to new location
-	if destType == "github" || destType == "gitlab" {
-		if err := updatePrimaryBackend(dest); err != nil {
+	if gitLocationTypes[destType] {
+		if err := updatePrimaryBackend(destType, destPath); err != nil {
 			return fmt.Errorf("backend update failed: %w", err)
 		}
 	}
@@ -76,7 +92,9 @@ func migrateSpirit(source, dest string) error {
 func parseLocation(loc string) (string, string) {
 	// Parse location strings like:
 	// github:TheOrionAI/orion-state
+	// git@host:user/repo.git, https://host/repo.git, /srv/bare.git
 	// s3://my-bucket/orion
+	// dir:/media/usb/orion (a dir mirror backend)
 	// /local/path
 	// current (use ~/.spirit)
 
@@ -96,136 +114,233 @@ func parseLocation(loc string) (string, string) {
 		return "s3", loc[5:]
 	}
 
+	if strings.HasPrefix(loc, "dir:") {
+		return "dir", loc[4:]
+	}
+
+	if strings.HasPrefix(loc, "git:") {
+		return "git", loc[4:]
+	}
+
+	if isGitURL(loc) {
+		return "git", loc
+	}
+
+	if strings.HasSuffix(loc, archiveExt) {
+		return "archive", loc
+	}
+
 	// Assume local path
 	return "local", loc
 }
 
+// ExportPackage is the manifest of a .spirit archive, plus the file
+// contents once the archive has been read.
 type ExportPackage struct {
-	Version   string                 `json:"version"`
-	Identity  Identity               `json:"identity"`
-	Soul      Soul                   `json:"soul"`
-	Backends  map[string]Backend    `json:"backends"`
-	Memory    []string               `json:"memory,omitempty"`
-	Projects  []string               `json:"projects,omitempty"`
-	ExportedAt string               `json:"exported_at"`
+	Version     string         `json:"version"`
+	ToolVersion string         `json:"tool_version"`
+	Identity    Identity       `json:"identity"`
+	Commit      string         `json:"commit,omitempty"`
+	ExportedAt  string         `json:"exported_at"`
+	Files       []manifestFile `json:"files"`
+
+	contents map[string][]byte
+	// history is a repository whose commits can be pushed to a git
+	// destination.
+	history  string
+	tempDirs []string
 }
 
-func exportFrom(sourceType, sourcePath string) (*ExportPackage, error) {
-	var pkg ExportPackage
+// Close removes the scratch directories the package was read through.
+func (pkg *ExportPackage) Close() {
+	for _, dir := range pkg.tempDirs {
+		os.RemoveAll(dir)
+	}
+}
 
-	switch sourceType {
-	case "local":
-		// Read from local filesystem
-		configPath := filepath.Join(sourcePath, "spirit.json")
-		data, err := os.ReadFile(configPath)
+func exportFrom(sourceType, sourcePath string) (*ExportPackage, error) {
+	switch {
+	case sourceType == "local":
+	case gitLocationTypes[sourceType]:
+		return exportFromGit(sourceType, sourcePath)
+	case sourceType == "archive":
+		f, err := os.Open(sourcePath)
 		if err != nil {
-			return nil, fmt.Errorf("cannot read config: %w", err)
+			return nil, err
 		}
-
-		var config Config
-		if err := json.Unmarshal(data, &config); err != nil {
-			return nil, fmt.Errorf("cannot parse config: %w", err)
+		defer f.Close()
+		return readArchive(f)
+	default:
+		// Pull the state into a scratch directory and read it from there
+		backend, err := backendForLocation(sourceType, sourcePath)
+		if err != nil {
+			return nil, err
 		}
+		tmpDir, err := os.MkdirTemp("", "spirit-export-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmpDir)
 
-		pkg.Identity = config.Identity
-		pkg.Soul = config.Soul
-		pkg.Backends = config.Backends
-
-		// TODO: Read memory and projects
+		if err := backend.Pull(tmpDir); err != nil {
+			return nil, fmt.Errorf("cannot pull from %s: %w", sourceType, err)
+		}
+		// A fresh clone has no spirit filter, so encrypted files arrive as-is
+		if err := decryptTree(tmpDir); err != nil {
+			return nil, fmt.Errorf("cannot decrypt %s state: %w", sourceType, err)
+		}
+		return exportFrom("local", tmpDir)
+	}
 
-	case "github", "gitlab":
-		// Clone and read
-		// Implementation depends on git client
-		return nil, fmt.Errorf("github export not yet implemented")
+	// Read from local filesystem
+	configPath := filepath.Join(sourcePath, "spirit.json")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config: %w", err)
+	}
 
-	case "s3":
-		// Download from S3
-		return nil, fmt.Errorf("s3 export not yet implemented")
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse config: %w", err)
 	}
 
-	// Capture export timestamp
-	pkg.Version = "1.0.0"
+	manifest, err := buildManifest(sourcePath)
+	if err != nil {
+		return nil, err
+	}
 
-	return &pkg, nil
+	pkg := &ExportPackage{
+		Version:     archiveVersion,
+		ToolVersion: Version,
+		Identity:    config.Identity,
+		Commit:      manifest.Commit,
+		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
+		Files:       manifest.Files,
+		contents:    map[string][]byte{},
+	}
+	for _, f := range manifest.Files {
+		content, err := os.ReadFile(filepath.Join(sourcePath, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return nil, err
+		}
+		pkg.contents[f.Path] = content
+	}
+	pkg.history = localHistory(sourcePath)
+	return pkg, nil
 }
 
+// validateExport checks a package before it is imported anywhere. Packages
+// from a newer format, or holding a spirit.json from a newer spirit, are
+// refused rather than half understood.
 func validateExport(pkg *ExportPackage) error {
+	if pkg.Version != archiveVersion {
+		return fmt.Errorf("archive format %q is not supported by spirit %s (expected %q)", pkg.Version, Version, archiveVersion)
+	}
 	if pkg.Identity.Name == "" {
 		return fmt.Errorf("identity missing name")
 	}
+	hasConfig := false
+	for _, f := range pkg.Files {
+		content, ok := pkg.contents[f.Path]
+		if !ok {
+			return fmt.Errorf("%s: missing from package", f.Path)
+		}
+		if sha256Hex(content) != f.SHA256 {
+			return fmt.Errorf("%s: checksum mismatch", f.Path)
+		}
+		if f.Path == "spirit.json" {
+			if _, _, err := configSchemaFile.validate(content); err != nil {
+				return err
+			}
+			hasConfig = true
+		}
+	}
+	if !hasConfig {
+		return fmt.Errorf("package has no spirit.json")
+	}
 	return nil
 }
 
-func importTo(destType, destPath string, pkg *ExportPackage) error {
-	switch destType {
-	case "local":
-		// Ensure directory exists
-		if err := os.MkdirAll(destPath, 0755); err != nil {
-			return fmt.Errorf("cannot create directory: %w", err)
+func importTo(destType, destPath string, pkg *ExportPackage, squash bool) error {
+	switch {
+	case destType == "local":
+	case gitLocationTypes[destType]:
+		return importToGit(destType, destPath, pkg, squash)
+	case destType == "archive":
+		f, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
 		}
-
-		// Create subdirectories
-		for _, dir := range []string{"memory", "projects", "context"} {
-			if err := os.MkdirAll(filepath.Join(destPath, dir), 0755); err != nil {
-				return fmt.Errorf("cannot create %s: %w", dir, err)
-			}
+		if err := writeArchive(f, pkg); err != nil {
+			f.Close()
+			return err
 		}
-
-		// Write config
-		config := Config{
-			Version:   pkg.Version,
-			Identity:  pkg.Identity,
-			Soul:      pkg.Soul,
-			Backends:  pkg.Backends,
+		return f.Close()
+	default:
+		// Lay the state out in a scratch directory and push it from there
+		backend, err := backendForLocation(destType, destPath)
+		if err != nil {
+			return err
 		}
-
-		data, err := json.MarshalIndent(config, "", "  ")
+		tmpDir, err := os.MkdirTemp("", "spirit-import-")
 		if err != nil {
-			return fmt.Errorf("cannot marshal config: %w", err)
+			return err
 		}
+		defer os.RemoveAll(tmpDir)
 
-		configPath := filepath.Join(destPath, "spirit.json")
-		if err := os.WriteFile(configPath, data, 0600); err != nil {
-			return fmt.Errorf("cannot write config: %w", err)
+		if err := importTo("local", tmpDir, pkg, false); err != nil {
+			return err
 		}
+		if _, err := backend.Push(tmpDir); err != nil {
+			return fmt.Errorf("cannot push to %s: %w", destType, err)
+		}
+		return nil
+	}
+
+	// Ensure directory exists
+	if err := os.MkdirAll(destPath, 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %w", err)
+	}
 
-	case "github", "gitlab":
-		// Create repo and push
-		return fmt.Errorf("github import requires git - not yet implemented")
+	// Create subdirectories
+	for _, dir := range []string{"memory", "projects", "context"} {
+		if err := os.MkdirAll(filepath.Join(destPath, dir), 0755); err != nil {
+			return fmt.Errorf("cannot create %s: %w", dir, err)
+		}
+	}
 
-	case "s3":
-		// Upload to S3
-		return fmt.Errorf("s3 import not yet implemented")
+	for _, f := range pkg.Files {
+		target := filepath.Join(destPath, filepath.FromSlash(f.Path))
+		// Replace symlinks such as a workspace .spirit-tracked with the file
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			os.Remove(target)
+		}
+		if err := writeManifestFile(destPath, f, pkg.contents[f.Path]); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	// spirit.json may name backends and accounts
+	return os.Chmod(filepath.Join(destPath, "spirit.json"), 0600)
 }
 
-func updatePrimaryBackend(newBackend string) error {
+func updatePrimaryBackend(destType, destPath string) error {
 	// Update ~/.spirit/spirit.json primary backend
-	configPath := filepath.Join(ConfigDir, "spirit.json")
-
-	data, err := os.ReadFile(configPath)
+	config, err := loadConfig()
 	if err != nil {
 		return err
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return err
-	}
-
-	config.Backends["primary"] = Backend{
-		Type: "github",
-		Config: map[string]string{
-			"repo": newBackend,
-		},
+	if config.Backends == nil {
+		config.Backends = map[string]BackendConfig{}
 	}
-
-	newData, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
+	primary := BackendConfig{Type: destType, Config: map[string]string{}}
+	if destType == "git" {
+		primary.Config["url"] = destPath
+	} else {
+		primary.Config["repo"] = destPath
 	}
+	config.Backends["primary"] = primary
 
-	return os.WriteFile(configPath, newData, 0600)
+	return saveConfig(config)
 }
@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitLocationTypes are migrate locations backed by a git repository.
+var gitLocationTypes = map[string]bool{
+	"git":    true,
+	"github": true,
+	"gitlab": true,
+}
+
+// isGitURL reports whether a location looks like a git remote rather than
+// a local directory: a URL, an scp-style address or a path to a bare repo.
+func isGitURL(loc string) bool {
+	return strings.Contains(loc, "://") || strings.HasPrefix(loc, "git@") || strings.HasSuffix(loc, ".git")
+}
+
+// gitLocationURL returns the clone URL of a git location.
+func gitLocationURL(locType, path string) string {
+	if locType == "git" {
+		return path
+	}
+	return hostedRepoURL(locType, path)
+}
+
+// exportFromGit clones a state repository with its full history. The clone
+// is kept until the package is closed so its history can be pushed on.
+func exportFromGit(locType, path string) (*ExportPackage, error) {
+	if err := requireGitBinary("migrating from a git repository"); err != nil {
+		return nil, err
+	}
+	url := gitLocationURL(locType, path)
+	tmpDir, err := os.MkdirTemp("", "spirit-export-")
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("   Cloning %s...\n", url)
+	cmd := exec.Command("git", "clone", "--quiet", url, tmpDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, fmt.Errorf("git clone failed: %s", strings.TrimSpace(string(output)))
+	}
+	if err := checkoutDefaultBranch(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, fmt.Errorf("%s has no commits", url)
+	}
+
+	// A fresh clone has no spirit filter, so encrypted files arrive as-is.
+	// Decrypting the working tree leaves the history untouched.
+	if err := decryptTree(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, fmt.Errorf("cannot decrypt %s state: %w", locType, err)
+	}
+
+	pkg, err := exportFrom("local", tmpDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	pkg.history = tmpDir
+	pkg.tempDirs = append(pkg.tempDirs, tmpDir)
+	return pkg, nil
+}
+
+// importToGit pushes the package into an empty repository. History is kept
+// when the source had any, or collapsed into one commit when squash is set.
+func importToGit(locType, path string, pkg *ExportPackage, squash bool) error {
+	if err := requireGitBinary("migrating to a git repository"); err != nil {
+		return err
+	}
+	url := gitLocationURL(locType, path)
+
+	refs, err := gitOutputIn(".", "ls-remote", url)
+	if err != nil {
+		return fmt.Errorf("cannot reach %s: %w", url, err)
+	}
+	if refs != "" {
+		return fmt.Errorf("%s is not empty; migrate only pushes into a fresh repository", url)
+	}
+
+	repo := pkg.history
+	commit := "HEAD"
+	if repo != "" {
+		if stale := filesNotInHead(repo, pkg); len(stale) > 0 {
+			if encryptionEnabled() {
+				return fmt.Errorf("%d files are not committed yet (%s); run 'spirit sync' first so they are committed encrypted", len(stale), strings.Join(stale, ", "))
+			}
+			topped, err := commitOnTop(repo, pkg, stale)
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(topped)
+			repo = topped
+		}
+	}
+
+	if repo == "" {
+		// No history to carry: lay the files out and commit them once
+		tmpDir, err := os.MkdirTemp("", "spirit-import-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		if err := importTo("local", tmpDir, pkg, false); err != nil {
+			return err
+		}
+		if _, err := gitOutputIn(tmpDir, "init", "--quiet"); err != nil {
+			return err
+		}
+		if _, err := gitOutputIn(tmpDir, "add", "-A"); err != nil {
+			return err
+		}
+		message := fmt.Sprintf("SPIRIT migrate: %s (%d files)", pkg.Identity.Name, len(pkg.Files))
+		if _, err := gitOutputIn(tmpDir, "commit", "--quiet", "-m", message); err != nil {
+			return err
+		}
+		repo = tmpDir
+	} else if squash {
+		tree, err := gitOutputIn(repo, "rev-parse", "HEAD^{tree}")
+		if err != nil {
+			return err
+		}
+		source, _ := gitOutputIn(repo, "rev-parse", "--short", "HEAD")
+		message := fmt.Sprintf("SPIRIT migrate: %s (squashed from %s)", pkg.Identity.Name, source)
+		if commit, err = gitOutputIn(repo, "commit-tree", tree, "-m", message); err != nil {
+			return err
+		}
+	}
+
+	// The history keeps the branch it was on, and clones check it out
+	branch, err := gitOutputIn(repo, "symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil || branch == "" {
+		branch = "main"
+	}
+	if _, err := gitOutputIn(repo, "push", "--quiet", url, commit+":refs/heads/"+branch); err != nil {
+		return fmt.Errorf("git push failed: %w", err)
+	}
+	if err := setRemoteHead(url, branch); err != nil {
+		return err
+	}
+	count, _ := gitOutputIn(repo, "rev-list", "--count", commit)
+	fmt.Printf("   Pushed %s commits to %s (branch %s)\n", count, url, branch)
+	return nil
+}
+
+// setRemoteHead points the HEAD of a freshly pushed repository at branch.
+// Only a repository on this machine can be changed; hosting services make
+// the first pushed branch the default themselves, anything else is
+// reported.
+func setRemoteHead(url, branch string) error {
+	path := strings.TrimPrefix(url, "file://")
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if _, err := gitOutputIn(".", "--git-dir="+path, "symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+			return fmt.Errorf("cannot set HEAD of %s: %w", url, err)
+		}
+		return nil
+	}
+	output, err := gitOutputIn(".", "ls-remote", "--symref", url, "HEAD")
+	if err != nil || !strings.HasPrefix(output, "ref: refs/heads/"+branch+"\t") {
+		fmt.Printf("   ⚠️  Make %s the default branch of %s so clones check it out\n", branch, url)
+	}
+	return nil
+}
+
+// checkoutDefaultBranch makes sure a fresh clone has a commit checked out.
+// A remote HEAD naming a branch that was never pushed leaves the clone
+// empty; the clone then takes main, master, or the only branch there is.
+func checkoutDefaultBranch(dir string) error {
+	if _, err := gitOutputIn(dir, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		return nil
+	}
+	output, err := gitOutputIn(dir, "for-each-ref", "--format=%(refname:lstrip=3)", "refs/remotes/origin")
+	if err != nil {
+		return err
+	}
+	branches := []string{}
+	for _, b := range strings.Split(output, "\n") {
+		if b != "" && b != "HEAD" {
+			branches = append(branches, b)
+		}
+	}
+	if len(branches) == 0 {
+		return ErrNoCommits
+	}
+	branch := branches[0]
+	for _, b := range branches {
+		if b == "main" || b == "master" && branch != "main" {
+			branch = b
+		}
+	}
+	_, err = gitOutputIn(dir, "checkout", "--quiet", "-B", branch, "origin/"+branch)
+	return err
+}
+
+// localHistory returns dir if it is a git repository with commits.
+func localHistory(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return ""
+	}
+	if _, err := gitOutputIn(dir, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// filesNotInHead lists the package files whose content differs from, or
+// is missing in, the HEAD commit of repo.
+func filesNotInHead(repo string, pkg *ExportPackage) []string {
+	stale := []string{}
+	for _, f := range pkg.Files {
+		cmd := exec.Command("git", "cat-file", "blob", "HEAD:"+f.Path)
+		cmd.Dir = repo
+		content, err := cmd.Output()
+		if err == nil {
+			content, err = decryptContent(content)
+		}
+		if err != nil || sha256Hex(content) != f.SHA256 {
+			stale = append(stale, f.Path)
+		}
+	}
+	return stale
+}
+
+// commitOnTop clones repo and commits the given package files on top of its
+// history, so state that was never checkpointed is not lost in migration.
+func commitOnTop(repo string, pkg *ExportPackage, files []string) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "spirit-migrate-")
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", "clone", "--quiet", repo, tmpDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("git clone failed: %s", strings.TrimSpace(string(output)))
+	}
+
+	for _, f := range pkg.Files {
+		if err := writeManifestFile(tmpDir, f, pkg.contents[f.Path]); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", err
+		}
+	}
+	args := append([]string{"add", "--"}, files...)
+	if _, err := gitOutputIn(tmpDir, args...); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	message := fmt.Sprintf("SPIRIT migrate: include %d uncommitted files", len(files))
+	if _, err := gitOutputIn(tmpDir, "commit", "--quiet", "-m", message); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	fmt.Printf("   Committed %d files missing from the history\n", len(files))
+	return tmpDir, nil
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withGitEnv isolates git from the user's configuration and gives it an
+// identity to commit with.
+func withGitEnv(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "spirit test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@spirit")
+	t.Setenv("GIT_COMMITTER_NAME", "spirit test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@spirit")
+	t.Setenv("SPIRIT_SECRETS", "off")
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// withConfigDir points ConfigDir at an empty directory for the test.
+func withConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous := ConfigDir
+	ConfigDir = dir
+	t.Cleanup(func() { ConfigDir = previous })
+	return dir
+}
+
+// newStateRepo makes ConfigDir a state directory with commits on branch.
+func newStateRepo(t *testing.T, branch string) string {
+	t.Helper()
+	dir := withConfigDir(t)
+
+	files := map[string]string{
+		"spirit.json":       `{"version": "1.2.0", "identity": {"name": "orion", "emoji": "🌌", "created_at": "2026-01-01T00:00:00Z"}, "backends": {}, "soul": {"vibe": "", "core_truths": [], "boundaries": []}, "created_at": "2026-01-01T00:00:00Z"}`,
+		".spirit-tracked":   `{"version": "1.0.0", "files": ["IDENTITY.md", "memory/*.md", "spirit.json"]}`,
+		"IDENTITY.md":       "- **Name:** orion\n",
+		"memory/2026-01.md": "- first day\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, dir, "init", "--quiet", "--initial-branch="+branch)
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "--quiet", "-m", "first")
+	if err := os.WriteFile(filepath.Join(dir, "memory/2026-01.md"), []byte("- first day\n- second day\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "commit", "--quiet", "-am", "second")
+	return dir
+}
+
+func newBareRepo(t *testing.T, head string) string {
+	t.Helper()
+	bare := filepath.Join(t.TempDir(), "state.git")
+	runGit(t, ".", "init", "--quiet", "--bare", "--initial-branch="+head, bare)
+	return bare
+}
+
+func TestMigrateToBareRepoKeepsBranchAndHistory(t *testing.T) {
+	withGitEnv(t)
+	newStateRepo(t, "trunk")
+	// git's own default, which the pushed branch must replace
+	bare := newBareRepo(t, "master")
+
+	if err := migrateSpirit("current", bare, false); err != nil {
+		t.Fatalf("migrate to %s: %v", bare, err)
+	}
+	if head := runGit(t, bare, "symbolic-ref", "HEAD"); head != "refs/heads/trunk" {
+		t.Errorf("destination HEAD = %s, want refs/heads/trunk", head)
+	}
+	if count := runGit(t, bare, "rev-list", "--count", "trunk"); count != "2" {
+		t.Errorf("destination has %s commits, want 2", count)
+	}
+
+	restored := filepath.Join(t.TempDir(), "restored")
+	if err := migrateSpirit(bare, restored, false); err != nil {
+		t.Fatalf("migrate back from %s: %v", bare, err)
+	}
+	content, err := os.ReadFile(filepath.Join(restored, "memory/2026-01.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "- first day\n- second day\n" {
+		t.Errorf("restored memory = %q", content)
+	}
+}
+
+func TestMigrateSquashPushesOneCommit(t *testing.T) {
+	withGitEnv(t)
+	newStateRepo(t, "main")
+	bare := newBareRepo(t, "main")
+
+	if err := migrateSpirit("current", bare, true); err != nil {
+		t.Fatal(err)
+	}
+	if count := runGit(t, bare, "rev-list", "--count", "main"); count != "1" {
+		t.Errorf("destination has %s commits, want 1", count)
+	}
+}
+
+func TestMigrateRefusesNonEmptyRepo(t *testing.T) {
+	withGitEnv(t)
+	newStateRepo(t, "main")
+	bare := newBareRepo(t, "main")
+	if err := migrateSpirit("current", bare, false); err != nil {
+		t.Fatal(err)
+	}
+
+	err := migrateSpirit("current", bare, false)
+	if err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Errorf("second migrate into %s: err = %v, want 'not empty'", bare, err)
+	}
+}
+
+func TestMigrateFromRepoWhoseHeadNamesNoBranch(t *testing.T) {
+	withGitEnv(t)
+	state := newStateRepo(t, "main")
+	// Pushed by hand: HEAD still names master, which does not exist
+	bare := newBareRepo(t, "master")
+	runGit(t, state, "push", "--quiet", bare, "main:refs/heads/main")
+
+	restored := filepath.Join(t.TempDir(), "restored")
+	if err := migrateSpirit(bare, restored, false); err != nil {
+		t.Fatalf("migrate from %s: %v", bare, err)
+	}
+	if _, err := os.Stat(filepath.Join(restored, "IDENTITY.md")); err != nil {
+		t.Error(err)
+	}
+}
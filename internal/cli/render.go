@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// spirit render writes IDENTITY.md, SOUL.md and README.md from the
+// identity and soul in spirit.json, the reverse of what a checkpoint does.
+// Each file comes from a Go template: a built-in one, or <name>.tmpl in
+// the templates directory, which may also add files of its own.
+
+const templateExt = ".tmpl"
+
+// The built-in templates write what parseIdentity and parseSoul read, so a
+// render followed by a checkpoint leaves spirit.json unchanged. Values
+// spanning several lines come back joined onto one, and a value that is
+// itself Markdown structure (a bullet, a heading or a "(placeholder)")
+// does not come back as written.
+const identityTemplate = `# {{.Identity.Emoji}} {{.Identity.Name}}
+
+- **Name:** {{.Identity.Name}}
+- **Emoji:** {{.Identity.Emoji}}
+{{- with .Identity.Description}}
+- **Creature:** {{.}}
+{{- end}}
+{{- with .Identity.Email}}
+- **Email:** {{.}}
+{{- end}}
+`
+
+const soulTemplate = `# SOUL.md - Who You Are
+
+## Core Truths
+
+{{with .Soul.CoreTruths}}{{paragraphs .}}{{else}}<!-- What you hold to be true, one paragraph each -->
+{{end}}
+## Boundaries
+
+{{with .Soul.Boundaries}}{{bullets .}}{{else}}<!-- What you will not do, one bullet each -->
+{{end}}
+## Vibe
+
+{{with .Soul.Vibe}}{{.}}{{else}}<!-- How you come across -->{{end}}
+`
+
+const readmeTemplate = `# SPIRIT State for {{.Identity.Emoji}} {{.Identity.Name}}
+
+Run: spirit sync
+`
+
+var builtinTemplates = map[string]string{
+	"IDENTITY.md": identityTemplate,
+	"SOUL.md":     soulTemplate,
+	"README.md":   readmeTemplate,
+}
+
+var templateFuncs = template.FuncMap{
+	"bullets":    formatBulletList,
+	"paragraphs": formatParagraphs,
+}
+
+func renderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render [file...]",
+		Short: "Write IDENTITY.md, SOUL.md and README.md from spirit.json",
+		Long: `Generate Markdown from the identity and soul in spirit.json with Go
+templates. Each built-in template can be replaced by <name>.tmpl in
+~/.spirit/templates/, and any other .tmpl file there is rendered too, so
+one canonical spirit.json can produce the files every platform expects.
+
+Templates see the whole spirit.json ({{.Identity.Name}}, {{.Soul.Vibe}},
+...) and the functions bullets and paragraphs for lists.
+
+Files are written to SPIRIT_SOURCE_DIR when it is set, otherwise to
+~/.spirit/. Changed files are listed and confirmed before being replaced,
+and a safety checkpoint of the current files is tagged first, so a render
+can be undone with spirit restore like a restore.
+
+Examples:
+  spirit render                             # All files
+  spirit render SOUL.md --dry-run           # Only show what would change
+  spirit render -o /srv/agent --templates ./platform -y`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString("output")
+			templates, _ := cmd.Flags().GetString("templates")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			yes, _ := cmd.Flags().GetBool("yes")
+			return withLock(cmd.CommandPath(), func() error {
+				return renderSpirit(args, output, templates, dryRun, yes)
+			})
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "", "Directory to write to (default SPIRIT_SOURCE_DIR or ~/.spirit)")
+	cmd.Flags().String("templates", "", "Directory of .tmpl files (default ~/.spirit/templates)")
+	cmd.Flags().Bool("dry-run", false, "Show what would be written without writing files")
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+
+	return cmd
+}
+
+func renderSpirit(names []string, outputDir, templatesDir string, dryRun, yes bool) error {
+	config, err := loadConfig()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no configuration found, run 'spirit init' first")
+		}
+		return err
+	}
+	if outputDir == "" {
+		outputDir = getSourceDir()
+	}
+	if templatesDir == "" {
+		templatesDir = filepath.Join(ConfigDir, "templates")
+	}
+	if len(names) == 0 {
+		names = templateNames(templatesDir)
+	}
+
+	fmt.Printf("🌌 Rendering %s %s\n", config.Identity.Emoji, config.Identity.Name)
+	fmt.Printf("   Target: %s\n\n", outputDir)
+
+	entries := []restoreEntry{}
+	changes, overwrites := 0, 0
+	for _, name := range names {
+		content, err := renderTemplate(name, templatesDir, config)
+		if err != nil {
+			return err
+		}
+		entry := restoreEntry{Path: name, Content: content, Action: "unchanged"}
+		current, err := os.ReadFile(filepath.Join(outputDir, name))
+		switch {
+		case os.IsNotExist(err):
+			entry.Action = "create"
+			fmt.Printf("   + %s\n", name)
+		case err != nil || !bytes.Equal(current, content):
+			entry.Action = "overwrite"
+			overwrites++
+			fmt.Printf("   ~ %s\n", name)
+		}
+		if entry.Action != "unchanged" {
+			changes++
+		}
+		entries = append(entries, entry)
+	}
+
+	if changes == 0 {
+		fmt.Println("✅ Already up to date")
+		return nil
+	}
+	if dryRun {
+		fmt.Println("\n   Dry run: no files written")
+		return nil
+	}
+	if overwrites > 0 && !yes && !confirm("\nOverwrite these files?") {
+		fmt.Println("Render cancelled")
+		return nil
+	}
+
+	// Rendering over the agent's own files keeps them in a safety
+	// checkpoint first, as a restore does
+	safetyRef := ""
+	if filepath.Clean(outputDir) == filepath.Clean(getSourceDir()) {
+		patterns, err := trackedFilesOrDefault(ConfigDir)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		if safetyRef, err = writeEntries(entries, outputDir, patterns); err != nil {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return err
+		}
+		if _, err := writeEntries(entries, outputDir, nil); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n✅ Rendered %d files\n", changes)
+	if safetyRef != "" {
+		fmt.Printf("   Undo with: spirit restore %s\n", safetyRef)
+	}
+	return nil
+}
+
+// templateNames lists the built-in files and every other template in dir.
+func templateNames(dir string) []string {
+	names := []string{"IDENTITY.md", "SOUL.md", "README.md"}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"+templateExt))
+	extra := []string{}
+	for _, m := range matches {
+		name := strings.TrimSuffix(filepath.Base(m), templateExt)
+		if _, ok := builtinTemplates[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
+
+// renderTemplate renders one file from <name>.tmpl in dir, or from the
+// built-in template when there is none.
+func renderTemplate(name, dir string, config *Config) ([]byte, error) {
+	if filepath.Base(name) != name || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid file name %q", name)
+	}
+	text, ok := builtinTemplates[name]
+	path := filepath.Join(dir, name+templateExt)
+	if data, err := os.ReadFile(path); err == nil {
+		text, ok = string(data), true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no template for %s (create %s)", name, path)
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, config); err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	return out.Bytes(), nil
+}
+
+// writeStarterFiles renders the built-in templates for names into dir,
+// skipping files that already exist.
+func writeStarterFiles(dir string, config *Config, names ...string) error {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		content, err := renderTemplate(name, "", config)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// formatParagraphs writes each item as a paragraph of its own, on one
+// line so a blank line inside an item does not split it.
+func formatParagraphs(items []string) string {
+	var result strings.Builder
+	for i, item := range items {
+		if i > 0 {
+			result.WriteString("\n")
+		}
+		lines := []string{}
+		for _, line := range strings.Split(item, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		result.WriteString(strings.Join(lines, " ") + "\n")
+	}
+	return result.String()
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// renderedIdentity renders IDENTITY.md and SOUL.md from config and reads
+// them back the way a checkpoint does.
+func renderedIdentity(t *testing.T, config *Config) (Identity, Soul) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"IDENTITY.md", "SOUL.md"} {
+		content, err := renderTemplate(name, dir, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	identity, soul, _ := identityFromMarkdown(dir)
+	return identity, soul
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	identityMD, soulMD := repoIdentity(t)
+	cases := map[string]Config{
+		"repo identity": {Identity: parseIdentity(identityMD), Soul: parseSoul(soulMD)},
+		"minimal":       {Identity: Identity{Name: "Vega"}},
+		"markdown in values": {
+			Identity: Identity{Name: "Vega", Emoji: "⭐", Description: "a **bright** star: the fifth", Email: "vega@example.com"},
+			Soul: Soul{
+				Vibe:       "Calm. _Precise_ when it counts.",
+				CoreTruths: []string{"**Be kind.** Always.", "Ship: then polish."},
+				Boundaries: []string{"Ask before **anything** public", "Keep secrets"},
+			},
+		},
+	}
+	for name, config := range cases {
+		t.Run(name, func(t *testing.T) {
+			identity, soul := renderedIdentity(t, &config)
+			if identity != config.Identity {
+				t.Errorf("identity = %+v, want %+v", identity, config.Identity)
+			}
+			if !sameSoul(soul, config.Soul) {
+				t.Errorf("soul = %#v, want %#v", soul, config.Soul)
+			}
+		})
+	}
+
+	// Values over several lines come back on one
+	config := Config{Identity: Identity{Name: "Vega"}, Soul: Soul{
+		Vibe:       "Calm.\nPrecise.",
+		CoreTruths: []string{"Be kind.\n\nAlways.", "Ship."},
+		Boundaries: []string{"Ask first,\nthen act", "Keep secrets"},
+	}}
+	want := Soul{Vibe: "Calm. Precise.", CoreTruths: []string{"Be kind. Always.", "Ship."}, Boundaries: []string{"Ask first, then act", "Keep secrets"}}
+	if _, soul := renderedIdentity(t, &config); !sameSoul(soul, want) {
+		t.Errorf("multi-line soul = %#v, want %#v", soul, want)
+	}
+}
+
+// sameSoul compares souls, taking a nil list to be an empty one.
+func sameSoul(a, b Soul) bool {
+	return a.Vibe == b.Vibe && strings.Join(a.CoreTruths, "\n") == strings.Join(b.CoreTruths, "\n") && strings.Join(a.Boundaries, "\n") == strings.Join(b.Boundaries, "\n")
+}
+
+func TestRenderTemplateOverride(t *testing.T) {
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	withConfigDir(t)
+	if err := saveConfig(&Config{Identity: Identity{Name: "Vega", Emoji: "⭐"}, Soul: Soul{Boundaries: []string{"Ask first"}}}); err != nil {
+		t.Fatal(err)
+	}
+	templates := t.TempDir()
+	for name, text := range map[string]string{
+		"IDENTITY.md.tmpl": "I am {{.Identity.Name}}\n",
+		"AGENTS.md.tmpl":   "# {{.Identity.Name}}\n\n{{bullets .Soul.Boundaries}}",
+		"notes.txt":        "not a template",
+	} {
+		if err := os.WriteFile(filepath.Join(templates, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := templateNames(templates), []string{"IDENTITY.md", "SOUL.md", "README.md", "AGENTS.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("templateNames = %v, want %v", got, want)
+	}
+	output := t.TempDir()
+	if err := renderSpirit(nil, output, templates, false, true); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"IDENTITY.md": "I am Vega\n",
+		"AGENTS.md":   "# Vega\n\n- Ask first\n",
+		"README.md":   "# SPIRIT State for ⭐ Vega\n\nRun: spirit sync\n",
+	} {
+		if got := readTestFile(t, filepath.Join(output, name)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// A broken override is reported, not rendered
+	if err := os.WriteFile(filepath.Join(templates, "SOUL.md.tmpl"), []byte("{{.Soul.Mood}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renderTemplate("SOUL.md", templates, &Config{}); err == nil || !strings.Contains(err.Error(), "template SOUL.md") {
+		t.Errorf("render with an unknown field = %v", err)
+	}
+}
+
+func TestRenderTemplateInvalidName(t *testing.T) {
+	templates := t.TempDir()
+	for _, name := range []string{"../IDENTITY.md", "memory/notes.md", ".", "..", ""} {
+		if _, err := renderTemplate(name, templates, &Config{}); err == nil || !strings.Contains(err.Error(), "invalid file name") {
+			t.Errorf("renderTemplate(%q) = %v, want an invalid name", name, err)
+		}
+	}
+	if _, err := renderTemplate("NOTES.md", templates, &Config{}); err == nil || !strings.Contains(err.Error(), "no template for NOTES.md") {
+		t.Errorf("renderTemplate without a template = %v", err)
+	}
+
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	withConfigDir(t)
+	if err := saveConfig(&Config{Identity: Identity{Name: "Vega"}}); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(t.TempDir(), "out")
+	if err := renderSpirit([]string{"../escaped.md"}, output, templates, false, true); err == nil {
+		t.Error("render of ../escaped.md succeeded")
+	}
+	if exists(filepath.Join(filepath.Dir(output), "escaped.md")) {
+		t.Error("render wrote outside the output directory")
+	}
+}
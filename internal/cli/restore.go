@@ -1,17 +1,450 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 func restoreCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "restore",
-		Short: "Restore state from backup",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("🌌 Restore complete (stub)")
+	cmd := &cobra.Command{
+		Use:   "restore [ref]",
+		Short: "Restore state from a checkpoint",
+		Long: `Resurrect tracked files from any point in the ~/.spirit history.
+
+The ref can be a commit hash, a tag, or a timestamp. A timestamp restores the
+latest checkpoint made at or before that time. Without a ref, the last
+checkpoint (HEAD) is restored.
+
+Files are written to SPIRIT_SOURCE_DIR when it is set, otherwise to ~/.spirit/.
+Before anything is overwritten, a safety checkpoint of the current state is
+created and tagged so the restore can be undone.
+
+With --from, the files come from a snapshot held by a backend instead: a
+backend named in spirit.json, or a location such as dir:/media/usb/orion.
+The ref is then a snapshot id (or its prefix) or a timestamp, and defaults
+to the latest snapshot. A dir mirror needs no network at all.
+
+Examples:
+  spirit restore                        # Discard changes since last checkpoint
+  spirit restore 3f2a9c1                # Restore a specific checkpoint
+  spirit restore "2026-02-16 18:00"     # Restore state as of a point in time
+  spirit restore 3f2a9c1 --dry-run      # Only show what would change
+  spirit restore --from usb             # Latest snapshot on the usb backend
+  spirit restore --from dir:/media/usb/orion 20260216T1800`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref := ""
+			if len(args) > 0 {
+				ref = args[0]
+			}
+			from, _ := cmd.Flags().GetString("from")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			yes, _ := cmd.Flags().GetBool("yes")
+			return withLock(cmd.CommandPath(), func() error {
+				if from != "" {
+					return restoreSnapshot(from, ref, dryRun, yes)
+				}
+				if ref == "" {
+					ref = "HEAD"
+				}
+				return restoreSpirit(ref, dryRun, yes)
+			})
 		},
 	}
+
+	cmd.Flags().String("from", "", "Restore a snapshot from this backend or location instead of the local history")
+	cmd.Flags().Bool("dry-run", false, "Show what would be restored without writing files")
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+
+	return cmd
+}
+
+// restoreEntry is a single tracked file stored in a checkpoint.
+type restoreEntry struct {
+	Path    string
+	Content []byte
+	Action  string // "create", "overwrite" or "unchanged"
+}
+
+func restoreSpirit(ref string, dryRun, yes bool) error {
+	if _, err := os.Stat(filepath.Join(ConfigDir, ".git")); os.IsNotExist(err) {
+		return fmt.Errorf("no checkpoint history in %s. Run: spirit checkpoint", ConfigDir)
+	}
+
+	commit, err := resolveRestoreRef(ref)
+	if err != nil {
+		return err
+	}
+	summary := commit[:7]
+	if git, err := activeGit(); err == nil {
+		if commits, err := git.Log(ConfigDir, commit, time.Time{}, 1); err == nil && len(commits) == 1 {
+			c := commits[0]
+			summary = fmt.Sprintf("%s %s %s", c.Hash[:7], c.Time.Format("2006-01-02 15:04:05 -0700"), c.Subject())
+		}
+	}
+
+	targetDir := getSourceDir()
+	fmt.Printf("🌌 Restoring from %s\n", summary)
+	fmt.Printf("   Target: %s\n\n", targetDir)
+
+	patterns := trackedPatternsAt(commit)
+	entries, err := readCheckpointFiles(commit, patterns)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("checkpoint %s contains no tracked files", ref)
+	}
+	return applyRestore(entries, targetDir, patterns, dryRun, yes)
+}
+
+// applyRestore writes entries into targetDir after showing what changes and
+// taking a safety checkpoint of the files about to be overwritten.
+func applyRestore(entries []restoreEntry, targetDir string, patterns []string, dryRun, yes bool) error {
+	// Work out what the restore would do to the target directory
+	inCheckpoint := map[string]bool{}
+	changes := 0
+	for i := range entries {
+		e := &entries[i]
+		inCheckpoint[e.Path] = true
+		current, err := os.ReadFile(filepath.Join(targetDir, e.Path))
+		switch {
+		case os.IsNotExist(err):
+			e.Action = "create"
+			changes++
+		case err == nil && bytes.Equal(current, e.Content):
+			e.Action = "unchanged"
+		default:
+			e.Action = "overwrite"
+			changes++
+		}
+	}
+
+	for _, e := range entries {
+		switch e.Action {
+		case "create":
+			fmt.Printf("   + %s\n", e.Path)
+		case "overwrite":
+			fmt.Printf("   ~ %s\n", e.Path)
+		}
+	}
+	kept := 0
+	for _, f := range collectTrackedFiles(targetDir, patterns) {
+		if !inCheckpoint[filepath.ToSlash(f)] {
+			kept++
+		}
+	}
+
+	fmt.Printf("\n   %d to create/overwrite, %d unchanged", changes, len(entries)-changes)
+	if kept > 0 {
+		fmt.Printf(", %d not in checkpoint (kept)", kept)
+	}
+	fmt.Println()
+
+	if changes == 0 {
+		fmt.Println("\n✅ Already matches this checkpoint")
+		return nil
+	}
+	if dryRun {
+		fmt.Println("\n   Dry run: no files written")
+		return nil
+	}
+	if !yes && !confirm("\nOverwrite these files?") {
+		fmt.Println("Restore cancelled")
+		return nil
+	}
+
+	fmt.Println()
+	safetyRef, err := writeEntries(entries, targetDir, patterns)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✅ Restored %d files\n", changes)
+	if safetyRef != "" {
+		fmt.Printf("   Undo with: spirit restore %s\n", safetyRef)
+	}
+	return nil
+}
+
+// writeEntries writes the entries that are not unchanged into targetDir,
+// after a safety checkpoint of its tracked files. It returns the ref to
+// restore from to undo, or "" when there was nothing to keep.
+func writeEntries(entries []restoreEntry, targetDir string, patterns []string) (string, error) {
+	// A fresh machine restoring from a mirror has nothing to keep
+	safetyRef := ""
+	if len(collectTrackedFiles(targetDir, patterns)) > 0 {
+		ref, err := createSafetyCheckpoint(targetDir, patterns)
+		if err != nil {
+			return "", fmt.Errorf("safety checkpoint failed: %w", err)
+		}
+		safetyRef = ref
+	}
+
+	for _, e := range entries {
+		if e.Action == "unchanged" {
+			continue
+		}
+		dst := filepath.Join(targetDir, filepath.FromSlash(e.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return "", fmt.Errorf("cannot create directory for %s: %w", e.Path, err)
+		}
+		mode := restoreMode(targetDir, e.Path)
+		if err := os.WriteFile(dst, e.Content, mode); err != nil {
+			return "", fmt.Errorf("cannot write %s: %w", e.Path, err)
+		}
+		if err := os.Chmod(dst, mode); err != nil {
+			return "", fmt.Errorf("cannot write %s: %w", e.Path, err)
+		}
+	}
+	return safetyRef, nil
+}
+
+// restoreMode is the mode to write a restored file with: that of the file
+// it replaces, or of its copy in the state directory, so files doctor made
+// private stay private. A new spirit.json is private from the start.
+func restoreMode(targetDir, path string) os.FileMode {
+	for _, dir := range []string{targetDir, ConfigDir} {
+		if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path))); err == nil && info.Mode().IsRegular() {
+			return info.Mode().Perm()
+		}
+	}
+	if path == "spirit.json" {
+		return 0600
+	}
+	return 0644
+}
+
+// restoreSnapshot restores a snapshot held by a backend. from is the name
+// of a backend in spirit.json or a location such as dir:/media/usb/orion.
+func restoreSnapshot(from, ref string, dryRun, yes bool) error {
+	backend, err := restoreBackend(from)
+	if err != nil {
+		return err
+	}
+	snapshots, err := backend.List()
+	if err != nil {
+		return fmt.Errorf("cannot list snapshots in %s: %w", from, err)
+	}
+	snapshot, err := findSnapshot(snapshots, ref)
+	if err != nil {
+		return fmt.Errorf("%s: %w", from, err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "spirit-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := backend.Fetch(snapshot.ID, tmpDir); err != nil {
+		return fmt.Errorf("cannot fetch snapshot %s: %w", snapshot.ID, err)
+	}
+	if err := decryptTree(tmpDir); err != nil {
+		return fmt.Errorf("cannot decrypt snapshot %s: %w", snapshot.ID, err)
+	}
+
+	targetDir := getSourceDir()
+	fmt.Printf("🌌 Restoring snapshot %s from %s (%s)\n", snapshot.ID, from, snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("   Target: %s\n\n", targetDir)
+
+	patterns := defaultTrackedFiles
+	if data, err := os.ReadFile(filepath.Join(tmpDir, ".spirit-tracked")); err == nil {
+		var config TrackedConfig
+		if json.Unmarshal(data, &config) == nil && len(config.Files) > 0 {
+			patterns = config.Files
+		}
+	} else if tracked, err := loadTrackedFiles(); err == nil {
+		patterns = tracked
+	}
+
+	entries := []restoreEntry{}
+	for _, f := range collectTrackedFiles(tmpDir, patterns) {
+		content, err := os.ReadFile(filepath.Join(tmpDir, f))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, restoreEntry{Path: filepath.ToSlash(f), Content: content})
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("snapshot %s contains no tracked files", snapshot.ID)
+	}
+	return applyRestore(entries, targetDir, patterns, dryRun, yes)
+}
+
+// restoreBackend finds the backend a --from value names.
+func restoreBackend(from string) (Backend, error) {
+	if config, err := loadConfig(); err == nil {
+		if bc, ok := config.Backends[from]; ok {
+			return newBackend(from, bc)
+		}
+	}
+	locType, path := parseLocation(from)
+	if locType == "local" || locType == "archive" {
+		return nil, fmt.Errorf("no backend %q in spirit.json (use a location such as dir:%s)", from, from)
+	}
+	return backendForLocation(locType, path)
+}
+
+// findSnapshot picks the snapshot ref names: an id or id prefix, a
+// timestamp for the latest snapshot at or before it, or the latest one.
+func findSnapshot(snapshots []Snapshot, ref string) (Snapshot, error) {
+	if len(snapshots) == 0 {
+		return Snapshot{}, fmt.Errorf("no snapshots")
+	}
+	if ref == "" || ref == "latest" {
+		return snapshots[0], nil
+	}
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.ID, ref) {
+			return s, nil
+		}
+	}
+	if t, ok := parseRestoreTime(ref); ok {
+		for _, s := range snapshots {
+			if !s.CreatedAt.After(t) {
+				return s, nil
+			}
+		}
+		return Snapshot{}, fmt.Errorf("no snapshot at or before %s", t.Format("2006-01-02 15:04:05"))
+	}
+	return Snapshot{}, fmt.Errorf("unknown snapshot %q (expected a snapshot id or timestamp)", ref)
+}
+
+// resolveRestoreRef turns a commit hash, tag or timestamp into a full commit id.
+func resolveRestoreRef(ref string) (string, error) {
+	git, err := activeGit()
+	if err != nil {
+		return "", err
+	}
+	commit, err := git.ResolveCommit(ConfigDir, ref)
+	if err == nil {
+		return commit, nil
+	}
+	if !errors.Is(err, ErrUnknownRevision) {
+		return "", err
+	}
+
+	if t, ok := parseRestoreTime(ref); ok {
+		commits, err := git.Log(ConfigDir, "HEAD", time.Time{}, 0)
+		if err != nil && !errors.Is(err, ErrUnknownRevision) {
+			return "", err
+		}
+		for _, c := range commits {
+			if !c.Time.After(t) {
+				return c.Hash, nil
+			}
+		}
+		return "", fmt.Errorf("no checkpoint at or before %s", t.Format("2006-01-02 15:04:05"))
+	}
+
+	return "", fmt.Errorf("unknown checkpoint %q (expected a commit, tag or timestamp)", ref)
+}
+
+func parseRestoreTime(s string) (time.Time, bool) {
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02T15:04",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// trackedPatternsAt returns the tracked patterns recorded in a checkpoint,
+// falling back to the current .spirit-tracked and then the defaults.
+func trackedPatternsAt(commit string) []string {
+	if git, err := activeGit(); err == nil {
+		if data, err := git.ReadFile(ConfigDir, commit, ".spirit-tracked"); err == nil {
+			var config TrackedConfig
+			if err := json.Unmarshal(data, &config); err == nil && len(config.Files) > 0 {
+				return config.Files
+			}
+		}
+	}
+	if tracked, err := loadTrackedFiles(); err == nil {
+		return tracked
+	}
+	return defaultTrackedFiles
+}
+
+// readCheckpointFiles loads every regular tracked file stored in a commit.
+func readCheckpointFiles(commit string, patterns []string) ([]restoreEntry, error) {
+	git, err := activeGit()
+	if err != nil {
+		return nil, err
+	}
+	files, err := git.Files(ConfigDir, commit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list checkpoint: %w", err)
+	}
+
+	entries := []restoreEntry{}
+	for _, f := range files {
+		// Symlinks, such as a workspace .spirit-tracked, are not listed
+		if !matchesTracked(f.Path, patterns) {
+			continue
+		}
+		content, err := git.ReadFile(ConfigDir, commit, f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", f.Path, err)
+		}
+		if content, err = decryptContent(content); err != nil {
+			return nil, fmt.Errorf("cannot decrypt %s: %w", f.Path, err)
+		}
+		entries = append(entries, restoreEntry{Path: f.Path, Content: content})
+	}
+	return entries, nil
+}
+
+// createSafetyCheckpoint commits the current state of targetDir and tags it,
+// returning the tag to restore from to undo.
+func createSafetyCheckpoint(targetDir string, patterns []string) (string, error) {
+	if targetDir != ConfigDir {
+		for _, f := range collectTrackedFiles(targetDir, patterns) {
+			if err := copyFile(filepath.Join(targetDir, f), filepath.Join(ConfigDir, f)); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err := createCheckpoint("Pre-restore safety checkpoint"); err != nil {
+		return "", err
+	}
+
+	git, err := activeGit()
+	if err != nil {
+		return "", err
+	}
+	head, err := git.Head(ConfigDir)
+	if err != nil {
+		return "", err
+	}
+	tag := "spirit/pre-restore-" + time.Now().Format("20060102-150405")
+	if err := git.Tag(ConfigDir, tag, head); err != nil {
+		// The commit itself is still a valid undo point
+		return head[:7], nil
+	}
+	return tag, nil
+}
+
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
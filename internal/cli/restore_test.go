@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreSpirit(t *testing.T) {
+	withGitEnv(t)
+	t.Setenv("SPIRIT_SOURCE_DIR", "")
+	dir := newStateRepo(t, "main")
+	identity := filepath.Join(dir, "IDENTITY.md")
+	memory := filepath.Join(dir, "memory/2026-01.md")
+
+	// Checkpoints a day apart, in the past so a timestamp picks one of them
+	hashes := map[string]string{}
+	for i, name := range []string{"vega", "lyra", "altair"} {
+		date := fmt.Sprintf("2020-01-%02dT12:00:00", i+1)
+		t.Setenv("GIT_AUTHOR_DATE", date)
+		t.Setenv("GIT_COMMITTER_DATE", date)
+		if err := os.WriteFile(identity, []byte("- **Name:** "+name+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, dir, "commit", "--quiet", "-am", name)
+		hashes[name] = runGit(t, dir, "rev-parse", "HEAD")
+	}
+	runGit(t, dir, "tag", "lyra", hashes["lyra"])
+
+	// Edits since the last checkpoint, with files doctor made private
+	if err := os.WriteFile(identity, []byte("- **Name:** edited\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(memory, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "spirit.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restoreSpirit(hashes["vega"][:7], false, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, identity); got != "- **Name:** vega\n" {
+		t.Errorf("restore by hash gave %q", got)
+	}
+	tags := strings.Fields(runGit(t, dir, "tag", "--list", "spirit/pre-restore-*"))
+	if len(tags) != 1 {
+		t.Fatalf("safety tags = %v, want one", tags)
+	}
+	if got := runGit(t, dir, "show", tags[0]+":IDENTITY.md"); got != "- **Name:** edited" {
+		t.Errorf("safety tag %s has IDENTITY.md %q, want the edited one", tags[0], got)
+	}
+	for path, want := range map[string]os.FileMode{memory: 0600, filepath.Join(dir, "spirit.json"): 0600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s restored with mode %v, want %v", path, info.Mode().Perm(), want)
+		}
+	}
+
+	if err := restoreSpirit("lyra", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, identity); got != "- **Name:** lyra\n" {
+		t.Errorf("restore by tag gave %q", got)
+	}
+
+	if err := restoreSpirit("2020-01-01 18:00", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, identity); got != "- **Name:** vega\n" {
+		t.Errorf("restore by timestamp gave %q", got)
+	}
+}
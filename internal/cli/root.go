@@ -2,7 +2,6 @@ package cli
 
 import (
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -27,26 +26,47 @@ Don't backup. Resurrect.
 
 Complete documentation: https://spirit.theorionai.io`,
 		Version: Version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return selectConfigDir()
+		},
 	}
+	rootCmd.PersistentFlags().StringVar(&agentFlag, "agent", "", "Agent profile to act on (default $SPIRIT_AGENT, or the state in ~/.spirit)")
+	rootCmd.PersistentFlags().StringVar(&configDirFlag, "config-dir", "", "State directory to act on (default $SPIRIT_CONFIG_DIR or ~/.spirit)")
+	rootCmd.PersistentFlags().BoolVar(&lockNoWait, "no-wait", false, "Fail at once instead of waiting when another spirit command holds the lock")
+	rootCmd.PersistentFlags().DurationVar(&lockTimeout, "lock-timeout", lockTimeout, "How long to wait for another spirit command to finish")
+	rootCmd.PersistentFlags().StringVar(&secretsMode, "secrets", "", "What to do with secrets found in tracked files: block, redact or off (default $SPIRIT_SECRETS, else block at a terminal and redact otherwise)")
 
 	rootCmd.AddCommand(initCmd())
+	rootCmd.AddCommand(agentsCmd())
 	rootCmd.AddCommand(migrateCmd())
 	rootCmd.AddCommand(backupCmd())
 	rootCmd.AddCommand(autoBackupCmd())
+	rootCmd.AddCommand(daemonCmd())
 	rootCmd.AddCommand(checkpointCmd())
 	rootCmd.AddCommand(restoreCmd())
 	rootCmd.AddCommand(syncCmd())
+	rootCmd.AddCommand(conflictsCmd())
 	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(doctorCmd())
+	rootCmd.AddCommand(logCmd())
+	rootCmd.AddCommand(diffCmd())
+	rootCmd.AddCommand(exportCmd())
+	rootCmd.AddCommand(importCmd())
+	rootCmd.AddCommand(bundleCmd())
+	rootCmd.AddCommand(renderCmd())
+	rootCmd.AddCommand(configCmd())
+	rootCmd.AddCommand(keyCmd())
+	rootCmd.AddCommand(cryptCmd())
+	rootCmd.AddCommand(mergeDriverCmd())
 
 	return rootCmd.Execute()
 }
 
 func getConfigDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ".spirit"
+	if configDir := os.Getenv("SPIRIT_CONFIG_DIR"); configDir != "" {
+		return configDir
 	}
-	return filepath.Join(home, ".spirit")
+	return defaultConfigDir()
 }
 
 // getSourceDir returns the directory containing actual state files
@@ -0,0 +1,446 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scheduleSuffix tells the schedules of several state directories apart:
+// empty for ~/.spirit, the agent name for a profile, or a hash of the
+// directory when it was given with --config-dir or SPIRIT_CONFIG_DIR.
+func scheduleSuffix() string {
+	switch {
+	case activeAgent != "":
+		return "-" + activeAgent
+	case filepath.Clean(ConfigDir) == defaultConfigDir():
+		return ""
+	}
+	return "-" + sha256Hex([]byte(filepath.Clean(ConfigDir)))[:8]
+}
+
+// systemdUnitName names the systemd service and timer of the state
+// directory, e.g. spirit-backup-atlas.
+func systemdUnitName() string {
+	return "spirit-backup" + scheduleSuffix()
+}
+
+// cronMarker ends the crontab line of the state directory.
+func cronMarker() string {
+	return "# spirit-autobackup" + scheduleSuffix()
+}
+
+// installSchedule sets up a systemd user timer or a crontab entry that runs
+// 'spirit backup' every interval.
+func installSchedule(scheduler string, interval time.Duration) error {
+	switch scheduler {
+	case "systemd":
+		return installSystemd(interval)
+	case "cron":
+		return installCron(interval)
+	default:
+		return fmt.Errorf("unknown scheduler %q (use systemd or cron)", scheduler)
+	}
+}
+
+// validateSchedule checks that scheduler can run every interval before
+// anything already installed is replaced.
+func validateSchedule(scheduler string, interval time.Duration) error {
+	switch scheduler {
+	case "systemd":
+		return nil
+	case "cron":
+		_, err := cronSchedule(interval)
+		return err
+	default:
+		return fmt.Errorf("unknown scheduler %q (use systemd or cron)", scheduler)
+	}
+}
+
+// uninstallSchedule removes every schedule spirit may have installed.
+func uninstallSchedule() error {
+	if err := uninstallSystemd(); err != nil {
+		return err
+	}
+	return uninstallCron()
+}
+
+// verifySchedule reports whether the installed schedule is in place and
+// points at an existing spirit binary.
+func verifySchedule(scheduler string) error {
+	switch scheduler {
+	case "systemd":
+		return verifySystemd()
+	case "cron":
+		return verifyCron()
+	case "":
+		return fmt.Errorf("no scheduler installed. Run: spirit autobackup --interval=15m --install=systemd")
+	default:
+		return fmt.Errorf("unknown scheduler %q", scheduler)
+	}
+}
+
+// backupCommandLine is the command the scheduler runs, with the environment
+// needed to find the same state and source directories.
+func backupCommandLine() (exe string, env []string, err error) {
+	exe, err = os.Executable()
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot locate spirit binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	env = []string{"SPIRIT_CONFIG_DIR=" + ConfigDir}
+	if activeAgent != "" {
+		env = []string{"SPIRIT_AGENT=" + activeAgent}
+	}
+	if sourceDir := getSourceDir(); sourceDir != ConfigDir {
+		env = append(env, "SPIRIT_SOURCE_DIR="+sourceDir)
+	}
+	return exe, env, nil
+}
+
+func systemdUserDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+func installSystemd(interval time.Duration) error {
+	unit := systemdUnitName()
+	dir, err := systemdUserDir()
+	if err != nil {
+		return err
+	}
+	exe, env, err := backupCommandLine()
+	if err != nil {
+		return err
+	}
+
+	var service strings.Builder
+	service.WriteString("[Unit]\n")
+	if activeAgent != "" {
+		service.WriteString(fmt.Sprintf("Description=SPIRIT state backup (%s)\n", activeAgent))
+	} else {
+		service.WriteString("Description=SPIRIT state backup\n")
+	}
+	service.WriteString("After=network-online.target\n\n")
+	service.WriteString("[Service]\n")
+	service.WriteString("Type=oneshot\n")
+	for _, e := range env {
+		service.WriteString(fmt.Sprintf("Environment=%s\n", systemdQuote(e)))
+	}
+	service.WriteString(fmt.Sprintf("ExecStart=%s backup --message \"Scheduled backup\"\n",
+		systemdQuote(strings.ReplaceAll(exe, "$", "$$"))))
+
+	timer := fmt.Sprintf(`[Unit]
+Description=Run SPIRIT backup every %[1]s
+
+[Timer]
+OnBootSec=5min
+OnUnitActiveSec=%[1]s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`, systemdDuration(interval))
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, unit+".service"), []byte(service.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, unit+".timer"), []byte(timer), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("   Wrote %s/%s.{service,timer}\n", dir, unit)
+
+	if err := systemctl("daemon-reload"); err != nil {
+		fmt.Println("   systemctl --user unavailable, enable manually with:")
+		fmt.Printf("   systemctl --user enable --now %s.timer\n", unit)
+		return nil
+	}
+	return systemctl("enable", "--now", unit+".timer")
+}
+
+func uninstallSystemd() error {
+	unit := systemdUnitName()
+	dir, err := systemdUserDir()
+	if err != nil {
+		return err
+	}
+	timerPath := filepath.Join(dir, unit+".timer")
+	if _, err := os.Stat(timerPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	systemctl("disable", "--now", unit+".timer")
+	for _, ext := range []string{".timer", ".service"} {
+		if err := os.Remove(filepath.Join(dir, unit+ext)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	systemctl("daemon-reload")
+	fmt.Println("   Removed systemd timer")
+	return nil
+}
+
+func verifySystemd() error {
+	unit := systemdUnitName()
+	dir, err := systemdUserDir()
+	if err != nil {
+		return err
+	}
+	service, err := os.ReadFile(filepath.Join(dir, unit+".service"))
+	if err != nil {
+		return fmt.Errorf("systemd service missing: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, unit+".timer")); err != nil {
+		return fmt.Errorf("systemd timer missing: %w", err)
+	}
+	for _, line := range strings.Split(string(service), "\n") {
+		if command, ok := strings.CutPrefix(line, "ExecStart="); ok {
+			unescape := strings.NewReplacer("%%", "%", "$$", "$")
+			words := splitWords(command)
+			for i := range words {
+				words[i] = unescape.Replace(words[i])
+			}
+			if err := checkScheduledBinary(words); err != nil {
+				return err
+			}
+		}
+	}
+	if err := systemctl("is-enabled", "--quiet", unit+".timer"); err != nil {
+		return fmt.Errorf("%s.timer is not enabled", unit)
+	}
+	if err := systemctl("is-active", "--quiet", unit+".timer"); err != nil {
+		return fmt.Errorf("%s.timer is not active", unit)
+	}
+	return nil
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %s", strings.Join(args, " "), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// systemdQuote quotes a unit file value so spaces, quotes, backslashes and
+// % specifiers in paths are taken literally.
+func systemdQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(value) + `"`
+}
+
+// systemdDuration formats a duration as a systemd time span, e.g. "1h30min"
+// or "1min30s".
+func systemdDuration(d time.Duration) string {
+	var b strings.Builder
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{{time.Hour, "h"}, {time.Minute, "min"}, {time.Second, "s"}, {time.Millisecond, "ms"}} {
+		if n := d / unit.size; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.name)
+			d -= n * unit.size
+		}
+	}
+	return b.String()
+}
+
+// cronSchedule converts an interval into a cron expression. Cron can only
+// express intervals that divide evenly into an hour or a day.
+func cronSchedule(d time.Duration) (string, error) {
+	switch {
+	case d < time.Hour && d%time.Minute == 0 && 60%int(d.Minutes()) == 0:
+		return fmt.Sprintf("*/%d * * * *", int(d.Minutes())), nil
+	case d == time.Hour:
+		return "0 * * * *", nil
+	case d < 24*time.Hour && d%time.Hour == 0 && 24%int(d.Hours()) == 0:
+		return fmt.Sprintf("0 */%d * * *", int(d.Hours())), nil
+	case d == 24*time.Hour:
+		return "0 0 * * *", nil
+	}
+	return "", fmt.Errorf("cron cannot run every %s; use an interval that divides an hour or a day, or --install=systemd", d)
+}
+
+// cronQuote quotes a word of a crontab command for the shell. Cron turns a
+// bare % into a newline, so those are escaped as well.
+func cronQuote(word string) string {
+	return strings.ReplaceAll("'"+strings.ReplaceAll(word, "'", `'\''`)+"'", "%", `\%`)
+}
+
+func readCrontab() (string, error) {
+	output, err := exec.Command("crontab", "-l").CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "no crontab") {
+			return "", nil
+		}
+		return "", fmt.Errorf("crontab -l: %s", strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+func writeCrontab(content string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(content)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("crontab: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// withoutSpiritEntries drops the lines spirit added to a crontab.
+func withoutSpiritEntries(crontab string) string {
+	var kept bytes.Buffer
+	for _, line := range strings.Split(strings.TrimRight(crontab, "\n"), "\n") {
+		if strings.HasSuffix(line, cronMarker()) {
+			continue
+		}
+		kept.WriteString(line + "\n")
+	}
+	if strings.TrimSpace(kept.String()) == "" {
+		return ""
+	}
+	return kept.String()
+}
+
+func hasSpiritEntry(crontab string) bool {
+	for _, line := range strings.Split(crontab, "\n") {
+		if strings.HasSuffix(line, cronMarker()) {
+			return true
+		}
+	}
+	return false
+}
+
+func installCron(interval time.Duration) error {
+	schedule, err := cronSchedule(interval)
+	if err != nil {
+		return err
+	}
+	exe, env, err := backupCommandLine()
+	if err != nil {
+		return err
+	}
+	current, err := readCrontab()
+	if err != nil {
+		return err
+	}
+
+	for i, e := range env {
+		name, value, _ := strings.Cut(e, "=")
+		env[i] = name + "=" + cronQuote(value)
+	}
+	logPath := filepath.Join(ConfigDir, "autobackup.log")
+	entry := fmt.Sprintf("%s %s %s backup --message \"Scheduled backup\" >> %s 2>&1 %s",
+		schedule, strings.Join(env, " "), cronQuote(exe), cronQuote(logPath), cronMarker())
+
+	if err := writeCrontab(withoutSpiritEntries(current) + entry + "\n"); err != nil {
+		return err
+	}
+	fmt.Printf("   Installed crontab entry: %s\n", schedule)
+	return nil
+}
+
+func uninstallCron() error {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return nil
+	}
+	current, err := readCrontab()
+	if err != nil || !hasSpiritEntry(current) {
+		return err
+	}
+	if err := writeCrontab(withoutSpiritEntries(current)); err != nil {
+		return err
+	}
+	fmt.Println("   Removed crontab entry")
+	return nil
+}
+
+func verifyCron() error {
+	current, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(current, "\n") {
+		if strings.HasSuffix(line, cronMarker()) {
+			return checkScheduledBinary(splitWords(strings.ReplaceAll(line, `\%`, "%")))
+		}
+	}
+	return fmt.Errorf("no spirit entry in crontab")
+}
+
+// checkScheduledBinary makes sure the spirit binary, the first absolute path
+// among the words of an ExecStart or crontab line, still exists.
+func checkScheduledBinary(words []string) error {
+	for _, field := range words {
+		if filepath.IsAbs(field) {
+			if _, err := os.Stat(field); err != nil {
+				return fmt.Errorf("scheduled binary %s is missing", field)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// splitWords splits a command line into words the way the shell and systemd
+// do for the lines spirit writes: single quotes are literal, double quotes
+// and bare words take backslash escapes.
+func splitWords(line string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSystemdDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		time.Minute:                       "1min",
+		90 * time.Second:                  "1min30s",
+		15 * time.Minute:                  "15min",
+		90 * time.Minute:                  "1h30min",
+		24 * time.Hour:                    "24h",
+		time.Hour + 1500*time.Millisecond: "1h1s500ms",
+	}
+	for d, want := range cases {
+		if got := systemdDuration(d); got != want {
+			t.Errorf("systemdDuration(%s) = %s, want %s", d, got, want)
+		}
+	}
+}
+
+func TestScheduleQuoting(t *testing.T) {
+	path := `/home/o'brien/My "Apps"/100%\spirit`
+
+	unit := systemdQuote(strings.ReplaceAll(path, "$", "$$"))
+	if want := `"/home/o'brien/My \"Apps\"/100%%\\spirit"`; unit != want {
+		t.Errorf("systemdQuote = %s, want %s", unit, want)
+	}
+	words := splitWords(unit + ` backup --message "Scheduled backup"`)
+	if len(words) != 4 || strings.ReplaceAll(words[0], "%%", "%") != path || words[3] != "Scheduled backup" {
+		t.Errorf("ExecStart words = %q", words)
+	}
+
+	line := "*/15 * * * * SPIRIT_CONFIG_DIR=" + cronQuote(path) + " " + cronQuote(path) + " backup"
+	words = splitWords(strings.ReplaceAll(line, `\%`, "%"))
+	if len(words) != 8 || words[5] != "SPIRIT_CONFIG_DIR="+path || words[6] != path {
+		t.Errorf("crontab words = %q", words)
+	}
+
+	// What cron hands to the shell, once it has unescaped \%
+	if _, err := exec.LookPath("sh"); err == nil {
+		command := strings.ReplaceAll("printf %s "+cronQuote(path), `\%`, "%")
+		if out, err := exec.Command("sh", "-c", command).Output(); err != nil || string(out) != path {
+			t.Errorf("sh -c %s = %q, %v; want %q", command, out, err, path)
+		}
+	}
+}
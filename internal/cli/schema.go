@@ -0,0 +1,466 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// spirit.json and .spirit-tracked carry a schema version. Older files are
+// upgraded step by step when they are loaded, after a copy of the original
+// is kept next to them. Files from a newer spirit are refused: saving them
+// through older structs would silently drop what this version does not know.
+
+const (
+	// 1.0.0 was written by 'spirit init', 1.1.0 by workspace init; 1.2.0
+	// adds the sync section and disabled backends.
+	configVersion  = "1.2.0"
+	trackedVersion = "1.0.0"
+)
+
+// schemaUpgrade moves a document from one version to the next. Documents
+// are handled as generic JSON so fields survive the upgrade untouched.
+type schemaUpgrade struct {
+	from, to string
+	apply    func(doc map[string]any)
+}
+
+var configUpgrades = []schemaUpgrade{
+	// Files written by hand before versions were recorded
+	{from: "", to: "1.0.0", apply: func(doc map[string]any) {}},
+	// 1.0.0 wrote "backends": null when there were none
+	{from: "1.0.0", to: "1.1.0", apply: func(doc map[string]any) {
+		if doc["backends"] == nil {
+			doc["backends"] = map[string]any{}
+		}
+	}},
+	// Soul lists are always lists
+	{from: "1.1.0", to: "1.2.0", apply: func(doc map[string]any) {
+		if soul, ok := doc["soul"].(map[string]any); ok {
+			for _, key := range []string{"core_truths", "boundaries"} {
+				if soul[key] == nil {
+					soul[key] = []any{}
+				}
+			}
+		}
+	}},
+}
+
+var trackedUpgrades = []schemaUpgrade{
+	{from: "", to: "1.0.0", apply: func(doc map[string]any) {}},
+}
+
+// schemaFile describes one versioned file.
+type schemaFile struct {
+	name     string
+	version  string
+	upgrades []schemaUpgrade
+	schema   string
+}
+
+var (
+	configSchemaFile  = schemaFile{name: "spirit.json", version: configVersion, upgrades: configUpgrades, schema: configSchema}
+	trackedSchemaFile = schemaFile{name: ".spirit-tracked", version: trackedVersion, upgrades: trackedUpgrades, schema: trackedSchema}
+)
+
+const configSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://spirit.theorionai.io/schema/spirit-1.2.0.json",
+  "title": "spirit.json",
+  "type": "object",
+  "required": ["version", "identity"],
+  "additionalProperties": false,
+  "properties": {
+    "version": { "type": "string", "pattern": "^[0-9]+\\.[0-9]+\\.[0-9]+$" },
+    "backends": {
+      "type": ["object", "null"],
+      "additionalProperties": {
+        "type": "object",
+        "required": ["type"],
+        "additionalProperties": false,
+        "properties": {
+          "type": { "enum": ["git", "github", "gitlab", "s3", "dir", "workspace"] },
+          "config": { "type": ["object", "null"], "additionalProperties": { "type": "string" } },
+          "disabled": { "type": "boolean" }
+        }
+      }
+    },
+    "identity": {
+      "type": "object",
+      "required": ["name"],
+      "additionalProperties": false,
+      "properties": {
+        "name": { "type": "string", "minLength": 1 },
+        "emoji": { "type": "string" },
+        "email": { "type": "string" },
+        "description": { "type": "string" },
+        "created_at": { "type": "string", "format": "date-time" }
+      }
+    },
+    "soul": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "vibe": { "type": "string" },
+        "core_truths": { "type": ["array", "null"], "items": { "type": "string" } },
+        "boundaries": { "type": ["array", "null"], "items": { "type": "string" } }
+      }
+    },
+    "sync": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "concurrency": { "type": "integer", "minimum": 1 },
+        "quorum": { "type": "integer", "minimum": 1 }
+      }
+    },
+    "created_at": { "type": "string", "format": "date-time" }
+  }
+}
+`
+
+const trackedSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://spirit.theorionai.io/schema/spirit-tracked-1.0.0.json",
+  "title": ".spirit-tracked",
+  "type": "object",
+  "required": ["version", "files"],
+  "additionalProperties": false,
+  "properties": {
+    "version": { "type": "string", "pattern": "^[0-9]+\\.[0-9]+\\.[0-9]+$" },
+    "files": { "type": "array", "items": { "type": "string", "minLength": 1 } }
+  }
+}
+`
+
+// schemaFileFor picks the schema of a file by its name.
+func schemaFileFor(path string) (schemaFile, error) {
+	switch filepath.Base(path) {
+	case "spirit.json":
+		return configSchemaFile, nil
+	case ".spirit-tracked":
+		return trackedSchemaFile, nil
+	}
+	return schemaFile{}, fmt.Errorf("%s: not spirit.json or .spirit-tracked", path)
+}
+
+// compareVersions compares two x.y.z versions like strings.Compare.
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(v string) ([3]int, error) {
+	var parts [3]int
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, fmt.Errorf("invalid version %q", v)
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, fmt.Errorf("invalid version %q", v)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// documentVersion returns the version recorded in a document, or "" for
+// files written before versions were.
+func documentVersion(doc map[string]any) (string, error) {
+	switch v := doc["version"].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("version must be a string")
+}
+
+// NewerVersionError is a file written by a newer spirit than this one.
+type NewerVersionError struct {
+	File      string
+	Version   string
+	Supported string
+}
+
+func (e *NewerVersionError) Error() string {
+	return fmt.Sprintf("%s is version %s, newer than spirit %s understands (%s); upgrade spirit", e.File, e.Version, Version, e.Supported)
+}
+
+// checkVersion refuses versions newer than this spirit understands.
+func (f schemaFile) checkVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	cmp, err := compareVersions(version, f.version)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.name, err)
+	}
+	if cmp > 0 {
+		return &NewerVersionError{File: f.name, Version: version, Supported: f.version}
+	}
+	return nil
+}
+
+// upgrade runs the chain from the document's version to the current one
+// and returns the versions it went through.
+func (f schemaFile) upgrade(doc map[string]any) ([]string, error) {
+	version, err := documentVersion(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.name, err)
+	}
+	if err := f.checkVersion(version); err != nil {
+		return nil, err
+	}
+
+	steps := []string{}
+	for version != f.version {
+		found := false
+		for _, u := range f.upgrades {
+			if u.from == version {
+				u.apply(doc)
+				version = u.to
+				steps = append(steps, u.to)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: no upgrade from version %s", f.name, version)
+		}
+	}
+	doc["version"] = version
+	return steps, nil
+}
+
+// upgradeFile brings the file at path to the current version in place.
+// The original is kept as <file>.<version>.bak before anything is written.
+func (f schemaFile) upgradeFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", f.name, err)
+	}
+	from, _ := documentVersion(doc)
+	if from == f.version {
+		return nil
+	}
+	steps, err := f.upgrade(doc)
+	if err != nil {
+		return err
+	}
+
+	// A symlinked .spirit-tracked is upgraded where it really lives
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	label := from
+	if label == "" {
+		label = "unversioned"
+	}
+	backup := fmt.Sprintf("%s.%s.bak", path, label)
+	if _, err := os.Stat(backup); os.IsNotExist(err) {
+		if err := os.WriteFile(backup, data, 0600); err != nil {
+			return fmt.Errorf("cannot back up %s: %w", f.name, err)
+		}
+	}
+
+	upgraded, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(upgraded, '\n'), info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "⬆️  Upgraded %s from %s to %s (original kept in %s)\n", f.name, label, strings.Join(steps, " → "), filepath.Base(backup))
+	return nil
+}
+
+// validate checks a document against the file's schema, as it will be
+// once upgraded, and returns the version it was written with and every
+// problem found.
+func (f schemaFile) validate(data []byte) (string, []string, error) {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(f.schema), &schema); err != nil {
+		return "", nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return "", validateSchema(doc, schema, "$"), nil
+	}
+	version, err := documentVersion(m)
+	if err != nil {
+		return "", []string{"$.version: " + err.Error()}, nil
+	}
+	if _, err := f.upgrade(m); err != nil {
+		return version, nil, err
+	}
+	return version, validateSchema(m, schema, "$"), nil
+}
+
+// validateSchema checks value against the subset of JSON Schema the spirit
+// schemas use: type, enum, required, properties, additionalProperties,
+// items, pattern, minLength, minimum and the date-time format.
+func validateSchema(value any, schema map[string]any, path string) []string {
+	problems := []string{}
+	if types, ok := schema["type"]; ok && !matchesSchemaType(value, types) {
+		return append(problems, fmt.Sprintf("%s: expected %s, got %s", path, describeSchemaType(types), jsonTypeName(value)))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, allowed := range enum {
+			if allowed == value {
+				found = true
+			}
+		}
+		if !found {
+			names := []string{}
+			for _, allowed := range enum {
+				names = append(names, fmt.Sprint(allowed))
+			}
+			problems = append(problems, fmt.Sprintf("%s: %v is not one of %s", path, value, strings.Join(names, ", ")))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, key := range required {
+				if _, ok := v[key.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: missing %q", path, key))
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "." + key
+			if sub, ok := properties[key].(map[string]any); ok {
+				problems = append(problems, validateSchema(v[key], sub, child)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					problems = append(problems, fmt.Sprintf("%s: unknown field", child))
+				}
+			case map[string]any:
+				problems = append(problems, validateSchema(v[key], extra, child)...)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				problems = append(problems, validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && float64(len(v)) < min {
+			problems = append(problems, fmt.Sprintf("%s: must not be empty", path))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+			problems = append(problems, fmt.Sprintf("%s: %q does not match %s", path, v, pattern))
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not an RFC 3339 time", path, v))
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			problems = append(problems, fmt.Sprintf("%s: %v is less than %v", path, v, min))
+		}
+	}
+	return problems
+}
+
+func matchesSchemaType(value any, types any) bool {
+	names := []any{types}
+	if list, ok := types.([]any); ok {
+		names = list
+	}
+	for _, name := range names {
+		switch name {
+		case "integer":
+			if n, ok := value.(float64); ok && n == float64(int64(n)) {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		default:
+			if jsonTypeName(value) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeSchemaType(types any) string {
+	if list, ok := types.([]any); ok {
+		names := []string{}
+		for _, name := range list {
+			names = append(names, fmt.Sprint(name))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
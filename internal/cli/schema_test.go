@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchemaUpgradeChain(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(`{"identity": {"name": "orion"}, "backends": null, "soul": {"vibe": "warm", "core_truths": null}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := configSchemaFile.upgrade(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1.0.0", "1.1.0", "1.2.0"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+	want := map[string]any{
+		"version":  configVersion,
+		"identity": map[string]any{"name": "orion"},
+		"backends": map[string]any{},
+		"soul":     map[string]any{"vibe": "warm", "core_truths": []any{}, "boundaries": []any{}},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("upgraded = %v, want %v", doc, want)
+	}
+
+	// A current document goes nowhere, an unknown one cannot be upgraded
+	if steps, err := configSchemaFile.upgrade(map[string]any{"version": configVersion}); err != nil || len(steps) != 0 {
+		t.Errorf("current upgrade = %v, %v", steps, err)
+	}
+	if _, err := configSchemaFile.upgrade(map[string]any{"version": "1.0.5"}); err == nil || !strings.Contains(err.Error(), "no upgrade from version 1.0.5") {
+		t.Errorf("unknown version upgrade = %v", err)
+	}
+	if _, err := configSchemaFile.upgrade(map[string]any{"version": 1}); err == nil {
+		t.Error("a numeric version was accepted")
+	}
+}
+
+func TestSchemaUpgradeFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "spirit.json")
+	original := `{"version": "1.0.0", "identity": {"name": "orion"}, "backends": null, "custom": {"kept": true}}`
+	if err := os.WriteFile(path, []byte(original), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := configSchemaFile.upgradeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	backup := path + ".1.0.0.bak"
+	if got := readTestFile(t, backup); got != original {
+		t.Errorf("backup = %q, want the original", got)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(readTestFile(t, path)), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["version"] != configVersion || !reflect.DeepEqual(doc["backends"], map[string]any{}) || !reflect.DeepEqual(doc["custom"], map[string]any{"kept": true}) {
+		t.Errorf("upgraded = %v", doc)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("upgraded file mode = %v, %v; want 0600", info, err)
+	}
+
+	// Upgrading again changes nothing, and an existing backup is never replaced
+	upgraded := readTestFile(t, path)
+	if err := configSchemaFile.upgradeFile(path); err != nil || readTestFile(t, path) != upgraded {
+		t.Errorf("second upgrade = %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"identity": {"name": "orion"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(backup, []byte("first backup"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := configSchemaFile.upgradeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(path+".unversioned.bak") || readTestFile(t, backup) != "first backup" {
+		t.Error("unversioned file not backed up on its own")
+	}
+}
+
+func TestSchemaRefusesNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".spirit-tracked")
+	newer := `{"version": "1.1.0", "files": ["*.md"], "exclude": ["secret.md"]}`
+	if err := os.WriteFile(path, []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var versionErr *NewerVersionError
+	if err := trackedSchemaFile.upgradeFile(path); !errors.As(err, &versionErr) {
+		t.Fatalf("upgrade = %v, want NewerVersionError", err)
+	}
+	if versionErr.File != ".spirit-tracked" || versionErr.Version != "1.1.0" || versionErr.Supported != trackedVersion {
+		t.Errorf("error = %+v", versionErr)
+	}
+	if readTestFile(t, path) != newer {
+		t.Error("the newer file was rewritten")
+	}
+	if backups, _ := filepath.Glob(filepath.Join(dir, "*.bak")); len(backups) != 0 {
+		t.Errorf("backups written: %v", backups)
+	}
+	if _, _, err := trackedSchemaFile.validate([]byte(newer)); !errors.As(err, &versionErr) {
+		t.Errorf("validate = %v, want NewerVersionError", err)
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		doc      string
+		version  string
+		problems []string
+	}{
+		{"valid", `{"version": "1.2.0", "identity": {"name": "orion", "created_at": "2026-01-01T00:00:00Z"}, "backends": {"usb": {"type": "dir", "config": {"path": "/media/usb"}}}, "sync": {"quorum": 1}}`, "1.2.0", nil},
+		{"upgraded first", `{"identity": {"name": "orion"}, "backends": null}`, "", nil},
+		{"missing fields", `{"version": "1.2.0"}`, "1.2.0", []string{`$: missing "identity"`}},
+		{"unknown field", `{"version": "1.2.0", "identity": {"name": "orion", "nick": "o"}}`, "1.2.0", []string{"$.identity.nick: unknown field"}},
+		{"wrong type", `{"version": "1.2.0", "identity": {"name": 7}}`, "1.2.0", []string{"$.identity.name: expected string, got number"}},
+		{"empty name", `{"version": "1.2.0", "identity": {"name": ""}}`, "1.2.0", []string{"$.identity.name: must not be empty"}},
+		{"enum", `{"version": "1.2.0", "identity": {"name": "o"}, "backends": {"x": {"type": "ftp"}}}`, "1.2.0", []string{"$.backends.x.type: ftp is not one of git, github, gitlab, s3, dir, workspace"}},
+		{"nested map values", `{"version": "1.2.0", "identity": {"name": "o"}, "backends": {"x": {"type": "dir", "config": {"keep": 3}}}}`, "1.2.0", []string{"$.backends.x.config.keep: expected string, got number"}},
+		{"date-time", `{"version": "1.2.0", "identity": {"name": "o", "created_at": "yesterday"}}`, "1.2.0", []string{`$.identity.created_at: "yesterday" is not an RFC 3339 time`}},
+		{"integer minimum", `{"version": "1.2.0", "identity": {"name": "o"}, "sync": {"quorum": 0, "concurrency": 1.5}}`, "1.2.0", []string{"$.sync.concurrency: expected integer, got number", "$.sync.quorum: 0 is less than 1"}},
+		{"array items", `{"version": "1.2.0", "identity": {"name": "o"}, "soul": {"boundaries": ["ok", 3]}}`, "1.2.0", []string{"$.soul.boundaries[1]: expected string, got number"}},
+		{"not an object", `["orion"]`, "", []string{"$: expected object, got array"}},
+		{"bad version", `{"version": 1, "identity": {"name": "o"}}`, "", []string{"$.version: version must be a string"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			version, problems, err := configSchemaFile.validate([]byte(c.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != c.version {
+				t.Errorf("version = %q, want %q", version, c.version)
+			}
+			if strings.Join(problems, "\n") != strings.Join(c.problems, "\n") {
+				t.Errorf("problems = %q, want %q", problems, c.problems)
+			}
+		})
+	}
+
+	if _, _, err := configSchemaFile.validate([]byte("{")); err == nil {
+		t.Error("invalid JSON validated")
+	}
+	if _, problems, err := trackedSchemaFile.validate([]byte(`{"version": "1.0.0", "files": ["*.md", ""]}`)); err != nil || len(problems) != 1 {
+		t.Errorf(".spirit-tracked problems = %q, %v", problems, err)
+	}
+}
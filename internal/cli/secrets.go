@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Agents paste credentials into memory and notes. Before anything is
+// staged, tracked files are scanned for secrets and the checkpoint is
+// either blocked or the secrets are redacted in what is committed. The
+// files themselves are never changed: they are the agent's working state.
+
+const (
+	allowlistFile  = ".spirit-allowlist"
+	allowComment   = "spirit:allow"
+	redactedFormat = "[REDACTED:%s]"
+)
+
+// secretsMode is set by the global --secrets flag: block, redact or off.
+var secretsMode string
+
+// unattended is set by commands that run with nobody to act on a blocked
+// checkpoint, such as the daemon.
+var unattended bool
+
+type secretRule struct {
+	ID      string
+	Pattern *regexp.Regexp
+	// Group is the submatch holding the secret itself; 0 for the whole match.
+	Group int
+	// MinEntropy filters out placeholders and prose for generic rules.
+	MinEntropy float64
+}
+
+var secretRules = []secretRule{
+	{ID: "github-token", Pattern: regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b`)},
+	{ID: "github-pat", Pattern: regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`)},
+	{ID: "gitlab-token", Pattern: regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{ID: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{ID: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws.{0,20}secret.{0,20}[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`), Group: 1},
+	{ID: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`)},
+	{ID: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{ID: "slack-token", Pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{ID: "stripe-key", Pattern: regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{20,}\b`)},
+	{ID: "google-api-key", Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{ID: "openai-key", Pattern: regexp.MustCompile(`\bsk-(?:proj-|ant-)?[A-Za-z0-9_-]{32,}`)},
+	{ID: "url-credentials", Pattern: regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^\s:/@]+:([^\s:/@]{6,})@`), Group: 1},
+	{ID: "password-assignment", Pattern: regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret)\b["']?\s*[:=]\s*["']?([^\s"'` + "`" + `]{8,})`), Group: 1, MinEntropy: 3.0},
+	{ID: "high-entropy", Pattern: regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`), MinEntropy: 4.5},
+}
+
+// secretFinding is one suspected secret in a tracked file.
+type secretFinding struct {
+	Path   string
+	Line   int
+	Rule   string
+	Secret string
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", f.Path, f.Line, f.Rule, maskSecret(f.Secret))
+}
+
+func maskSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", 8)
+}
+
+// shannonEntropy returns the bits of entropy per character of s.
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	n := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// isPlaceholder reports whether a value is a template rather than a
+// secret, e.g. <your-token>, ${API_KEY} or {{ token }}.
+func isPlaceholder(value string) bool {
+	return strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">") ||
+		strings.HasPrefix(value, "${") || strings.HasPrefix(value, "{{") || strings.HasPrefix(value, "$(")
+}
+
+// secretAllowlist holds the entries of .spirit-allowlist:
+//
+//	path:<glob>   ignore every finding in matching files
+//	rule:<id>     disable a rule entirely
+//	<regexp>      ignore secrets matching the expression
+type secretAllowlist struct {
+	paths    []string
+	rules    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func loadSecretAllowlist() (*secretAllowlist, error) {
+	allow := &secretAllowlist{rules: map[string]bool{}}
+	data, err := os.ReadFile(filepath.Join(ConfigDir, allowlistFile))
+	if os.IsNotExist(err) {
+		return allow, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "path:"):
+			allow.paths = append(allow.paths, strings.TrimSpace(strings.TrimPrefix(line, "path:")))
+		case strings.HasPrefix(line, "rule:"):
+			allow.rules[strings.TrimSpace(strings.TrimPrefix(line, "rule:"))] = true
+		default:
+			re, err := regexp.Compile(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", allowlistFile, i+1, err)
+			}
+			allow.patterns = append(allow.patterns, re)
+		}
+	}
+	return allow, nil
+}
+
+func (a *secretAllowlist) allowsPath(path string) bool {
+	for _, pattern := range a.paths {
+		if matched, _ := filepath.Match(pattern, filepath.ToSlash(path)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *secretAllowlist) allowsSecret(rule, secret string) bool {
+	if a.rules[rule] {
+		return true
+	}
+	for _, re := range a.patterns {
+		if re.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretMatch is the byte range of a secret within a line.
+type secretMatch struct {
+	rule       string
+	start, end int
+}
+
+// scanLine returns the secrets found in one line, ordered by position.
+func scanLine(line string, allow *secretAllowlist) []secretMatch {
+	var found []secretMatch
+	if strings.Contains(line, allowComment) {
+		return found
+	}
+
+	overlaps := func(start, end int) bool {
+		for _, f := range found {
+			if start < f.end && end > f.start {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, rule := range secretRules {
+		for _, m := range rule.Pattern.FindAllStringSubmatchIndex(line, -1) {
+			start, end := m[2*rule.Group], m[2*rule.Group+1]
+			if start < 0 || overlaps(start, end) {
+				continue
+			}
+			secret := line[start:end]
+			if strings.HasPrefix(secret, "[REDACTED:") {
+				continue // already redacted
+			}
+			if rule.MinEntropy > 0 && (isPlaceholder(secret) || shannonEntropy(secret) < rule.MinEntropy) {
+				continue
+			}
+			if rule.ID == "high-entropy" && !strings.ContainsAny(secret, "0123456789") {
+				continue // long identifiers and words, not keys
+			}
+			if allow.allowsSecret(rule.ID, secret) {
+				continue
+			}
+			found = append(found, secretMatch{rule.ID, start, end})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].start < found[j].start })
+	return found
+}
+
+// scanSecrets scans files (relative to dir). It returns every finding and,
+// for each file with one, its content with the findings redacted.
+func scanSecrets(dir string, files []string) ([]secretFinding, map[string][]byte, error) {
+	allow, err := loadSecretAllowlist()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	findings := []secretFinding{}
+	redacted := map[string][]byte{}
+	for _, f := range files {
+		if allow.allowsPath(f) {
+			continue
+		}
+		path := filepath.Join(dir, f)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // staged deletion
+			}
+			return nil, nil, err
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue // binary
+		}
+
+		var out strings.Builder
+		changed := false
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Text()
+			matches := scanLine(line, allow)
+			for _, m := range matches {
+				findings = append(findings, secretFinding{Path: f, Line: n, Rule: m.rule, Secret: line[m.start:m.end]})
+			}
+			if len(matches) > 0 {
+				// Replace from the end so earlier offsets stay valid
+				for i := len(matches) - 1; i >= 0; i-- {
+					m := matches[i]
+					line = line[:m.start] + fmt.Sprintf(redactedFormat, m.rule) + line[m.end:]
+				}
+				changed = true
+			}
+			out.WriteString(line + "\n")
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", f, err)
+		}
+
+		if changed {
+			result := out.String()
+			if !bytes.HasSuffix(content, []byte("\n")) {
+				result = strings.TrimSuffix(result, "\n")
+			}
+			redacted[f] = []byte(result)
+		}
+	}
+	return findings, redacted, nil
+}
+
+// resolveSecretsMode returns the --secrets flag, SPIRIT_SECRETS, or the
+// default: block at a terminal, redact when nobody is there to fix the
+// files, so scheduled backups keep running.
+func resolveSecretsMode() (string, error) {
+	mode := secretsMode
+	if mode == "" {
+		mode = os.Getenv("SPIRIT_SECRETS")
+	}
+	if mode == "" {
+		mode = "block"
+		if unattended || !stdinIsTerminal() {
+			mode = "redact"
+		}
+	}
+	switch mode {
+	case "block", "redact", "off":
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid secrets mode %q (use block, redact or off)", mode)
+}
+
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// guardSecrets runs the secret scanner over files in ConfigDir before they
+// are staged. In block mode any finding stops the checkpoint; in redact
+// mode the redacted content of each file is returned for stageRedacted.
+func guardSecrets(files []string) (map[string][]byte, error) {
+	mode, err := resolveSecretsMode()
+	if err != nil || mode == "off" {
+		return nil, err
+	}
+
+	findings, redacted, err := scanSecrets(ConfigDir, files)
+	if err != nil {
+		return nil, fmt.Errorf("secret scan failed: %w", err)
+	}
+	if len(findings) == 0 {
+		return nil, nil
+	}
+
+	if mode == "redact" {
+		fmt.Printf("🔒 Redacted %d secrets in what is committed:\n", len(findings))
+		for _, f := range findings {
+			fmt.Printf("   %s\n", f)
+		}
+		return redacted, nil
+	}
+
+	fmt.Printf("🚫 Found %d possible secrets:\n", len(findings))
+	for _, f := range findings {
+		fmt.Printf("   %s\n", f)
+	}
+	fmt.Printf("\n   Remove them, rerun with --secrets=redact, or allow them in %s\n", allowlistFile)
+	fmt.Printf("   (path:<glob>, rule:<id>, a regexp, or a '%s' comment on the line)\n", allowComment)
+	return nil, fmt.Errorf("refusing to stage %d possible secrets", len(findings))
+}
+
+// stageRedacted replaces what is staged for each file with its redacted
+// content, once the files have been added.
+func stageRedacted(redacted map[string][]byte) error {
+	if len(redacted) == 0 {
+		return nil
+	}
+	git, err := activeGit()
+	if err != nil {
+		return err
+	}
+	for path, content := range redacted {
+		if err := git.StageContent(ConfigDir, path, content); err != nil {
+			return fmt.Errorf("cannot stage redacted %s: %w", path, err)
+		}
+	}
+	return nil
+}
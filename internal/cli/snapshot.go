@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotManifest lists the files of a snapshot held by an object or
+// directory backend. File contents are stored once per SHA-256.
+type snapshotManifest struct {
+	ID        string         `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Message   string         `json:"message,omitempty"`
+	Commit    string         `json:"commit,omitempty"`
+	Files     []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Blob is the object name of the content when it differs from SHA256,
+	// as it does for encrypted snapshots.
+	Blob string `json:"blob,omitempty"`
+}
+
+func (f manifestFile) blob() string {
+	if f.Blob != "" {
+		return f.Blob
+	}
+	return f.SHA256
+}
+
+// blobNamePattern matches blob names: a SHA-256, or for encrypted
+// snapshots an HMAC-SHA256, in lowercase hex.
+var blobNamePattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// validate checks a manifest read from a backend. Blob names become object
+// paths, so anything but a plain hash is refused.
+func (m *snapshotManifest) validate() error {
+	for _, f := range m.Files {
+		if !blobNamePattern.MatchString(f.SHA256) || (f.Blob != "" && !blobNamePattern.MatchString(f.Blob)) {
+			return fmt.Errorf("invalid blob name for %s", f.Path)
+		}
+	}
+	return nil
+}
+
+func (m *snapshotManifest) snapshot() Snapshot {
+	return Snapshot{ID: m.ID, CreatedAt: m.CreatedAt, Message: m.Message, Files: len(m.Files)}
+}
+
+// newerSnapshot orders snapshots newest first. Their IDs only have
+// one-second resolution, so they merely break ties.
+func newerSnapshot(a, b Snapshot) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// buildManifest hashes the tracked files currently in dir. spirit.json and
+// .spirit-tracked are always included so a snapshot is self-describing.
+func buildManifest(dir string) (*snapshotManifest, error) {
+	patterns, err := trackedFilesOrDefault(dir)
+	if err != nil {
+		return nil, err
+	}
+	patterns = append(patterns, "spirit.json", ".spirit-tracked")
+
+	files := collectTrackedFiles(dir, patterns)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no tracked files in %s", dir)
+	}
+	sort.Strings(files)
+
+	now := time.Now().UTC()
+	manifest := &snapshotManifest{
+		ID:        now.Format("20060102T150405Z"),
+		CreatedAt: now,
+	}
+
+	// Tie the snapshot to the checkpoint it was taken from, when there is one
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		if git, err := activeGit(); err == nil {
+			if commits, err := git.Log(dir, "HEAD", time.Time{}, 1); err == nil && len(commits) == 1 {
+				manifest.Commit = commits[0].Hash
+				manifest.Message = commits[0].Subject()
+				manifest.ID += "-" + commits[0].Hash[:7]
+			}
+		}
+	}
+
+	for _, f := range files {
+		content, err := os.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, manifestFile{
+			Path:   filepath.ToSlash(f),
+			Size:   int64(len(content)),
+			SHA256: sha256Hex(content),
+		})
+	}
+	return manifest, nil
+}
+
+// writeManifestFile decrypts content if needed, verifies it against its
+// manifest entry and writes it below dir.
+func writeManifestFile(dir string, f manifestFile, content []byte) error {
+	content, err := decryptContent(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Path, err)
+	}
+	if sha256Hex(content) != f.SHA256 {
+		return fmt.Errorf("%s: checksum mismatch", f.Path)
+	}
+	target := filepath.Join(dir, filepath.FromSlash(f.Path))
+	if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return fmt.Errorf("invalid path in manifest: %s", f.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, 0644)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -4,22 +4,40 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
 type SpiritStatus struct {
-	Initialized   bool       `json:"initialized"`
-	ConfigDir     string     `json:"config_dir"`
-	Version       string     `json:"version"`
-	LastBackup    *time.Time `json:"last_backup,omitempty"`
-	TrackedFiles  int        `json:"tracked_files"`
-	ExistingFiles int        `json:"existing_files"`
-	GitConfigured bool       `json:"git_configured"`
-	RemoteURL     string     `json:"remote_url,omitempty"`
+	Initialized   bool            `json:"initialized"`
+	Agent         string          `json:"agent,omitempty"`
+	ConfigDir     string          `json:"config_dir"`
+	Version       string          `json:"version"`
+	LastBackup    *time.Time      `json:"last_backup,omitempty"`
+	TrackedFiles  int             `json:"tracked_files"`
+	ExistingFiles int             `json:"existing_files"`
+	GitConfigured bool            `json:"git_configured"`
+	Branch        string          `json:"branch,omitempty"`
+	RemoteURL     string          `json:"remote_url,omitempty"`
+	Backends      []BackendStatus `json:"backends,omitempty"`
+	Lock          *LockInfo       `json:"lock,omitempty"`
+	Conflicts     int             `json:"conflicts,omitempty"`
+	IdentityDrift []string        `json:"identity_drift,omitempty"`
+	IdentityNewer bool            `json:"identity_newer,omitempty"`
+	ConfigError   string          `json:"config_error,omitempty"`
+}
+
+type BackendStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// Branch, Ahead and Behind compare a git backend with the local branch
+	Branch string `json:"branch,omitempty"`
+	Ahead  int    `json:"ahead,omitempty"`
+	Behind int    `json:"behind,omitempty"`
 }
 
 func statusCmd() *cobra.Command {
@@ -52,19 +70,11 @@ func showStatus() error {
 
 	status := SpiritStatus{
 		Initialized: true,
+		Agent:       activeAgent,
 		ConfigDir:   ConfigDir,
 		Version:     Version,
 	}
 
-	// Load config for version
-	configPath := filepath.Join(ConfigDir, "spirit.json")
-	if data, err := os.ReadFile(configPath); err == nil {
-		var config Config
-		if err := json.Unmarshal(data, &config); err == nil {
-			_ = config // could extract version from here if needed
-		}
-	}
-
 	// Check tracked files
 	trackedPath := filepath.Join(ConfigDir, ".spirit-tracked")
 	if data, err := os.ReadFile(trackedPath); err == nil {
@@ -84,21 +94,51 @@ func showStatus() error {
 	if _, err := os.Stat(dotGit); err == nil {
 		status.GitConfigured = true
 
-		// Get remote URL
-		cmd := exec.Command("git", "remote", "get-url", "origin")
-		cmd.Dir = ConfigDir
-		if output, err := cmd.Output(); err == nil {
-			status.RemoteURL = string(output)
+		if git, err := activeGit(); err == nil {
+			if branch, err := git.CurrentBranch(ConfigDir); err == nil {
+				status.Branch = branch
+			}
+
+			// Get remote URL
+			if url, err := git.RemoteURL(ConfigDir, "origin"); err == nil {
+				status.RemoteURL = url
+			}
+
+			// Get last commit time
+			if t, err := git.LastCommitTime(ConfigDir); err == nil {
+				status.LastBackup = &t
+			}
 		}
+	}
+
+	// Check who holds the operation lock
+	if holder, err := readLock(); err == nil {
+		status.Lock = holder
+	}
 
-		// Get last commit time
-		cmd = exec.Command("git", "log", "-1", "--format=%ct")
-		cmd.Dir = ConfigDir
-		if output, err := cmd.Output(); err == nil {
-			var ts int64
-			fmt.Sscanf(string(output), "%d", &ts)
-			t := time.Unix(ts, 0)
-			status.LastBackup = &t
+	status.Conflicts = len(loadConflicts())
+
+	// Compare spirit.json with the IDENTITY.md and SOUL.md it mirrors
+	if config, err := loadConfig(); err == nil {
+		status.IdentityDrift, _ = identityDrift(config, getSourceDir())
+		status.IdentityNewer = configNewerThanMarkdown(getSourceDir())
+	} else if !os.IsNotExist(err) {
+		status.ConfigError = err.Error()
+	}
+
+	// Check every configured backend
+	if backends, err := loadBackends(); err == nil {
+		for _, backend := range backends {
+			bs := BackendStatus{Name: backend.Name(), Healthy: true}
+			if err := backend.Health(); err != nil {
+				bs.Healthy = false
+				bs.Error = err.Error()
+			} else if gb, ok := backend.(*gitBackend); ok && status.GitConfigured {
+				if branch, ahead, behind, err := gb.Tracking(); err == nil {
+					bs.Branch, bs.Ahead, bs.Behind = branch, ahead, behind
+				}
+			}
+			status.Backends = append(status.Backends, bs)
 		}
 	}
 
@@ -106,6 +146,9 @@ func showStatus() error {
 	fmt.Println("🌌 SPIRIT Status")
 	fmt.Println()
 	fmt.Printf("   Version:    %s\n", status.Version)
+	if status.Agent != "" {
+		fmt.Printf("   Agent:      %s\n", status.Agent)
+	}
 	fmt.Printf("   Config:     %s\n", status.ConfigDir)
 	fmt.Printf("   Initialized: Yes\n")
 
@@ -123,8 +166,11 @@ func showStatus() error {
 	fmt.Println()
 	if status.GitConfigured {
 		fmt.Println("   Git: ✓ Configured")
+		if status.Branch != "" {
+			fmt.Printf("   Branch: %s\n", status.Branch)
+		}
 		if status.RemoteURL != "" {
-			fmt.Printf("   Remote: %s", status.RemoteURL)
+			fmt.Printf("   Remote: %s\n", status.RemoteURL)
 		} else {
 			fmt.Println("   Remote: ✗ Not configured")
 			fmt.Println("           Run: git remote add origin <url>")
@@ -133,6 +179,43 @@ func showStatus() error {
 		fmt.Println("   Git: ✗ Not initialized")
 	}
 
+	if status.Lock != nil {
+		if status.Lock.Stale() {
+			fmt.Printf("   Lock: held by %s (stale)\n", status.Lock)
+		} else {
+			fmt.Printf("   Lock: held by %s\n", status.Lock)
+		}
+	} else {
+		fmt.Println("   Lock: free")
+	}
+
+	if status.Conflicts > 0 {
+		fmt.Printf("   Conflicts: %d unresolved (run: spirit conflicts)\n", status.Conflicts)
+	}
+	if status.ConfigError != "" {
+		fmt.Printf("   spirit.json: ✗ %s\n", status.ConfigError)
+	}
+	if len(status.IdentityDrift) > 0 && status.IdentityNewer {
+		fmt.Printf("   Identity: spirit.json was edited after IDENTITY.md/SOUL.md (%s); run 'spirit render' to update them\n", strings.Join(status.IdentityDrift, ", "))
+	} else if len(status.IdentityDrift) > 0 {
+		fmt.Printf("   Identity: spirit.json differs from IDENTITY.md/SOUL.md (%s); the next checkpoint updates it\n", strings.Join(status.IdentityDrift, ", "))
+	}
+
+	if len(status.Backends) > 0 {
+		fmt.Println()
+		fmt.Println("   Backends:")
+		for _, bs := range status.Backends {
+			switch {
+			case bs.Healthy && bs.Branch != "":
+				fmt.Printf("     ✓ %s (%s)\n", bs.Name, formatTracking(bs))
+			case bs.Healthy:
+				fmt.Printf("     ✓ %s\n", bs.Name)
+			default:
+				fmt.Printf("     ✗ %s: %s\n", bs.Name, bs.Error)
+			}
+		}
+	}
+
 	fmt.Println()
 	fmt.Println("   Commands:")
 	fmt.Println("     spirit sync    - Push state to remote")
@@ -141,6 +224,22 @@ func showStatus() error {
 	return nil
 }
 
+// formatTracking describes how far a git backend is from the local branch,
+// e.g. "main, 2 ahead, 1 behind".
+func formatTracking(bs BackendStatus) string {
+	if bs.Ahead == 0 && bs.Behind == 0 {
+		return bs.Branch + ", up to date"
+	}
+	parts := []string{bs.Branch}
+	if bs.Ahead > 0 {
+		parts = append(parts, fmt.Sprintf("%d ahead", bs.Ahead))
+	}
+	if bs.Behind > 0 {
+		parts = append(parts, fmt.Sprintf("%d behind", bs.Behind))
+	}
+	return strings.Join(parts, ", ")
+}
+
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
 		return fmt.Sprintf("%ds", int(d.Seconds()))
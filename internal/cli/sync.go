@@ -2,9 +2,9 @@ package cli
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -12,11 +12,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-type TrackedConfig struct {
-	Version string   `json:"version"`
-	Files   []string `json:"files"`
-}
-
 func syncCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "sync",
@@ -27,6 +22,11 @@ Environment variable SPIRIT_SOURCE_DIR:
   When set, reads files from this directory instead of ~/.spirit/
   The .spirit-tracked config is still read from ~/.spirit/ (which may be a symlink)
 
+Tracked files that were deleted or renamed in the source directory are
+removed from ~/.spirit/ as well, unless --no-delete is given. A sync that
+would remove every tracked file, e.g. because SPIRIT_SOURCE_DIR points at
+the wrong directory, is refused unless --delete-all is given.
+
 Examples:
   spirit sync                          # Sync from ~/.spirit/
   SPIRIT_SOURCE_DIR=/workspace spirit sync   # Sync from /workspace
@@ -35,14 +35,29 @@ Examples:
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			verbose, _ := cmd.Flags().GetBool("verbose")
-			return runSync(verbose)
+			noDelete, _ := cmd.Flags().GetBool("no-delete")
+			deleteAll, _ := cmd.Flags().GetBool("delete-all")
+			policy := deleteStale
+			switch {
+			case noDelete && deleteAll:
+				return fmt.Errorf("--no-delete and --delete-all cannot be combined")
+			case noDelete:
+				policy = keepStale
+			case deleteAll:
+				policy = deleteAllStale
+			}
+			return withLock(cmd.CommandPath(), func() error {
+				return runSync(verbose, policy)
+			})
 		},
 	}
 	cmd.Flags().Bool("verbose", false, "Verbose output")
+	cmd.Flags().Bool("no-delete", false, "Keep files that were deleted from the source directory")
+	cmd.Flags().Bool("delete-all", false, "Remove every tracked file when the source directory has none of them")
 	return cmd
 }
 
-func runSync(verbose bool) error {
+func runSync(verbose bool, policy deletePolicy) error {
 	sourceDir := getSourceDir()
 
 	if verbose {
@@ -66,73 +81,38 @@ func runSync(verbose bool) error {
 		}
 	}
 
-	// Check for remote
-	remoteURL, err := getRemoteURL()
-	if err != nil || remoteURL == "" {
-		fmt.Println("🔗 No remote configured. Set up with:")
-		fmt.Println("   cd ~/.spirit && git remote add origin <url>")
-		return fmt.Errorf("no remote configured")
+	// Resolve the backends configured in spirit.json
+	backends, err := loadBackends()
+	if err != nil {
+		return err
 	}
 
 	// Load tracked files from ConfigDir (or via symlink)
-	tracked, err := loadTrackedFiles()
+	tracked, err := trackedFilesOrDefault(ConfigDir)
 	if err != nil {
-		if verbose {
-			fmt.Printf("⚠️  Using defaults: %v\n", err)
-		}
-		tracked = []string{
-			"IDENTITY.md", "SOUL.md", "AGENTS.md", "TOOLS.md", "PROJECTS.md",
-			"HEARTBEAT.md", "README.md", "spirit.json", ".spirit-tracked",
-			"memory/*.md", "projects/*.md", "context/*.md",
-		}
+		return err
 	}
 
-	// Clear ConfigDir (except git) and copy fresh files from sourceDir
-	// This ensures the git repo always reflects the current workspace state
-	existingFiles := []string{}
-	missingFiles := []string{}
+	staged, err := stageSourceFiles(sourceDir, tracked, policy, verbose)
+	if err != nil {
+		return err
+	}
+	existingFiles, missingFiles := staged.Existing, staged.Missing
+	renames, staleFiles := staged.Renames, staged.Removed
 
-	for _, pattern := range tracked {
-		// Handle wildcards
-		if strings.Contains(pattern, "*") {
-			// Glob in source directory
-			matches, _ := filepath.Glob(filepath.Join(sourceDir, pattern))
-			for _, match := range matches {
-				if info, err := os.Stat(match); err == nil && !info.IsDir() {
-					relPath, _ := filepath.Rel(sourceDir, match)
-					// Copy to ConfigDir for sync
-					targetPath := filepath.Join(ConfigDir, relPath)
-					if err := copyFile(match, targetPath); err == nil {
-						existingFiles = append(existingFiles, relPath)
-					}
-				}
-			}
-		} else {
-			// Direct file
-			sourcePath := filepath.Join(sourceDir, pattern)
-			if _, err := os.Stat(sourcePath); err == nil {
-				targetPath := filepath.Join(ConfigDir, pattern)
-				if err := copyFile(sourcePath, targetPath); err == nil {
-					existingFiles = append(existingFiles, pattern)
-				} else if verbose {
-					fmt.Printf("⚠️  Failed to copy %s: %v\n", pattern, err)
-				}
-			} else {
-				// File doesn't exist in source
-				// Check if it exists in ConfigDir (maybe user added it manually)
-				configPath := filepath.Join(ConfigDir, pattern)
-				if _, err := os.Stat(configPath); err == nil {
-					existingFiles = append(existingFiles, pattern)
-				} else {
-					missingFiles = append(missingFiles, pattern)
-				}
-			}
+	if len(renames) > 0 {
+		fmt.Printf("↪️  Renamed %d files:\n", len(renames))
+		for _, r := range renames {
+			fmt.Printf("   %s → %s\n", r[0], r[1])
 		}
 	}
-
-	// Always include .spirit-tracked itself
-	if _, err := os.Stat(filepath.Join(ConfigDir, ".spirit-tracked")); err == nil {
-		// Already exists
+	if deleted := len(staleFiles) - len(renames); deleted > 0 {
+		fmt.Printf("🗑️  Removing %d files deleted from source\n", deleted)
+		if verbose {
+			for _, f := range staleFiles {
+				fmt.Printf("   - %s\n", f)
+			}
+		}
 	}
 
 	if verbose {
@@ -154,37 +134,43 @@ func runSync(verbose bool) error {
 		return fmt.Errorf("no files to sync (check .spirit-tracked or SPIRIT_SOURCE_DIR)")
 	}
 
-	// Stage and sync
+	// Exactly what was scanned is staged, with the files spirit keeps next
+	// to the state and the removals
+	scanFiles := existingFiles
+	for _, f := range spiritRepoFiles {
+		if _, err := os.Lstat(filepath.Join(ConfigDir, f)); err == nil && !matchesTracked(f, tracked) {
+			scanFiles = append(scanFiles, f)
+		}
+	}
+	redacted, err := guardSecrets(scanFiles)
+	if err != nil {
+		return err
+	}
+
+	// Stage and commit locally, then push to every backend
 	fmt.Println("➕ Staging changes...")
-	if err := gitAddAll(); err != nil {
+	if err := gitAddFiles(append(scanFiles, deletedTrackedFiles(tracked)...)); err != nil {
 		return fmt.Errorf("git add failed: %w", err)
 	}
-
-	fmt.Println("📥 Fetching remote...")
-	gitFetch()
-
-	fmt.Println("🔄 Syncing with remote...")
-	if err := gitPull(); err != nil {
-		return fmt.Errorf("sync failed: %w", err)
+	if err := stageRedacted(redacted); err != nil {
+		return err
 	}
 
 	commitMsg := fmt.Sprintf("SPIRIT sync: %s (%d files)", time.Now().Format("2006-01-02 15:04"), len(existingFiles))
 	fmt.Println("💾 Creating commit...")
-	if err := gitCommit(commitMsg); err != nil {
-		if strings.Contains(err.Error(), "nothing") || strings.Contains(err.Error(), "No changes") {
-			fmt.Println("✅ Already up to date")
-			return nil
+	if err := gitCommit(spiritCommitMessage(kindSync, commitMsg)); err != nil {
+		if !errors.Is(err, ErrNothingToCommit) {
+			return fmt.Errorf("git commit failed: %w", err)
 		}
-		return fmt.Errorf("git commit failed: %w", err)
+		fmt.Println("   No local changes")
 	}
 
-	fmt.Println("☁️ Pushing to remote...")
-	if err := gitPush(); err != nil {
-		return fmt.Errorf("git push failed: %w", err)
+	fmt.Println("☁️ Pushing to backends...")
+	if err := pushToBackends(backends); err != nil {
+		return fmt.Errorf("sync failed: %w", err)
 	}
 
 	fmt.Println("✅ Sync complete!")
-	fmt.Printf("   Remote: %s\n", remoteURL)
 	fmt.Printf("   Files: %d\n", len(existingFiles))
 	if sourceDir != ConfigDir {
 		fmt.Printf("   Source: %s\n", sourceDir)
@@ -192,6 +178,147 @@ func runSync(verbose bool) error {
 	return nil
 }
 
+// stageResult describes how the source directory was mirrored into ConfigDir.
+type stageResult struct {
+	Existing []string
+	Missing  []string
+	Removed  []string
+	Renames  [][2]string
+}
+
+// deletePolicy says what stageSourceFiles does with tracked files that are
+// gone from the source directory.
+type deletePolicy int
+
+const (
+	deleteStale    deletePolicy = iota // remove them, unless that removes every tracked file
+	keepStale                          // keep them (--no-delete)
+	deleteAllStale                     // remove them even if none are left (--delete-all)
+)
+
+// ErrDeleteAll is returned instead of removing every tracked file from
+// ConfigDir, which usually means the source directory is the wrong one.
+var ErrDeleteAll = errors.New("refusing to remove every tracked file")
+
+// stageSourceFiles mirrors the tracked files of sourceDir into ConfigDir.
+func stageSourceFiles(sourceDir string, tracked []string, policy deletePolicy, verbose bool) (*stageResult, error) {
+	info, err := os.Stat(sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("source directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("source directory %s is not a directory", sourceDir)
+	}
+
+	// Copy fresh files from sourceDir and remove tracked files that no
+	// longer exist there, so the git repo always reflects the current
+	// workspace state
+	existingFiles := []string{}
+	missingFiles := []string{}
+	addedFiles := []string{}
+	inSource := map[string]bool{}
+
+	for _, relPath := range collectTrackedFiles(sourceDir, tracked) {
+		inSource[relPath] = true
+		targetPath := filepath.Join(ConfigDir, relPath)
+		if _, err := os.Stat(targetPath); os.IsNotExist(err) {
+			addedFiles = append(addedFiles, relPath)
+		}
+		if sourceDir != ConfigDir {
+			if err := copyFile(filepath.Join(sourceDir, relPath), targetPath); err != nil {
+				if verbose {
+					fmt.Printf("⚠️  Failed to copy %s: %v\n", relPath, err)
+				}
+				continue
+			}
+		}
+		existingFiles = append(existingFiles, relPath)
+	}
+
+	staleFiles := []string{}
+	removable := 0
+	for _, relPath := range collectTrackedFiles(ConfigDir, tracked) {
+		if !configOnlyFiles[relPath] {
+			removable++
+		}
+		if inSource[relPath] {
+			continue
+		}
+		if policy == keepStale || configOnlyFiles[relPath] {
+			existingFiles = append(existingFiles, relPath)
+			continue
+		}
+		staleFiles = append(staleFiles, relPath)
+	}
+
+	for _, pattern := range tracked {
+		if strings.Contains(pattern, "*") || inSource[pattern] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(ConfigDir, pattern)); os.IsNotExist(err) {
+			missingFiles = append(missingFiles, pattern)
+		}
+	}
+
+	// A stale file whose content reappears under a new path was renamed
+	renames := detectRenames(staleFiles, addedFiles, sourceDir)
+	if deleted := len(staleFiles) - len(renames); policy == deleteStale && deleted > 0 && deleted == removable {
+		return nil, fmt.Errorf("%w: %s has none of the %d tracked files in %s (sync --delete-all if that is intended)",
+			ErrDeleteAll, sourceDir, removable, ConfigDir)
+	}
+	for _, relPath := range staleFiles {
+		if err := os.Remove(filepath.Join(ConfigDir, relPath)); err != nil {
+			return nil, fmt.Errorf("cannot remove %s: %w", relPath, err)
+		}
+	}
+	return &stageResult{
+		Existing: existingFiles,
+		Missing:  missingFiles,
+		Removed:  staleFiles,
+		Renames:  renames,
+	}, nil
+}
+
+// spiritRepoFiles are committed by every sync alongside the tracked files.
+var spiritRepoFiles = []string{"spirit.json", ".spirit-tracked", ".gitignore", ".gitattributes"}
+
+// configOnlyFiles live only in ConfigDir and are never removed by sync.
+var configOnlyFiles = map[string]bool{
+	"spirit.json":     true,
+	".spirit-tracked": true,
+}
+
+// detectRenames pairs removed ConfigDir files with newly added source files
+// that have identical content.
+func detectRenames(removed, added []string, sourceDir string) [][2]string {
+	byHash := map[string]string{}
+	for _, relPath := range removed {
+		if content, err := os.ReadFile(filepath.Join(ConfigDir, relPath)); err == nil {
+			byHash[sha256Hex(content)] = relPath
+		}
+	}
+
+	renames := [][2]string{}
+	for _, relPath := range added {
+		content, err := os.ReadFile(filepath.Join(sourceDir, relPath))
+		if err != nil {
+			continue
+		}
+		if from, ok := byHash[sha256Hex(content)]; ok {
+			renames = append(renames, [2]string{from, relPath})
+			delete(byHash, sha256Hex(content))
+		}
+	}
+	return renames
+}
+
+// defaultTrackedFiles is used when no .spirit-tracked config can be read.
+var defaultTrackedFiles = []string{
+	"IDENTITY.md", "SOUL.md", "AGENTS.md", "TOOLS.md", "PROJECTS.md",
+	"HEARTBEAT.md", "README.md", "spirit.json", ".spirit-tracked",
+	"memory/*.md", "projects/*.md", "context/*.md",
+}
+
 func copyFile(src, dst string) error {
 	// Ensure directory exists
 	dir := filepath.Dir(dst)
@@ -206,8 +333,27 @@ func copyFile(src, dst string) error {
 	return os.WriteFile(dst, content, 0644)
 }
 
+// trackedFilesOrDefault returns the tracked patterns of dir, or the
+// defaults when it has no .spirit-tracked. A config that cannot be read,
+// or was written by a newer spirit, is an error rather than a reason to
+// track something else.
+func trackedFilesOrDefault(dir string) ([]string, error) {
+	tracked, err := loadTrackedFilesFrom(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultTrackedFiles, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load .spirit-tracked: %w", err)
+	}
+	return tracked, nil
+}
+
 func loadTrackedFiles() ([]string, error) {
-	trackedPath := filepath.Join(ConfigDir, ".spirit-tracked")
+	return loadTrackedFilesFrom(ConfigDir)
+}
+
+func loadTrackedFilesFrom(dir string) ([]string, error) {
+	trackedPath := filepath.Join(dir, ".spirit-tracked")
 	data, err := os.ReadFile(trackedPath)
 	if err != nil {
 		// Check if it's a symlink and read target
@@ -221,6 +367,12 @@ func loadTrackedFiles() ([]string, error) {
 			return nil, err
 		}
 	}
+	if err := trackedSchemaFile.upgradeFile(trackedPath); err != nil {
+		return nil, err
+	}
+	if data, err = os.ReadFile(trackedPath); err != nil {
+		return nil, err
+	}
 	var config TrackedConfig
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, err
@@ -228,100 +380,55 @@ func loadTrackedFiles() ([]string, error) {
 	return config.Files, nil
 }
 
-func gitInit() error {
-	cmd := exec.Command("git", "init")
-	cmd.Dir = ConfigDir
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s: %w", string(output), err)
-	}
-	return nil
-}
-
-func getRemoteURL() (string, error) {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = ConfigDir
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
+// collectTrackedFiles expands tracked patterns against dir and returns the
+// relative paths of the regular files that currently exist there.
+func collectTrackedFiles(dir string, patterns []string) []string {
+	seen := map[string]bool{}
+	files := []string{}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			relPath, err := filepath.Rel(dir, match)
+			if err != nil || seen[relPath] {
+				continue
+			}
+			seen[relPath] = true
+			files = append(files, relPath)
+		}
 	}
-	return strings.TrimSpace(string(output)), nil
+	return files
 }
 
-func gitAddAll() error {
-	cmd := exec.Command("git", "add", "-A")
-	cmd.Dir = ConfigDir
-	output, err := cmd.CombinedOutput()
+// deletedTrackedFiles lists the indexed files covered by the tracked
+// patterns that no longer exist in ConfigDir, to be staged as removals.
+func deletedTrackedFiles(tracked []string) []string {
+	deleted := []string{}
+	indexed, err := gitIndexedFiles()
 	if err != nil {
-		return fmt.Errorf("%s", string(output))
+		return deleted
 	}
-	return nil
-}
-
-func gitFetch() error {
-	cmd := exec.Command("git", "fetch", "origin")
-	cmd.Dir = ConfigDir
-	output, err :=	cmd.CombinedOutput()
-	if err != nil {
-		outputStr := strings.TrimSpace(string(output))
-		if !strings.Contains(outputStr, "could not resolve") && 
-		   !strings.Contains(outputStr, "does not appear to be") &&
-		   !strings.Contains(outputStr, "No remote repository") {
-			return fmt.Errorf("git fetch failed: %s", outputStr)
+	for _, f := range indexed {
+		if !matchesTracked(f, tracked) {
+			continue
 		}
-	}
-	return nil
-}
-
-func gitPull() error {
-	cmd := exec.Command("git", "pull", "--rebase", "origin", "main")
-	cmd.Dir = ConfigDir
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		if strings.Contains(string(output), "main") || strings.Contains(string(output), "couldn't find") {
-			cmd = exec.Command("git", "pull", "--rebase", "origin", "master")
-			cmd.Dir = ConfigDir
-			output, err = cmd.CombinedOutput()
-			if err != nil {
-				if strings.Contains(string(output), "no such ref") || strings.Contains(string(output), "could not resolve") {
-					return nil
-				}
-				return fmt.Errorf("git pull failed: %s", string(output))
-			}
-		} else if strings.Contains(string(output), "no such ref") || strings.Contains(string(output), "could not resolve") {
-			return nil
-		} else {
-			return fmt.Errorf("git pull failed: %s", string(output))
+		if _, err := os.Lstat(filepath.Join(ConfigDir, f)); os.IsNotExist(err) {
+			deleted = append(deleted, f)
 		}
 	}
-	return nil
-}
-
-func gitCommit(message string) error {
-	cmd := exec.Command("git", "commit", "-m", message)
-	cmd.Dir = ConfigDir
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("%s", string(output))
-	}
-	return nil
+	return deleted
 }
 
-func gitPush() error {
-	cmd := exec.Command("git", "push", "origin", "main")
-	cmd.Dir = ConfigDir
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		if strings.Contains(string(output), "main") {
-			cmd = exec.Command("git", "push", "origin", "master")
-			cmd.Dir = ConfigDir
-			output, err = cmd.CombinedOutput()
-			if err != nil {
-				return fmt.Errorf("git push failed: %s", string(output))
-			}
-			return nil
+// matchesTracked reports whether a repo-relative path is covered by any of
+// the tracked patterns.
+func matchesTracked(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, filepath.FromSlash(path)); ok {
+			return true
 		}
-		return fmt.Errorf("git push failed: %s", string(output))
 	}
-	return nil
+	return false
 }
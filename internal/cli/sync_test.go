@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newSourceDir writes files into a fresh source directory.
+func newSourceDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestStageSourceFiles(t *testing.T) {
+	tracked := []string{"IDENTITY.md", "memory/*.md", "spirit.json"}
+
+	t.Run("deletes", func(t *testing.T) {
+		withGitEnv(t)
+		dir := newStateRepo(t, "main")
+		source := newSourceDir(t, map[string]string{"IDENTITY.md": "- **Name:** orion\n"})
+
+		staged, err := stageSourceFiles(source, tracked, deleteStale, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"memory/2026-01.md"}; !reflect.DeepEqual(staged.Removed, want) {
+			t.Errorf("removed = %v, want %v", staged.Removed, want)
+		}
+		if exists(filepath.Join(dir, "memory/2026-01.md")) {
+			t.Error("memory/2026-01.md was kept")
+		}
+		if !exists(filepath.Join(dir, "spirit.json")) {
+			t.Error("spirit.json was removed")
+		}
+	})
+
+	t.Run("renames", func(t *testing.T) {
+		withGitEnv(t)
+		dir := newStateRepo(t, "main")
+		source := newSourceDir(t, map[string]string{
+			"IDENTITY.md":         "- **Name:** orion\n",
+			"memory/2026-01-a.md": "- first day\n- second day\n",
+		})
+
+		staged, err := stageSourceFiles(source, tracked, deleteStale, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := [][2]string{{"memory/2026-01.md", "memory/2026-01-a.md"}}; !reflect.DeepEqual(staged.Renames, want) {
+			t.Errorf("renames = %v, want %v", staged.Renames, want)
+		}
+		if exists(filepath.Join(dir, "memory/2026-01.md")) || !exists(filepath.Join(dir, "memory/2026-01-a.md")) {
+			t.Error("rename not mirrored into the state directory")
+		}
+	})
+
+	t.Run("no-delete", func(t *testing.T) {
+		withGitEnv(t)
+		dir := newStateRepo(t, "main")
+		source := newSourceDir(t, map[string]string{"IDENTITY.md": "- **Name:** orion\n"})
+
+		staged, err := stageSourceFiles(source, tracked, keepStale, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(staged.Removed) != 0 || !exists(filepath.Join(dir, "memory/2026-01.md")) {
+			t.Errorf("removed %v with --no-delete", staged.Removed)
+		}
+	})
+
+	t.Run("empty source", func(t *testing.T) {
+		withGitEnv(t)
+		dir := newStateRepo(t, "main")
+		source := newSourceDir(t, map[string]string{"notes.txt": "untracked\n"})
+
+		if _, err := stageSourceFiles(source, tracked, deleteStale, false); !errors.Is(err, ErrDeleteAll) {
+			t.Fatalf("stage = %v, want ErrDeleteAll", err)
+		}
+		for _, f := range []string{"IDENTITY.md", "memory/2026-01.md"} {
+			if !exists(filepath.Join(dir, f)) {
+				t.Errorf("%s was removed", f)
+			}
+		}
+
+		if _, err := stageSourceFiles(source, tracked, deleteAllStale, false); err != nil {
+			t.Fatalf("stage with --delete-all = %v", err)
+		}
+		if exists(filepath.Join(dir, "IDENTITY.md")) || !exists(filepath.Join(dir, "spirit.json")) {
+			t.Error("--delete-all did not remove exactly the tracked files")
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		withGitEnv(t)
+		dir := newStateRepo(t, "main")
+
+		if _, err := stageSourceFiles(filepath.Join(t.TempDir(), "gone"), tracked, deleteAllStale, false); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("stage = %v, want ErrNotExist", err)
+		}
+		if !exists(filepath.Join(dir, "IDENTITY.md")) {
+			t.Error("IDENTITY.md was removed")
+		}
+	})
+}